@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"errors"
+	"reflect"
+	"time"
+)
+
+var errBatchTarget = errors.New("cache: target of GetMulti must be a pointer to a map with string keys")
+
+// GetMulti gets several items at once. The target must be a pointer
+// to a map with string keys, like *map[string]person, and the items
+// that are found are added to it. Missing and expired keys are left out.
+//
+// Every adapter is asked for the keys that were not found yet, with one
+// call if it implements BatchGetter and one Get per key otherwise.
+// The read strategy and WithRenewOnRead are not used.
+func (c *Cache) GetMulti(keys []string, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Map || v.Elem().Type().Key().Kind() != reflect.String {
+		return errBatchTarget
+	}
+	m := v.Elem()
+	if m.IsNil() {
+		m.Set(reflect.MakeMap(m.Type()))
+	}
+
+	start := time.Now()
+	found, err := c.getMulti(keys)
+	c.observe("getmulti", "", start, err)
+	if err != nil {
+		return err
+	}
+
+	for key, data := range found {
+		value := reflect.New(m.Type().Elem())
+		err := c.decode(data, value.Interface())
+		if err != nil {
+			return err
+		}
+		m.SetMapIndex(reflect.ValueOf(key).Convert(m.Type().Key()), value.Elem())
+	}
+	return nil
+}
+
+// getMulti returns the data by the keys that were passed in,
+// not the transformed keys.
+func (c *Cache) getMulti(keys []string) (map[string][]byte, error) {
+	original := make(map[string]string, len(keys))
+	missing := make([]string, 0, len(keys))
+	for _, key := range keys {
+		transformed := c.key(key)
+		if _, ok := original[transformed]; !ok {
+			missing = append(missing, transformed)
+		}
+		original[transformed] = key
+	}
+
+	found := make(map[string][]byte, len(keys))
+	for _, adapter := range c.adapters {
+		if len(missing) == 0 {
+			break
+		}
+
+		result, err := getMultiFrom(adapter, missing)
+		if err != nil {
+			return nil, err
+		}
+
+		var rest []string
+		for _, key := range missing {
+			if data, ok := result[key]; ok {
+				found[original[key]] = data
+			} else {
+				rest = append(rest, key)
+			}
+		}
+		missing = rest
+	}
+
+	return found, nil
+}
+
+func getMultiFrom(adapter Adapter, keys []string) (map[string][]byte, error) {
+	var getter BatchGetter
+	if as(adapter, &getter) {
+		return getter.GetMulti(keys)
+	}
+
+	result := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		data, err := adapter.Get(key)
+		if isMiss(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		result[key] = data
+	}
+	return result, nil
+}
+
+// SetMulti sets several items at once in every adapter, with one call
+// if the adapter implements BatchSetter and one Set per item otherwise.
+func (c *Cache) SetMulti(items map[string]interface{}) error {
+	data := make(map[string][]byte, len(items))
+	keys := make([]string, 0, len(items))
+	for key, value := range items {
+		encoded, err := c.codec.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		key = c.key(key)
+		data[key] = encoded
+		keys = append(keys, key)
+	}
+
+	start := time.Now()
+	return c.observe("setmulti", "", start, c.setMulti(keys, data))
+}
+
+func (c *Cache) setMulti(keys []string, data map[string][]byte) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	err := c.each(c.writeStrategy, func(adapter Adapter) error {
+		var setter BatchSetter
+		if as(adapter, &setter) {
+			return setter.SetMulti(data)
+		}
+
+		for _, key := range keys {
+			err := adapter.Set(key, data[key])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.publish(Invalidation{Keys: keys})
+	return nil
+}
+
+// DelMulti deletes several items at once from every adapter, with one
+// call if the adapter implements BatchDeleter and one Del per key otherwise.
+func (c *Cache) DelMulti(keys ...string) error {
+	transformed := make([]string, len(keys))
+	for i, key := range keys {
+		transformed[i] = c.key(key)
+	}
+
+	start := time.Now()
+	return c.observe("delmulti", "", start, c.delMulti(transformed))
+}
+
+func (c *Cache) delMulti(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	err := c.each(c.delStrategy, func(adapter Adapter) error {
+		var deleter BatchDeleter
+		if as(adapter, &deleter) {
+			return deleter.DelMulti(keys)
+		}
+
+		for _, key := range keys {
+			err := adapter.Del(key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.publish(Invalidation{Keys: keys})
+	return nil
+}
@@ -0,0 +1,193 @@
+package broadcast
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// DefaultTimeout is the timeout of a request to a peer.
+const DefaultTimeout = time.Second * 2
+
+// MaxBodySize is the largest invalidation that ServeHTTP accepts.
+const MaxBodySize = 1 << 20
+
+// MaxSkew is how far the time of a request may be away from the
+// time of the peer that receives it, so that a request that was
+// recorded can't be sent again later.
+const MaxSkew = time.Minute * 5
+
+// The headers of the requests.
+const (
+	timestampHeader = "X-Invalidation-Timestamp"
+	signatureHeader = "X-Invalidation-Signature"
+)
+
+// HTTP sends invalidations as a POST request with a json body to
+// every peer. It is also the http.Handler that receives them, so
+// it has to be mounted at the url that the other peers use.
+//
+// Every request is signed with HMAC-SHA256 and the secret, which
+// every peer must share. ServeHTTP rejects requests without a valid
+// signature, so that nobody else can evict the items of a peer.
+type HTTP struct {
+	Client *http.Client
+
+	secret []byte
+	peers  []string
+	fns    []func(msg cache.Invalidation)
+	m      sync.RWMutex
+}
+
+// NewHTTP returns an invalidator that publishes to the urls of the
+// peers. The url of the instance itself should not be in the list.
+// It panics if the secret is empty.
+func NewHTTP(secret []byte, peers ...string) *HTTP {
+	if len(secret) == 0 {
+		panic("broadcast: the secret of NewHTTP is empty")
+	}
+	return &HTTP{
+		Client: &http.Client{Timeout: DefaultTimeout},
+		secret: secret,
+		peers:  peers,
+	}
+}
+
+// sign returns the signature of the body at that time.
+func (h *HTTP) sign(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'\n'})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks the signature and that the
+// request is not older or newer than MaxSkew.
+func (h *HTTP) verify(r *http.Request, body []byte) bool {
+	timestamp := r.Header.Get(timestampHeader)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	skew := time.Since(time.Unix(unix, 0))
+	if skew > MaxSkew || skew < -MaxSkew {
+		return false
+	}
+
+	expected := h.sign(timestamp, body)
+	return hmac.Equal([]byte(r.Header.Get(signatureHeader)), []byte(expected))
+}
+
+// SetPeers replaces the peers, for example after
+// the service discovery found a new instance.
+func (h *HTTP) SetPeers(peers ...string) {
+	h.m.Lock()
+	h.peers = peers
+	h.m.Unlock()
+}
+
+// Publish sends the invalidation to every peer at the same time.
+// Every peer is tried and the first error is returned.
+func (h *HTTP) Publish(msg cache.Invalidation) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	h.m.RLock()
+	peers := h.peers
+	h.m.RUnlock()
+
+	errs := make(chan error, len(peers))
+	for _, peer := range peers {
+		go func(peer string) {
+			errs <- h.send(peer, body)
+		}(peer)
+	}
+
+	var first error
+	for range peers {
+		err := <-errs
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (h *HTTP) send(peer string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, peer, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(timestampHeader, timestamp)
+	req.Header.Set(signatureHeader, h.sign(timestamp, body))
+
+	res, err := h.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("broadcast: peer %s returned %s", peer, res.Status)
+	}
+	return nil
+}
+
+func (h *HTTP) Subscribe(fn func(msg cache.Invalidation)) error {
+	h.m.Lock()
+	h.fns = append(h.fns, fn)
+	h.m.Unlock()
+
+	return nil
+}
+
+// ServeHTTP receives the invalidations of the peers. Requests
+// without a valid signature or larger than MaxBodySize are rejected.
+func (h *HTTP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !h.verify(r, body) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	var msg cache.Invalidation
+	err = json.Unmarshal(body, &msg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.m.RLock()
+	fns := h.fns
+	h.m.RUnlock()
+
+	for _, fn := range fns {
+		fn(msg)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,133 @@
+package broadcast
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+var secret = []byte("secret")
+
+func TestHTTP_Set(t *testing.T) {
+	shared := newShared(t)
+
+	invA := NewHTTP(secret)
+	invB := NewHTTP(secret)
+	srvA := httptest.NewServer(invA)
+	defer srvA.Close()
+	srvB := httptest.NewServer(invB)
+	defer srvB.Close()
+	invA.SetPeers(srvB.URL)
+	invB.SetPeers(srvA.URL)
+
+	a := newInstance(t, shared, invA)
+	b := newInstance(t, shared, invB)
+
+	err := a.Set("key", "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, b, "key", "one")
+
+	err = a.Set("key", "two")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, b, "key", "two")
+}
+
+func TestHTTP_PeerDown(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	inv := NewHTTP(secret, srv.URL)
+	err := inv.Publish(cache.Invalidation{Keys: []string{"key"}})
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected 404 error but got %v", err)
+	}
+}
+
+func TestHTTP_ServeHTTP(t *testing.T) {
+	inv := NewHTTP(secret)
+
+	var keys []string
+	inv.Subscribe(func(msg cache.Invalidation) {
+		keys = append(keys, msg.Keys...)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	inv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 but got %d", rec.Code)
+	}
+
+	body := `{"Keys":["a","b"]}`
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set(timestampHeader, timestamp)
+	req.Header.Set(signatureHeader, inv.sign(timestamp, []byte(body)))
+	rec = httptest.NewRecorder()
+	inv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204 but got %d", rec.Code)
+	}
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("got wrong keys %v", keys)
+	}
+}
+
+func TestHTTP_Unauthorized(t *testing.T) {
+	inv := NewHTTP(secret)
+	var received int
+	inv.Subscribe(func(msg cache.Invalidation) {
+		received++
+	})
+
+	body := `{"Prefixes":[""]}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-2*MaxSkew).Unix(), 10)
+	other := NewHTTP([]byte("other"))
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+	}{
+		{"Missing", "", ""},
+		{"OtherSecret", now, other.sign(now, []byte(body))},
+		{"OtherTimestamp", old, inv.sign(now, []byte(body))},
+		{"Old", old, inv.sign(old, []byte(body))},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			req.Header.Set(timestampHeader, test.timestamp)
+			req.Header.Set(signatureHeader, test.signature)
+			rec := httptest.NewRecorder()
+			inv.ServeHTTP(rec, req)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("expected 401 but got %d", rec.Code)
+			}
+		})
+	}
+	if received != 0 {
+		t.Error("received an unauthorized invalidation")
+	}
+}
+
+func TestHTTP_TooLarge(t *testing.T) {
+	inv := NewHTTP(secret)
+
+	body := strings.Repeat(" ", MaxBodySize+1)
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	inv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 but got %d", rec.Code)
+	}
+}
@@ -0,0 +1,77 @@
+// Package broadcast sends invalidations between the instances of a
+// cache, so that every instance evicts items from its local adapters
+// once another instance changed them.
+//
+// The Hub connects caches in the same process and is meant for tests.
+// HTTP sends the invalidations to a list of peers.
+package broadcast
+
+import (
+	"sync"
+
+	"github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// Hub connects invalidators in the same process.
+type Hub struct {
+	members []*member
+	m       sync.Mutex
+}
+
+// NewHub returns an empty hub.
+func NewHub() *Hub {
+	return &Hub{}
+}
+
+// Join returns a new invalidator that receives
+// the invalidations of every other member.
+func (h *Hub) Join() cache.Invalidator {
+	mem := &member{hub: h}
+
+	h.m.Lock()
+	h.members = append(h.members, mem)
+	h.m.Unlock()
+
+	return mem
+}
+
+type member struct {
+	hub *Hub
+
+	fns []func(msg cache.Invalidation)
+	m   sync.Mutex
+}
+
+// Publish calls the subscribers of the other members before it returns.
+func (mem *member) Publish(msg cache.Invalidation) error {
+	mem.hub.m.Lock()
+	members := append([]*member(nil), mem.hub.members...)
+	mem.hub.m.Unlock()
+
+	for _, other := range members {
+		if other == mem {
+			continue
+		}
+		other.receive(msg)
+	}
+	return nil
+}
+
+func (mem *member) Subscribe(fn func(msg cache.Invalidation)) error {
+	mem.m.Lock()
+	mem.fns = append(mem.fns, fn)
+	mem.m.Unlock()
+
+	return nil
+}
+
+func (mem *member) receive(msg cache.Invalidation) {
+	mem.m.Lock()
+	fns := make([]func(msg cache.Invalidation), len(mem.fns))
+	copy(fns, mem.fns)
+	mem.m.Unlock()
+
+	for _, fn := range fns {
+		fn(msg)
+	}
+}
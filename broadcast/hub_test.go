@@ -0,0 +1,159 @@
+package broadcast
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/JohannesKaufmann/dynamodb-cache/memadapter"
+)
+
+// newInstance returns a cache with its own memory adapter in
+// front of the shared adapter, like one instance of a service.
+func newInstance(t *testing.T, shared cache.Adapter, inv cache.Invalidator) *cache.Cache {
+	c, err := cache.New(
+		memadapter.New(time.Hour, false),
+		func() (cache.Adapter, error) { return shared, nil },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.SetInvalidator(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func newShared(t *testing.T) cache.Adapter {
+	shared, err := memadapter.New(time.Hour, false)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return shared
+}
+
+// expectValue waits until c returns the expected value, because
+// the invalidations are published in the background.
+func expectValue(t *testing.T, c *cache.Cache, key, expected string) {
+	t.Helper()
+
+	var value string
+	var err error
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		err = c.Get(key, &value)
+		if err == nil && value == expected {
+			return
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Errorf("expected '%s' but got '%s'", expected, value)
+}
+
+// expectNotFound waits until the item is evicted from c.
+func expectNotFound(t *testing.T, c *cache.Cache, key string) {
+	t.Helper()
+
+	var value string
+	var err error
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		err = c.Get(key, &value)
+		if err == cache.ErrNotFound {
+			return
+		}
+	}
+	t.Errorf("expected ErrNotFound but got %v", err)
+}
+
+func TestHub_Set(t *testing.T) {
+	shared := newShared(t)
+	hub := NewHub()
+	a := newInstance(t, shared, hub.Join())
+	b := newInstance(t, shared, hub.Join())
+
+	err := a.Set("key", "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, b, "key", "one")
+
+	err = a.Set("key", "two")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without the invalidation b would still have "one" in memory
+	expectValue(t, b, "key", "two")
+	expectValue(t, a, "key", "two")
+}
+
+func TestHub_Del(t *testing.T) {
+	shared := newShared(t)
+	hub := NewHub()
+	a := newInstance(t, shared, hub.Join())
+	b := newInstance(t, shared, hub.Join())
+
+	err := a.Set("key", "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, b, "key", "one")
+
+	err = a.Del("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectNotFound(t, b, "key")
+}
+
+func TestHub_DelPrefix(t *testing.T) {
+	shared := newShared(t)
+	hub := NewHub()
+	a := newInstance(t, shared, hub.Join())
+	b := newInstance(t, shared, hub.Join())
+
+	for _, key := range []string{"user:1", "user:2", "post:1"} {
+		err := a.Set(key, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectValue(t, b, key, key)
+	}
+
+	_, err := a.DelPrefix("user:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectNotFound(t, b, "user:1")
+	expectValue(t, b, "post:1", "post:1")
+}
+
+func TestHub_NotSelf(t *testing.T) {
+	hub := NewHub()
+	inv := hub.Join()
+	other := hub.Join()
+
+	var received int
+	inv.Subscribe(func(msg cache.Invalidation) {
+		received++
+	})
+
+	err := inv.Publish(cache.Invalidation{Keys: []string{"key"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received != 0 {
+		t.Error("received own invalidation")
+	}
+
+	err = other.Publish(cache.Invalidation{Keys: []string{"key"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received != 1 {
+		t.Errorf("expected 1 invalidation but got %d", received)
+	}
+}
@@ -0,0 +1,89 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/JohannesKaufmann/dynamodb-cache/memadapter"
+)
+
+func TestSetBytes_GetBytes(t *testing.T) {
+	c, err := cache.New(memadapter.New(time.Hour, false), memadapter.New(time.Hour, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := []byte(`{"name":"Johannes"}`)
+	err = c.SetBytes("json", value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value[0] = 'x' // the cache should have its own copy
+
+	data, err := c.GetBytes("json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"name":"Johannes"}` {
+		t.Errorf("got wrong bytes '%s'", data)
+	}
+
+	info, err := c.GetWithMetadata("json", nil)
+	if err != cache.ErrWrongCodec {
+		t.Errorf("expected ErrWrongCodec but got %v", err)
+	}
+	if info.Codec != "raw" {
+		t.Errorf("expected codec 'raw' but got '%s'", info.Codec)
+	}
+}
+
+func TestSetBytes_Empty(t *testing.T) {
+	c, err := cache.New(memadapter.New(time.Hour, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.SetBytes("empty", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.GetBytes("empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 {
+		t.Errorf("expected no bytes but got %v", data)
+	}
+}
+
+func TestGetBytes_WrongCodec(t *testing.T) {
+	c, err := cache.New(memadapter.New(time.Hour, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.SetBytes("raw", []byte("raw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	err = c.Get("raw", &s)
+	if err != cache.ErrWrongCodec {
+		t.Errorf("expected ErrWrongCodec but got %v", err)
+	}
+
+	err = c.Set("encoded", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.GetBytes("encoded")
+	if err != cache.ErrWrongCodec {
+		t.Errorf("expected ErrWrongCodec but got %v", err)
+	}
+
+	_, err = c.GetBytes("missing")
+	if err != cache.ErrNotFound {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+}
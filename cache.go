@@ -1,9 +1,11 @@
 package cache
 
 import (
+	"context"
 	"errors"
-
-	"github.com/vmihailenco/msgpack"
+	"fmt"
+	"io"
+	"time"
 )
 
 //go:generate moq -pkg cache_test -out adapter_moq_test.go . Adapter
@@ -12,95 +14,318 @@ type Adapter interface {
 	Set(key string, value []byte) error
 	Get(key string) ([]byte, error)
 	Del(key string) error
-
-	// Del(keys ...string) error
-	// GetMultiple, BatchGet
 }
 
 type InitAdapter func() (Adapter, error)
 
 type Cache struct {
 	adapters []Adapter
-}
 
-// New initializes a new cache with the adapters that are passed in.
-func New(adapters ...InitAdapter) (*Cache, error) {
-	if len(adapters) == 0 {
-		return nil, errors.New("you need at least one adapter")
-	}
+	// set by the options, see WithRenewOnRead,
+	// WithReadStrategy and WithWriteStrategy
+	renewOnRead   time.Duration
+	readStrategy  ReadStrategy
+	hedgeDelay    time.Duration
+	writeStrategy WriteStrategy
+	delStrategy   WriteStrategy
 
-	var c Cache
-	for _, init := range adapters {
-		adapter, err := init()
-		if err != nil {
-			return nil, err
-		}
+	publisher *publisher
 
-		c.adapters = append(c.adapters, adapter)
-	}
+	// inits holds the adapters of WithAdapters
+	// until they are initialized.
+	inits []InitAdapter
+
+	names     []string
+	codec     Codec
+	logger    Logger
+	observer  Observer
+	transform func(key string) string
+}
 
-	return &c, nil
+// New initializes a new cache with the adapters that are passed in.
+// It is the same as NewWithOptions(WithAdapters(adapters...)).
+func New(adapters ...InitAdapter) (*Cache, error) {
+	return NewWithOptions(WithAdapters(adapters...))
 }
 
 // common errors
 var (
 	ErrNotFound = errors.New("item not found")
 	ErrExpired  = errors.New("item found but expired")
+
+	ErrAlreadyExists = errors.New("item already exists")
+	ErrConflict      = errors.New("item was changed concurrently")
+	ErrUnsupported   = errors.New("operation not supported by adapter")
 )
 
-/*
-func GetBytes(key interface{}) ([]byte, error) {
-    var buf bytes.Buffer
-    enc := gob.NewEncoder(&buf)
-    err := enc.Encode(key)
-    if err != nil {
-        return nil, err
-    }
-    return buf.Bytes(), nil
+// ErrWrongCodec is returned if an item that was set with SetBytes
+// is read with Get or the other way around.
+var ErrWrongCodec = errors.New("item was set with a different codec")
+
+// ErrNotCounter is returned by Incr if the item was not
+// written by Incr, and by Get and GetBytes if it was.
+var ErrNotCounter = errors.New("item is not a counter")
+
+// rawMarker is put in front of the data of SetBytes. It is
+// never used by msgpack, so encoded data can't start with it.
+const rawMarker byte = 0xc1
+
+func isRaw(data []byte) bool {
+	return len(data) > 0 && data[0] == rawMarker
+}
+
+func (c *Cache) codecName(data []byte) string {
+	if isCounter(data) {
+		return "counter"
+	}
+	if isRaw(data) {
+		return "raw"
+	}
+	if namer, ok := c.codec.(Namer); ok {
+		return namer.Name()
+	}
+	return fmt.Sprintf("%T", c.codec)
 }
-*/
 
 // Get gets the item from the cache. It tries every adapter until
 // it finds it.
 func (c *Cache) Get(key string, target interface{}) error {
-	var finalErr = ErrNotFound
+	return c.GetContext(context.Background(), key, target)
+}
 
-	for _, adapter := range c.adapters {
-		data, err := adapter.Get(key)
+// GetContext is like Get but stops waiting for the adapters once
+// the context is canceled. Adapters that implement ContextGetter
+// also cancel their request.
+func (c *Cache) GetContext(ctx context.Context, key string, target interface{}) error {
+	data, _, err := c.get(ctx, key, false)
+	if err != nil {
+		return err
+	}
 
-		if err != nil && (err == ErrNotFound || err == ErrExpired) {
-			finalErr = err
-			continue
-		} else if err != nil {
-			return err
-		}
+	return c.decode(data, target)
+}
 
-		return msgpack.Unmarshal(data, target)
+func (c *Cache) decode(data []byte, target interface{}) error {
+	if isCounter(data) {
+		return ErrNotCounter
+	}
+	if isRaw(data) {
+		return ErrWrongCodec
 	}
+	return c.codec.Unmarshal(data, target)
+}
 
-	return finalErr
+// GetBytes returns the bytes that were set with SetBytes.
+func (c *Cache) GetBytes(key string) ([]byte, error) {
+	data, _, err := c.get(context.Background(), key, false)
+	if err != nil {
+		return nil, err
+	}
+	if isCounter(data) {
+		return nil, ErrNotCounter
+	}
+	if !isRaw(data) {
+		return nil, ErrWrongCodec
+	}
+
+	return data[1:], nil
 }
 
 // Set sets the value for that key in the cache.
 func (c *Cache) Set(key string, value interface{}) error {
-	data, err := msgpack.Marshal(value)
+	data, err := c.codec.Marshal(value)
 	if err != nil {
 		return err
 	}
 
+	return c.set(key, data)
+}
+
+// SetBytes sets the bytes without encoding them, for example
+// json that is already encoded. Use GetBytes to read them.
+func (c *Cache) SetBytes(key string, value []byte) error {
+	data := make([]byte, len(value)+1)
+	data[0] = rawMarker
+	copy(data[1:], value)
+
+	return c.set(key, data)
+}
+
+func (c *Cache) set(key string, data []byte) error {
+	key = c.key(key)
+	start := time.Now()
+	return c.observe("set", key, start, c.write(key, data))
+}
+
+// SetWithTTL sets the value with a different ttl than the default of
+// the adapters. Adapters that don't implement TTLSetter get the item
+// with Set and then Touch. If an adapter implements neither
+// ErrUnsupported is returned before anything is written.
+func (c *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	key = c.key(key)
+	start := time.Now()
+	return c.observe("set", key, start, c.writeWithTTL(key, data, ttl))
+}
+
+func (c *Cache) writeWithTTL(key string, data []byte, ttl time.Duration) error {
 	for _, adapter := range c.adapters {
+		var setter TTLSetter
+		var toucher Toucher
+		if !as(adapter, &setter) && !as(adapter, &toucher) {
+			return ErrUnsupported
+		}
+	}
+
+	err := c.each(c.writeStrategy, func(adapter Adapter) error {
+		var setter TTLSetter
+		if as(adapter, &setter) {
+			return setter.SetWithTTL(key, data, ttl)
+		}
+
 		err := adapter.Set(key, data)
 		if err != nil {
 			return err
 		}
+		return adapter.(Toucher).Touch(key, ttl)
+	})
+	if err != nil {
+		return err
 	}
 
+	c.publish(Invalidation{Keys: []string{key}})
 	return nil
 }
 
-// Del deletes the item from the cache. The item is deleted from every adapter.
-func (c *Cache) Del(key string) error {
+func (c *Cache) write(key string, data []byte) error {
+	err := c.each(c.writeStrategy, func(adapter Adapter) error {
+		return adapter.Set(key, data)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.publish(Invalidation{Keys: []string{key}})
+	return nil
+}
+
+// Touch extends the expiry of the item to ttl from now in every
+// adapter that implements Toucher. The value is not written again.
+// If no adapter has the item ErrNotFound is returned.
+func (c *Cache) Touch(key string, ttl time.Duration) error {
+	key = c.key(key)
+	start := time.Now()
+	return c.observe("touch", key, start, c.touch(key, ttl))
+}
+
+func (c *Cache) touch(key string, ttl time.Duration) error {
+	var finalErr = ErrUnsupported
+
 	for _, adapter := range c.adapters {
+		var toucher Toucher
+		if !as(adapter, &toucher) {
+			continue
+		}
+
+		err := toucher.Touch(key, ttl)
+		if err == ErrNotFound {
+			if finalErr == ErrUnsupported {
+				finalErr = err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+		finalErr = nil
+	}
+
+	return finalErr
+}
+
+// Add sets the value for that key only if the item does not exist
+// or is expired. Otherwise ErrAlreadyExists is returned.
+//
+// The check is done atomically by the last adapter, which is
+// the one shared between instances. The item is removed from the
+// adapters in front of it, so that the next Get reads the new value.
+func (c *Cache) Add(key string, value interface{}) error {
+	key = c.key(key)
+	start := time.Now()
+	return c.observe("add", key, start, c.add(key, value))
+}
+
+func (c *Cache) add(key string, value interface{}) error {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	last := c.adapters[len(c.adapters)-1]
+	var adder Adder
+	if !as(last, &adder) {
+		return ErrUnsupported
+	}
+	err = adder.Add(key, data)
+	if err != nil {
+		return err
+	}
+
+	err = c.delUpper(key)
+	if err != nil {
+		return err
+	}
+	c.publish(Invalidation{Keys: []string{key}})
+	return nil
+}
+
+// CompareAndSwap replaces the value for that key with newValue, but
+// only if the current value still equals oldValue. Otherwise
+// ErrConflict is returned and the caller should Get the item again.
+//
+// Like Add it only runs against the last adapter. Because both
+// values are compared in their encoded form, oldValue should
+// be the value exactly as it was returned by Get.
+func (c *Cache) CompareAndSwap(key string, oldValue, newValue interface{}) error {
+	key = c.key(key)
+	start := time.Now()
+	return c.observe("cas", key, start, c.compareAndSwap(key, oldValue, newValue))
+}
+
+func (c *Cache) compareAndSwap(key string, oldValue, newValue interface{}) error {
+	oldData, err := c.codec.Marshal(oldValue)
+	if err != nil {
+		return err
+	}
+	newData, err := c.codec.Marshal(newValue)
+	if err != nil {
+		return err
+	}
+
+	last := c.adapters[len(c.adapters)-1]
+	var swapper Swapper
+	if !as(last, &swapper) {
+		return ErrUnsupported
+	}
+	err = swapper.CompareAndSwap(key, oldData, newData)
+	if err != nil {
+		return err
+	}
+
+	err = c.delUpper(key)
+	if err != nil {
+		return err
+	}
+	c.publish(Invalidation{Keys: []string{key}})
+	return nil
+}
+
+// delUpper deletes the item from every adapter except the last one.
+func (c *Cache) delUpper(key string) error {
+	for _, adapter := range c.adapters[:len(c.adapters)-1] {
 		err := adapter.Del(key)
 		if err != nil {
 			return err
@@ -110,40 +335,139 @@ func (c *Cache) Del(key string) error {
 	return nil
 }
 
-// - - - - - - //
-/*
-type Batch struct {}
+// Last returns a cache that only uses the last adapter, the one that
+// is shared between instances, with the same options as c. It is meant
+// for items of which no instance may keep a local copy, for example
+// sessions that must be gone everywhere once they are deleted.
+// It shares the invalidator of c, so only c should be closed.
+func (c *Cache) Last() *Cache {
+	last := *c
+	last.adapters = c.adapters[len(c.adapters)-1:]
+	last.names = c.names[len(c.names)-1:]
+	return &last
+}
 
-func (c *Cache) Batch() *Batch {
-	return &Batch{}
+// Del deletes the item from the cache. The item is deleted from every adapter.
+func (c *Cache) Del(key string) error {
+	key = c.key(key)
+	start := time.Now()
+	return c.observe("del", key, start, c.del(key))
 }
-func (b *Batch) Get(target interface{}, keys ...string) error {
+
+func (c *Cache) del(key string) error {
+	err := c.each(c.delStrategy, func(adapter Adapter) error {
+		return adapter.Del(key)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.publish(Invalidation{Keys: []string{key}})
 	return nil
 }
-func (b *Batch) Set(keyval ...interface{}) error {
-	for i := 0; i < len(keyval); i += 2 {
-		key, ok := keyval[i].(string)
-		if !ok {
-			return errors.New("key is not a string")
-		}
-		val := keyval[i+1]
 
-		data, err := msgpack.Marshal(val)
+// DelPrefix deletes every item whose key starts with prefix from
+// every adapter. Adapters that don't implement PrefixDeleter
+// but Scanner delete the scanned keys one by one.
+//
+// It returns the number of deleted items of the adapter
+// that deleted the most.
+func (c *Cache) DelPrefix(prefix string) (int, error) {
+	prefix = c.key(prefix)
+	var max int
+
+	for _, adapter := range c.adapters {
+		var count int
+		var err error
+		var deleter PrefixDeleter
+		var scanner Scanner
+		if as(adapter, &deleter) {
+			count, err = deleter.DelPrefix(prefix)
+		} else if as(adapter, &scanner) {
+			count, err = delScanned(adapter, scanner, prefix)
+		} else {
+			err = ErrUnsupported
+		}
 		if err != nil {
-			return err
+			return max, err
+		}
+
+		if count > max {
+			max = count
 		}
-		fmt.Println(key, "->", val, data)
+	}
 
-		var res int
-		err = msgpack.Unmarshal(data, &res)
+	c.publish(Invalidation{Prefixes: []string{prefix}})
+	return max, nil
+}
+
+// Clear removes every item from every adapter. Adapters that don't
+// implement Clearer delete every item with DelPrefix.
+func (c *Cache) Clear() error {
+	for _, adapter := range c.adapters {
+		var err error
+		var clearer Clearer
+		var deleter PrefixDeleter
+		var scanner Scanner
+		if as(adapter, &clearer) {
+			err = clearer.Clear()
+		} else if as(adapter, &deleter) {
+			_, err = deleter.DelPrefix("")
+		} else if as(adapter, &scanner) {
+			_, err = delScanned(adapter, scanner, "")
+		} else {
+			err = ErrUnsupported
+		}
 		if err != nil {
 			return err
 		}
-		fmt.Println("res", res)
 	}
+
+	c.publish(Invalidation{Prefixes: []string{""}})
 	return nil
 }
-func (b *Batch) Del(keys ...string) error {
-	return nil
+
+// Close closes every adapter that implements io.Closer, for
+// example to stop background goroutines. It first waits until
+// the pending invalidations are published (see SetInvalidator).
+func (c *Cache) Close() error {
+	if c.publisher != nil {
+		c.publisher.close()
+	}
+
+	var firstErr error
+	for _, adapter := range c.adapters {
+		if closer, ok := adapter.(io.Closer); ok {
+			err := closer.Close()
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
+func delScanned(adapter Adapter, scanner Scanner, prefix string) (int, error) {
+	var count int
+
+	opts := ScanOptions{Prefix: prefix, Limit: DefaultPageSize, IncludeExpired: true}
+	for {
+		keys, next, err := scanner.Scan(opts)
+		if err != nil {
+			return count, err
+		}
+		for _, key := range keys {
+			err := adapter.Del(key)
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if next == "" {
+			return count, nil
+		}
+		opts.Cursor = next
+	}
 }
-*/
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/vmihailenco/msgpack"
 
@@ -272,3 +273,297 @@ func TestDel_DelError(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+type adderMock struct {
+	*AdapterMock
+	AddFunc func(key string, data []byte) error
+}
+
+func (m *adderMock) Add(key string, data []byte) error {
+	return m.AddFunc(key, data)
+}
+
+func TestAdd_LastAdapter(t *testing.T) {
+	mock1 := &AdapterMock{
+		DelFunc: func(key string) error {
+			return nil
+		},
+	}
+	mock2 := &adderMock{
+		AdapterMock: &AdapterMock{},
+		AddFunc: func(key string, data []byte) error {
+			if key != "1" || data == nil {
+				t.Fail()
+			}
+			return nil
+		},
+	}
+
+	adapter1 := func() (cache.Adapter, error) {
+		return mock1, nil
+	}
+	adapter2 := func() (cache.Adapter, error) {
+		return mock2, nil
+	}
+	c, err := cache.New(adapter1, adapter2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = c.Add("1", "One")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(mock1.DelCalls()) != 1 {
+		t.Error("expected del1 to be called once")
+	}
+}
+func TestLast(t *testing.T) {
+	mock1 := &AdapterMock{}
+	mock2 := &AdapterMock{
+		SetFunc: func(key string, value []byte) error {
+			return nil
+		},
+	}
+
+	adapter1 := func() (cache.Adapter, error) {
+		return mock1, nil
+	}
+	adapter2 := func() (cache.Adapter, error) {
+		return mock2, nil
+	}
+	c, err := cache.New(adapter1, adapter2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = c.Last().Set("1", "One")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(mock1.SetCalls()) != 0 || len(mock2.SetCalls()) != 1 {
+		t.Error("expected only the last adapter to be set")
+	}
+}
+
+func TestAdd_AlreadyExists(t *testing.T) {
+	mock1 := &adderMock{
+		AdapterMock: &AdapterMock{},
+		AddFunc: func(key string, data []byte) error {
+			return cache.ErrAlreadyExists
+		},
+	}
+	adapter1 := func() (cache.Adapter, error) {
+		return mock1, nil
+	}
+	c, err := cache.New(adapter1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = c.Add("1", "One")
+	if err != cache.ErrAlreadyExists {
+		t.Error(err)
+	}
+}
+func TestAdd_Unsupported(t *testing.T) {
+	adapter1 := func() (cache.Adapter, error) {
+		return &AdapterMock{}, nil
+	}
+	c, err := cache.New(adapter1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = c.Add("1", "One")
+	if err != cache.ErrUnsupported {
+		t.Error(err)
+	}
+}
+
+type swapperMock struct {
+	*AdapterMock
+	CompareAndSwapFunc func(key string, old, new []byte) error
+}
+
+func (m *swapperMock) CompareAndSwap(key string, old, new []byte) error {
+	return m.CompareAndSwapFunc(key, old, new)
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	mock1 := &AdapterMock{
+		DelFunc: func(key string) error {
+			return nil
+		},
+	}
+	mock2 := &swapperMock{
+		AdapterMock: &AdapterMock{},
+		CompareAndSwapFunc: func(key string, old, new []byte) error {
+			var o, n string
+			msgpack.Unmarshal(old, &o)
+			msgpack.Unmarshal(new, &n)
+			if o != "One" || n != "Two" {
+				return cache.ErrConflict
+			}
+			return nil
+		},
+	}
+
+	adapter1 := func() (cache.Adapter, error) {
+		return mock1, nil
+	}
+	adapter2 := func() (cache.Adapter, error) {
+		return mock2, nil
+	}
+	c, err := cache.New(adapter1, adapter2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = c.CompareAndSwap("1", "Zero", "Two")
+	if err != cache.ErrConflict {
+		t.Error(err)
+	}
+	err = c.CompareAndSwap("1", "One", "Two")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(mock1.DelCalls()) != 1 {
+		t.Error("expected del1 to be called once")
+	}
+}
+
+type toucherMock struct {
+	*AdapterMock
+	TouchFunc func(key string, ttl time.Duration) error
+}
+
+func (m *toucherMock) Touch(key string, ttl time.Duration) error {
+	return m.TouchFunc(key, ttl)
+}
+
+func TestTouch_Everywhere(t *testing.T) {
+	var touched []string
+	mock1 := &toucherMock{
+		AdapterMock: &AdapterMock{},
+		TouchFunc: func(key string, ttl time.Duration) error {
+			touched = append(touched, "mock1")
+			return cache.ErrNotFound
+		},
+	}
+	mock2 := &toucherMock{
+		AdapterMock: &AdapterMock{},
+		TouchFunc: func(key string, ttl time.Duration) error {
+			touched = append(touched, "mock2")
+			return nil
+		},
+	}
+
+	adapter1 := func() (cache.Adapter, error) {
+		return mock1, nil
+	}
+	adapter2 := func() (cache.Adapter, error) {
+		return mock2, nil
+	}
+	c, err := cache.New(adapter1, adapter2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = c.Touch("1", time.Hour)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(touched) != 2 {
+		t.Error("expected both adapters to be touched")
+	}
+}
+func TestTouch_NotFound(t *testing.T) {
+	mock1 := &toucherMock{
+		AdapterMock: &AdapterMock{},
+		TouchFunc: func(key string, ttl time.Duration) error {
+			return cache.ErrNotFound
+		},
+	}
+	adapter1 := func() (cache.Adapter, error) {
+		return mock1, nil
+	}
+	adapter2 := func() (cache.Adapter, error) {
+		return &AdapterMock{}, nil
+	}
+	c, err := cache.New(adapter1, adapter2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = c.Touch("1", time.Hour)
+	if err != cache.ErrNotFound {
+		t.Error(err)
+	}
+}
+
+func TestGet_RenewOnRead(t *testing.T) {
+	var renewed time.Duration
+	mock1 := &toucherMock{
+		AdapterMock: &AdapterMock{
+			GetFunc: func(key string) ([]byte, error) {
+				return msgpack.Marshal("One")
+			},
+		},
+		TouchFunc: func(key string, ttl time.Duration) error {
+			renewed = ttl
+			return nil
+		},
+	}
+	adapter1 := func() (cache.Adapter, error) {
+		return mock1, nil
+	}
+	c, err := cache.NewWithOptions(
+		cache.WithAdapters(adapter1),
+		cache.WithRenewOnRead(time.Hour),
+	)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var target string
+	err = c.Get("1", &target)
+	if err != nil {
+		t.Error(err)
+	}
+	if renewed != time.Hour {
+		t.Error("expected the item to be renewed")
+	}
+}
+
+type closerMock struct {
+	*AdapterMock
+	closed bool
+}
+
+func (m *closerMock) Close() error {
+	m.closed = true
+	return nil
+}
+
+func TestClose(t *testing.T) {
+	mock1 := &closerMock{AdapterMock: &AdapterMock{}}
+	adapter1 := func() (cache.Adapter, error) {
+		return mock1, nil
+	}
+	adapter2 := func() (cache.Adapter, error) {
+		return &AdapterMock{}, nil
+	}
+	c, err := cache.New(adapter1, adapter2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = c.Close()
+	if err != nil {
+		t.Error(err)
+	}
+	if !mock1.closed {
+		t.Error("expected adapter to be closed")
+	}
+}
@@ -0,0 +1,36 @@
+package cachetest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a fake clock that only moves with Advance.
+type Clock struct {
+	now time.Time
+	m   sync.Mutex
+}
+
+// NewClock returns a clock that starts at the current time
+// (in whole seconds), so that adapters which save the time
+// in the backend don't see items from the distant past.
+func NewClock() *Clock {
+	return &Clock{now: time.Now().Truncate(time.Second)}
+}
+
+// Now returns the time of the clock. It is passed
+// to the adapters instead of time.Now.
+func (c *Clock) Now() time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return c.now
+}
+
+// Advance moves the clock forward.
+func (c *Clock) Advance(d time.Duration) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.now = c.now.Add(d)
+}
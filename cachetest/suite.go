@@ -0,0 +1,630 @@
+// Package cachetest checks that an adapter behaves like the
+// adapters of this repository, so that it can be used with
+// every feature of the cache:
+//
+//	func TestAdapter(t *testing.T) {
+//		cachetest.RunAdapterSuite(t, func(ttl time.Duration, now func() time.Time) cache.InitAdapter {
+//			return myadapter.New(ttl, myadapter.WithClock(now))
+//		})
+//	}
+//
+// The optional interfaces (see the cache package) are only
+// tested if the adapter implements them.
+package cachetest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// TTL is the ttl that is passed to the factory.
+const TTL = time.Minute
+
+// Factory returns a new and empty adapter whose items expire after
+// ttl. The adapter must get the time from now instead of time.Now,
+// so that the suite can move the clock forward.
+type Factory func(ttl time.Duration, now func() time.Time) cache.InitAdapter
+
+// RunAdapterSuite runs the tests for the adapter as subtests. Run it
+// with -race to also check the concurrent access.
+func RunAdapterSuite(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, a cache.Adapter, clock *Clock)
+	}{
+		{"NotFound", testNotFound},
+		{"SetGet", testSetGet},
+		{"Overwrite", testOverwrite},
+		{"Del", testDel},
+		{"DelMissing", testDelMissing},
+		{"Expired", testExpired},
+		{"Concurrent", testConcurrent},
+		{"Add", testAdd},
+		{"CompareAndSwap", testCompareAndSwap},
+		{"SetWithTTL", testSetWithTTL},
+		{"Touch", testTouch},
+		{"Batch", testBatch},
+		{"Incr", testIncr},
+		{"Scan", testScan},
+		{"DelPrefix", testDelPrefix},
+		{"Clear", testClear},
+		{"Metadata", testMetadata},
+		{"Context", testContext},
+		{"Dump", testDump},
+		{"Close", testClose},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			clock := NewClock()
+			a, err := factory(TTL, clock.Now)()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if closer, ok := a.(io.Closer); ok && test.name != "Close" {
+				defer closer.Close()
+			}
+
+			test.fn(t, a, clock)
+		})
+	}
+}
+
+// expire moves the clock past the ttl. The extra seconds
+// are for adapters that save the expiry in seconds.
+func expire(clock *Clock, ttl time.Duration) {
+	clock.Advance(ttl + 2*time.Second)
+}
+
+func isMiss(err error) bool {
+	return err == cache.ErrNotFound || err == cache.ErrExpired
+}
+
+func set(t *testing.T, a cache.Adapter, key, value string) {
+	t.Helper()
+
+	err := a.Set(key, []byte(value))
+	if err != nil {
+		t.Fatalf("set %q: %v", key, err)
+	}
+}
+
+func expectValue(t *testing.T, a cache.Adapter, key, expected string) {
+	t.Helper()
+
+	data, err := a.Get(key)
+	if err != nil {
+		t.Errorf("get %q: %v", key, err)
+		return
+	}
+	if string(data) != expected {
+		t.Errorf("get %q: expected '%s' but got '%s'", key, expected, data)
+	}
+}
+
+func expectMiss(t *testing.T, a cache.Adapter, key string) {
+	t.Helper()
+
+	data, err := a.Get(key)
+	if !isMiss(err) {
+		t.Errorf("get %q: expected ErrNotFound or ErrExpired but got '%s' and %v", key, data, err)
+	}
+}
+
+func testNotFound(t *testing.T, a cache.Adapter, clock *Clock) {
+	_, err := a.Get("missing")
+	if err != cache.ErrNotFound {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+}
+
+func testSetGet(t *testing.T, a cache.Adapter, clock *Clock) {
+	set(t, a, "key", "value")
+	expectValue(t, a, "key", "value")
+
+	binary := []byte{0, 0xc1, 0xff, '\n'}
+	err := a.Set("binary", binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := a.Get("binary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, binary) {
+		t.Errorf("expected %v but got %v", binary, data)
+	}
+
+	// keys are not interpreted by the adapter
+	set(t, a, "a:b#c/d e", "special")
+	expectValue(t, a, "a:b#c/d e", "special")
+	expectMiss(t, a, "a:b")
+}
+
+func testOverwrite(t *testing.T, a cache.Adapter, clock *Clock) {
+	set(t, a, "key", "one")
+	set(t, a, "key", "two")
+	expectValue(t, a, "key", "two")
+
+	// an overwritten item gets a new ttl
+	clock.Advance(TTL / 2)
+	set(t, a, "key", "three")
+	clock.Advance(TTL/2 + 2*time.Second)
+	expectValue(t, a, "key", "three")
+}
+
+func testDel(t *testing.T, a cache.Adapter, clock *Clock) {
+	set(t, a, "key", "value")
+	set(t, a, "other", "value")
+
+	err := a.Del("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectMiss(t, a, "key")
+	expectValue(t, a, "other", "value")
+}
+
+func testDelMissing(t *testing.T, a cache.Adapter, clock *Clock) {
+	err := a.Del("missing")
+	if err != nil {
+		t.Errorf("expected no error for a missing key but got %v", err)
+	}
+}
+
+func testExpired(t *testing.T, a cache.Adapter, clock *Clock) {
+	set(t, a, "key", "value")
+
+	clock.Advance(TTL - 2*time.Second)
+	expectValue(t, a, "key", "value")
+
+	expire(clock, 2*time.Second)
+	expectMiss(t, a, "key")
+
+	// an expired item can be set again
+	set(t, a, "key", "new")
+	expectValue(t, a, "key", "new")
+}
+
+func testConcurrent(t *testing.T, a cache.Adapter, clock *Clock) {
+	incrementer, _ := a.(cache.Incrementer)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+
+			for i := 0; i < 50; i++ {
+				key := fmt.Sprintf("key-%d", i%5)
+				err := a.Set(key, []byte(fmt.Sprintf("%d-%d", g, i)))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				_, err = a.Get(key)
+				if err != nil && !isMiss(err) {
+					t.Error(err)
+					return
+				}
+				if i%10 == 0 {
+					err = a.Del(key)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+				}
+
+				if incrementer != nil {
+					_, err = incrementer.Incr("counter", 1)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if incrementer != nil {
+		n, err := incrementer.Incr("counter", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 8*50 {
+			t.Errorf("expected the counter to be %d but got %d", 8*50, n)
+		}
+	}
+}
+
+func testAdd(t *testing.T, a cache.Adapter, clock *Clock) {
+	adder, ok := a.(cache.Adder)
+	if !ok {
+		t.Skip("adapter does not implement cache.Adder")
+	}
+
+	err := adder.Add("key", []byte("one"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adder.Add("key", []byte("two"))
+	if err != cache.ErrAlreadyExists {
+		t.Errorf("expected ErrAlreadyExists but got %v", err)
+	}
+	expectValue(t, a, "key", "one")
+
+	expire(clock, TTL)
+	err = adder.Add("key", []byte("three"))
+	if err != nil {
+		t.Errorf("expected an expired item to be replaced but got %v", err)
+	}
+	expectValue(t, a, "key", "three")
+}
+
+func testCompareAndSwap(t *testing.T, a cache.Adapter, clock *Clock) {
+	swapper, ok := a.(cache.Swapper)
+	if !ok {
+		t.Skip("adapter does not implement cache.Swapper")
+	}
+
+	err := swapper.CompareAndSwap("missing", []byte("old"), []byte("new"))
+	if err != cache.ErrConflict {
+		t.Errorf("expected ErrConflict for a missing key but got %v", err)
+	}
+
+	set(t, a, "key", "one")
+	err = swapper.CompareAndSwap("key", []byte("other"), []byte("two"))
+	if err != cache.ErrConflict {
+		t.Errorf("expected ErrConflict but got %v", err)
+	}
+	expectValue(t, a, "key", "one")
+
+	err = swapper.CompareAndSwap("key", []byte("one"), []byte("two"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, a, "key", "two")
+
+	expire(clock, TTL)
+	err = swapper.CompareAndSwap("key", []byte("two"), []byte("three"))
+	if err != cache.ErrConflict {
+		t.Errorf("expected ErrConflict for an expired item but got %v", err)
+	}
+}
+
+func testSetWithTTL(t *testing.T, a cache.Adapter, clock *Clock) {
+	setter, ok := a.(cache.TTLSetter)
+	if !ok {
+		t.Skip("adapter does not implement cache.TTLSetter")
+	}
+
+	err := setter.SetWithTTL("short", []byte("value"), 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = setter.SetWithTTL("long", []byte("value"), 3*TTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expire(clock, 10*time.Second)
+	expectMiss(t, a, "short")
+
+	expire(clock, TTL)
+	expectValue(t, a, "long", "value")
+}
+
+func testTouch(t *testing.T, a cache.Adapter, clock *Clock) {
+	toucher, ok := a.(cache.Toucher)
+	if !ok {
+		t.Skip("adapter does not implement cache.Toucher")
+	}
+
+	err := toucher.Touch("missing", TTL)
+	if err != cache.ErrNotFound {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+
+	set(t, a, "key", "value")
+	err = toucher.Touch("key", 3*TTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expire(clock, TTL)
+	expectValue(t, a, "key", "value")
+
+	expire(clock, 2*TTL)
+	err = toucher.Touch("key", TTL)
+	if err != cache.ErrNotFound {
+		t.Errorf("expected ErrNotFound for an expired item but got %v", err)
+	}
+}
+
+func testBatch(t *testing.T, a cache.Adapter, clock *Clock) {
+	getter, isGetter := a.(cache.BatchGetter)
+	setter, isSetter := a.(cache.BatchSetter)
+	deleter, isDeleter := a.(cache.BatchDeleter)
+	if !isGetter && !isSetter && !isDeleter {
+		t.Skip("adapter does not implement cache.BatchGetter, cache.BatchSetter or cache.BatchDeleter")
+	}
+
+	if isSetter {
+		err := setter.SetMulti(map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": []byte("3")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectValue(t, a, "a", "1")
+		expectValue(t, a, "c", "3")
+	} else {
+		set(t, a, "a", "1")
+		set(t, a, "b", "2")
+		set(t, a, "c", "3")
+	}
+
+	if isGetter {
+		set(t, a, "expired", "value")
+		expire(clock, TTL)
+		set(t, a, "a", "1")
+		set(t, a, "b", "2")
+
+		result, err := getter.GetMulti([]string{"a", "b", "c", "expired", "missing"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result) != 2 || string(result["a"]) != "1" || string(result["b"]) != "2" {
+			t.Errorf("expected a and b but got %q", result)
+		}
+	}
+
+	if isDeleter {
+		err := deleter.DelMulti([]string{"a", "b", "missing"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectMiss(t, a, "a")
+		expectMiss(t, a, "b")
+	}
+}
+
+func testIncr(t *testing.T, a cache.Adapter, clock *Clock) {
+	incrementer, ok := a.(cache.Incrementer)
+	if !ok {
+		t.Skip("adapter does not implement cache.Incrementer")
+	}
+
+	n, err := incrementer.Incr("counter", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Errorf("expected a new counter to start at 0 but got %d", n)
+	}
+	n, err = incrementer.Incr("counter", -7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != -2 {
+		t.Errorf("expected -2 but got %d", n)
+	}
+	expectValue(t, a, "counter", string(cache.EncodeCounter(-2)))
+
+	expire(clock, TTL)
+	n, err = incrementer.Incr("counter", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected an expired counter to start at 0 but got %d", n)
+	}
+
+	set(t, a, "text", "value")
+	_, err = incrementer.Incr("text", 1)
+	if err != cache.ErrNotCounter {
+		t.Errorf("expected ErrNotCounter but got %v", err)
+	}
+}
+
+// scanAll returns every key of every page.
+func scanAll(t *testing.T, scanner cache.Scanner, opts cache.ScanOptions) []string {
+	t.Helper()
+
+	var all []string
+	for page := 0; ; page++ {
+		if page > 100 {
+			t.Fatal("expected the scan to end")
+		}
+
+		keys, next, err := scanner.Scan(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, keys...)
+
+		if next == "" {
+			sort.Strings(all)
+			return all
+		}
+		opts.Cursor = next
+	}
+}
+
+func testScan(t *testing.T, a cache.Adapter, clock *Clock) {
+	scanner, ok := a.(cache.Scanner)
+	if !ok {
+		t.Skip("adapter does not implement cache.Scanner")
+	}
+
+	set(t, a, "user:expired", "value")
+	expire(clock, TTL)
+	set(t, a, "user:1", "value")
+	set(t, a, "user:2", "value")
+	set(t, a, "user:3", "value")
+	set(t, a, "session:1", "value")
+
+	keys := scanAll(t, scanner, cache.ScanOptions{Prefix: "user:", Limit: 1})
+	if strings.Join(keys, ",") != "user:1,user:2,user:3" {
+		t.Errorf("expected the keys of the users but got %v", keys)
+	}
+
+	keys = scanAll(t, scanner, cache.ScanOptions{Prefix: "user:", IncludeExpired: true})
+	if len(keys) != 4 {
+		t.Errorf("expected the expired key to be included but got %v", keys)
+	}
+
+	keys = scanAll(t, scanner, cache.ScanOptions{})
+	if len(keys) != 4 {
+		t.Errorf("expected every key that is not expired but got %v", keys)
+	}
+}
+
+func testDelPrefix(t *testing.T, a cache.Adapter, clock *Clock) {
+	deleter, ok := a.(cache.PrefixDeleter)
+	if !ok {
+		t.Skip("adapter does not implement cache.PrefixDeleter")
+	}
+
+	set(t, a, "user:1", "value")
+	set(t, a, "user:2", "value")
+	set(t, a, "session:1", "value")
+
+	count, err := deleter.DelPrefix("user:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 deleted items but got %d", count)
+	}
+	expectMiss(t, a, "user:1")
+	expectMiss(t, a, "user:2")
+	expectValue(t, a, "session:1", "value")
+}
+
+func testClear(t *testing.T, a cache.Adapter, clock *Clock) {
+	clearer, ok := a.(cache.Clearer)
+	if !ok {
+		t.Skip("adapter does not implement cache.Clearer")
+	}
+
+	set(t, a, "a", "value")
+	set(t, a, "b", "value")
+
+	err := clearer.Clear()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectMiss(t, a, "a")
+	expectMiss(t, a, "b")
+
+	set(t, a, "a", "new")
+	expectValue(t, a, "a", "new")
+}
+
+func within(actual, expected time.Time) bool {
+	diff := actual.Sub(expected)
+	return diff > -2*time.Second && diff < 2*time.Second
+}
+
+func testMetadata(t *testing.T, a cache.Adapter, clock *Clock) {
+	getter, ok := a.(cache.MetadataGetter)
+	if !ok {
+		t.Skip("adapter does not implement cache.MetadataGetter")
+	}
+
+	_, _, err := getter.GetWithMetadata("missing")
+	if err != cache.ErrNotFound {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+
+	created := clock.Now()
+	set(t, a, "key", "value")
+	clock.Advance(10 * time.Second)
+
+	data, meta, err := getter.GetWithMetadata("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "value" {
+		t.Errorf("expected 'value' but got '%s'", data)
+	}
+	if !meta.Created.IsZero() && !within(meta.Created, created) {
+		t.Errorf("expected the item to be created at %v but got %v", created, meta.Created)
+	}
+	if !meta.Expires.IsZero() && !within(meta.Expires, created.Add(TTL)) {
+		t.Errorf("expected the item to expire at %v but got %v", created.Add(TTL), meta.Expires)
+	}
+}
+
+func testContext(t *testing.T, a cache.Adapter, clock *Clock) {
+	getter, ok := a.(cache.ContextGetter)
+	if !ok {
+		t.Skip("adapter does not implement cache.ContextGetter")
+	}
+
+	set(t, a, "key", "value")
+	data, err := getter.GetWithContext(context.Background(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "value" {
+		t.Errorf("expected 'value' but got '%s'", data)
+	}
+
+	_, err = getter.GetWithContext(context.Background(), "missing")
+	if err != cache.ErrNotFound {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+}
+
+func testDump(t *testing.T, a cache.Adapter, clock *Clock) {
+	dumper, ok := a.(cache.Dumper)
+	if !ok {
+		t.Skip("adapter does not implement cache.Dumper")
+	}
+
+	set(t, a, "expired", "value")
+	expire(clock, TTL)
+	set(t, a, "a", "1")
+	set(t, a, "b", "2")
+
+	records := make(map[string]cache.Record)
+	err := dumper.Dump(func(rec cache.Record) error {
+		records[rec.Key] = rec
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || string(records["a"].Data) != "1" || string(records["b"].Data) != "2" {
+		t.Errorf("expected a and b but got %v", records)
+	}
+	if rec := records["a"]; !rec.Expires.IsZero() && !within(rec.Expires, clock.Now().Add(TTL)) {
+		t.Errorf("expected the item to expire at %v but got %v", clock.Now().Add(TTL), rec.Expires)
+	}
+}
+
+func testClose(t *testing.T, a cache.Adapter, clock *Clock) {
+	closer, ok := a.(io.Closer)
+	if !ok {
+		t.Skip("adapter does not implement io.Closer")
+	}
+
+	err := closer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = closer.Close()
+	if err != nil {
+		t.Errorf("expected a second Close to be a no-op but got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"reflect"
+	"time"
+)
+
+// Adapters only need Get, Set and Del. Everything else is an optional
+// interface that is detected when it is used, so that new capabilities
+// don't break existing adapters:
+//
+//	Adder, Swapper         conditional set (Add, CompareAndSwap)
+//	TTLSetter              set with a ttl (SetWithTTL, Import)
+//	Toucher                extend the expiry (Touch, WithRenewOnRead)
+//	BatchGetter            GetMulti
+//	BatchSetter            SetMulti
+//	BatchDeleter           DelMulti
+//	Incrementer            Incr
+//	Scanner                list keys (Iterate, Keys, DelPrefix)
+//	PrefixDeleter          DelPrefix
+//	Clearer                Clear
+//	io.Closer              Close
+//	MetadataGetter         GetWithMetadata
+//	ContextGetter          GetContext
+//	ContextMetadataGetter  cancel GetWithMetadata (Parallel, Hedged)
+//	Dumper                 Export
+//	Namer                  ItemInfo.TierName
+//
+// If an adapter is missing a capability the cache emulates it with the
+// other methods where that is possible, for example GetMulti with one
+// Get per key. Otherwise ErrUnsupported is returned.
+//
+// Adapters that have every method but only support some of the
+// optional interfaces, for example because they wrap another adapter,
+// implement Supporter.
+
+// Supporter is implemented by adapters that have the methods of every
+// optional interface, but only support some of them, for example
+// faultadapter, which only supports those of the adapter it wraps.
+// The cache only uses the optional interfaces for which Supports
+// returns true. io.Closer, ContextGetter, ContextMetadataGetter and
+// Namer are not checked.
+type Supporter interface {
+	// Supports gets a pointer to the optional interface,
+	// for example (*cache.Toucher)(nil).
+	Supports(capability interface{}) bool
+}
+
+// Implements reports whether the adapter implements the optional
+// interface, which is given as a pointer like (*Toucher)(nil).
+// A Supporter also needs to support it, so a wrapper can use
+// Implements to check the adapter that it wraps.
+func Implements(adapter Adapter, capability interface{}) bool {
+	if adapter == nil || !reflect.TypeOf(adapter).Implements(reflect.TypeOf(capability).Elem()) {
+		return false
+	}
+	supporter, ok := adapter.(Supporter)
+	return !ok || supporter.Supports(capability)
+}
+
+// as sets target, which is a pointer to an optional interface,
+// to the adapter if the adapter Implements that interface.
+func as(adapter Adapter, target interface{}) bool {
+	if !Implements(adapter, target) {
+		return false
+	}
+	reflect.ValueOf(target).Elem().Set(reflect.ValueOf(adapter))
+	return true
+}
+
+// Adder is implemented by adapters that can atomically set a value
+// only if the key does not exist yet (or the existing item is expired).
+// If the item already exists ErrAlreadyExists is returned.
+type Adder interface {
+	Add(key string, value []byte) error
+}
+
+// Swapper is implemented by adapters that can atomically replace
+// the value of an item, but only if it still is old. If the item
+// changed, expired or does not exist ErrConflict is returned.
+type Swapper interface {
+	CompareAndSwap(key string, old, new []byte) error
+}
+
+// TTLSetter is implemented by adapters that can set an item
+// with a different ttl than their default.
+type TTLSetter interface {
+	SetWithTTL(key string, value []byte, ttl time.Duration) error
+}
+
+// Toucher is implemented by adapters that can extend the expiry
+// of an item without writing the value again. If the item does
+// not exist or is expired ErrNotFound is returned.
+type Toucher interface {
+	Touch(key string, ttl time.Duration) error
+}
+
+// BatchGetter is implemented by adapters that can get several
+// items at once. Keys that don't exist or are expired are
+// missing from the result.
+type BatchGetter interface {
+	GetMulti(keys []string) (map[string][]byte, error)
+}
+
+// BatchSetter is implemented by adapters that can set several items at once.
+type BatchSetter interface {
+	SetMulti(items map[string][]byte) error
+}
+
+// BatchDeleter is implemented by adapters that can delete several items at once.
+type BatchDeleter interface {
+	DelMulti(keys []string) error
+}
+
+// Incrementer is implemented by adapters that can atomically add
+// delta to a counter and return the new value. The counter is stored
+// with EncodeCounter and a missing or expired counter starts at 0.
+// If the item is not a counter ErrNotCounter is returned.
+type Incrementer interface {
+	Incr(key string, delta int64) (int64, error)
+}
+
+// PrefixDeleter is implemented by adapters that can delete every
+// item whose key starts with prefix. It returns the number of
+// deleted items.
+type PrefixDeleter interface {
+	DelPrefix(prefix string) (int, error)
+}
+
+// Clearer is implemented by adapters that can remove every item.
+type Clearer interface {
+	Clear() error
+}
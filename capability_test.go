@@ -0,0 +1,248 @@
+package cache_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/JohannesKaufmann/dynamodb-cache/memadapter"
+)
+
+// newPlainAdapter returns an adapter with only Get, Set and Del,
+// so that the cache has to emulate everything else.
+func newPlainAdapter() (*AdapterMock, map[string][]byte) {
+	var m sync.Mutex
+	values := make(map[string][]byte)
+
+	return &AdapterMock{
+		GetFunc: func(key string) ([]byte, error) {
+			m.Lock()
+			defer m.Unlock()
+			data, ok := values[key]
+			if !ok {
+				return nil, cache.ErrNotFound
+			}
+			return data, nil
+		},
+		SetFunc: func(key string, data []byte) error {
+			m.Lock()
+			defer m.Unlock()
+			values[key] = data
+			return nil
+		},
+		DelFunc: func(key string) error {
+			m.Lock()
+			defer m.Unlock()
+			delete(values, key)
+			return nil
+		},
+	}, values
+}
+
+func TestMulti(t *testing.T) {
+	plain, values := newPlainAdapter()
+	c, err := cache.New(
+		memadapter.New(time.Hour, false),
+		func() (cache.Adapter, error) { return plain, nil },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.SetMulti(map[string]interface{}{"a": 1, "b": 2, "c": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 3 {
+		t.Errorf("expected the plain adapter to have 3 items but got %d", len(values))
+	}
+
+	// "d" is only in the second adapter
+	err = c.Evict("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("d", 4)
+	c.Evict("d")
+
+	var result map[string]int
+	err = c.GetMulti([]string{"a", "b", "d", "missing"}, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 3 || result["a"] != 1 || result["b"] != 2 || result["d"] != 4 {
+		t.Errorf("got wrong result %v", result)
+	}
+	if len(plain.GetCalls()) != 3 {
+		t.Errorf("expected the plain adapter to be asked for 3 keys but got %d", len(plain.GetCalls()))
+	}
+
+	err = c.DelMulti("a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result = nil
+	err = c.GetMulti([]string{"a", "b", "c"}, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || result["c"] != 3 {
+		t.Errorf("got wrong result %v", result)
+	}
+
+	var wrong []int
+	err = c.GetMulti([]string{"a"}, &wrong)
+	if err == nil {
+		t.Error("expected an error for a slice as target")
+	}
+}
+
+func TestSetWithTTL(t *testing.T) {
+	c, err := cache.New(memadapter.New(time.Hour, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.SetWithTTL("key", "value", time.Millisecond*10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 20)
+
+	var value string
+	err = c.Get("key", &value)
+	if err != cache.ErrExpired {
+		t.Errorf("expected ErrExpired but got %v", err)
+	}
+}
+
+func TestSetWithTTL_Unsupported(t *testing.T) {
+	plain, values := newPlainAdapter()
+	c, err := cache.New(
+		memadapter.New(time.Hour, false),
+		func() (cache.Adapter, error) { return plain, nil },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.SetWithTTL("key", "value", time.Minute)
+	if err != cache.ErrUnsupported {
+		t.Errorf("expected ErrUnsupported but got %v", err)
+	}
+	if len(values) != 0 {
+		t.Error("expected nothing to be written")
+	}
+}
+
+// adderSwapper only has Add and CompareAndSwap,
+// so Incr has to be emulated.
+type adderSwapper struct {
+	cache.Adapter
+	cache.Adder
+	cache.Swapper
+}
+
+func TestIncr(t *testing.T) {
+	tests := []struct {
+		name string
+		last func() cache.InitAdapter
+	}{
+		{"Incrementer", func() cache.InitAdapter {
+			return memadapter.New(time.Hour, false)
+		}},
+		{"Emulated", func() cache.InitAdapter {
+			return func() (cache.Adapter, error) {
+				mem, err := memadapter.New(time.Hour, false)()
+				if err != nil {
+					return nil, err
+				}
+				return adderSwapper{mem, mem.(cache.Adder), mem.(cache.Swapper)}, nil
+			}
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := cache.New(memadapter.New(time.Hour, false), test.last())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := c.Incr("counter", 2)
+					if err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			n, err := c.Incr("counter", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 40 {
+				t.Errorf("expected 40 but got %d", n)
+			}
+
+			c.Set("value", "text")
+			_, err = c.Incr("value", 1)
+			if err != cache.ErrNotCounter {
+				t.Errorf("expected ErrNotCounter but got %v", err)
+			}
+		})
+	}
+}
+
+func TestIncr_Get(t *testing.T) {
+	c, err := cache.New(memadapter.New(time.Hour, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, delta := range []int64{5, 7} {
+		_, err = c.Incr("n", delta)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the digits must not be decoded as msgpack fixints
+		var n int
+		err = c.Get("n", &n)
+		if err != cache.ErrNotCounter {
+			t.Errorf("expected ErrNotCounter but got %d, %v", n, err)
+		}
+		_, err = c.GetBytes("n")
+		if err != cache.ErrNotCounter {
+			t.Errorf("expected ErrNotCounter from GetBytes but got %v", err)
+		}
+	}
+
+	n, err := cache.DecodeCounter(cache.EncodeCounter(12))
+	if err != nil || n != 12 {
+		t.Errorf("expected 12 but got %d, %v", n, err)
+	}
+	_, err = cache.DecodeCounter([]byte("12"))
+	if err != cache.ErrNotCounter {
+		t.Errorf("expected ErrNotCounter for bare digits but got %v", err)
+	}
+}
+
+func TestIncr_Unsupported(t *testing.T) {
+	plain, _ := newPlainAdapter()
+	c, err := cache.New(func() (cache.Adapter, error) { return plain, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Incr("counter", 1)
+	if err != cache.ErrUnsupported {
+		t.Errorf("expected ErrUnsupported but got %v", err)
+	}
+}
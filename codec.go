@@ -0,0 +1,24 @@
+package cache
+
+import "github.com/vmihailenco/msgpack"
+
+// Codec encodes the values of Get and Set. The encoded data must
+// not start with the byte 0xc1, which marks the data of SetBytes.
+// Codecs that implement Namer are shown by name in ItemInfo.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// MsgpackCodec is the default codec.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+func (MsgpackCodec) Name() string {
+	return "msgpack"
+}
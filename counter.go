@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+)
+
+// MaxIncrAttempts is how often Incr retries the CompareAndSwap
+// of an adapter that doesn't implement Incrementer before
+// ErrConflict is returned.
+var MaxIncrAttempts = 100
+
+// counterPrefix is put in front of the decimal number of a counter.
+// It starts with the rawMarker, so the codec never reads a counter,
+// and the rest tells it apart from the data of SetBytes.
+var counterPrefix = []byte("\xc1\x00counter\x00")
+
+func isCounter(data []byte) bool {
+	return bytes.HasPrefix(data, counterPrefix)
+}
+
+// EncodeCounter returns the data of a counter with the value n.
+// Adapters that implement Incrementer store their counters like this.
+func EncodeCounter(n int64) []byte {
+	return strconv.AppendInt(append([]byte{}, counterPrefix...), n, 10)
+}
+
+// DecodeCounter returns the value of the data of a counter. If
+// the data was not written by EncodeCounter ErrNotCounter is returned.
+func DecodeCounter(data []byte) (int64, error) {
+	if !isCounter(data) {
+		return 0, ErrNotCounter
+	}
+	n, err := strconv.ParseInt(string(data[len(counterPrefix):]), 10, 64)
+	if err != nil {
+		return 0, ErrNotCounter
+	}
+	return n, nil
+}
+
+// Incr atomically adds delta to the counter and returns the new value.
+// A missing or expired counter starts at 0, so Incr(key, 0) reads it.
+// The counter is stored with EncodeCounter and not with the codec,
+// so Get and GetBytes return ErrNotCounter for it.
+//
+// Like Add it only runs against the last adapter and removes the item
+// from the adapters in front of it. If the last adapter does not
+// implement Incrementer the counter is changed with Add and
+// CompareAndSwap, and ErrUnsupported is returned if it can't do that either.
+func (c *Cache) Incr(key string, delta int64) (int64, error) {
+	key = c.key(key)
+	start := time.Now()
+	n, err := c.incr(key, delta)
+	return n, c.observe("incr", key, start, err)
+}
+
+func (c *Cache) incr(key string, delta int64) (int64, error) {
+	last := c.adapters[len(c.adapters)-1]
+
+	var n int64
+	var err error
+	var incrementer Incrementer
+	if as(last, &incrementer) {
+		n, err = incrementer.Incr(key, delta)
+	} else {
+		n, err = emulateIncr(last, key, delta)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	err = c.delUpper(key)
+	if err != nil {
+		return 0, err
+	}
+	c.publish(Invalidation{Keys: []string{key}})
+	return n, nil
+}
+
+// emulateIncr reads the counter and writes it back with Add or
+// CompareAndSwap. If it was changed concurrently it starts again.
+func emulateIncr(adapter Adapter, key string, delta int64) (int64, error) {
+	var adder Adder
+	var swapper Swapper
+	if !as(adapter, &adder) || !as(adapter, &swapper) {
+		return 0, ErrUnsupported
+	}
+
+	for attempt := 0; attempt < MaxIncrAttempts; attempt++ {
+		old, err := adapter.Get(key)
+		if err != nil && !isMiss(err) {
+			return 0, err
+		}
+		found := err == nil
+
+		var n int64
+		if found {
+			n, err = DecodeCounter(old)
+			if err != nil {
+				return 0, err
+			}
+		}
+		n += delta
+		data := EncodeCounter(n)
+
+		if found {
+			err = swapper.CompareAndSwap(key, old, data)
+		} else {
+			err = adder.Add(key, data)
+		}
+		if err == ErrConflict || err == ErrAlreadyExists {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+
+	return 0, ErrConflict
+}
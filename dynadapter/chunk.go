@@ -0,0 +1,256 @@
+package dynadapter
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// DefaultChunkSize is the size of the data in one chunk. It leaves
+// room for the other attributes below the item limit of 400KB.
+const DefaultChunkSize = 350 * 1024
+
+// batchGetSize is the maximum number of keys in a BatchGetItem
+// and batchGetBytes the maximum size of its response.
+const (
+	batchGetSize  = 100
+	batchGetBytes = 16 * 1024 * 1024
+)
+
+// ErrChecksum is returned if the chunks of a value
+// don't add up to the value that was set.
+var ErrChecksum = errors.New("dynamodb: checksum of chunked value does not match")
+
+// errMissingPart means that the value was replaced while it was read.
+var errMissingPart = errors.New("dynamodb: chunk or object is missing")
+
+// WithChunkSize changes the size at which values are split into
+// chunks. It must stay the same for all instances that share
+// the table, so that CompareAndSwap compares the same way.
+func WithChunkSize(bytes int) Option {
+	return func(a *Adapter) {
+		a.chunkSize = bytes
+	}
+}
+
+// Values that are larger than the chunk size are split into
+// several items with the keys "key#<version>#0", "key#<version>#1"
+// and so on. The item with the key itself is the manifest, which
+// holds the version, the number of chunks and a checksum.
+//
+// The chunks are always written before the manifest and every write
+// uses a new version, so the manifest only ever points to complete
+// chunks and readers never see a half-written value. The chunks of
+// the replaced manifest are deleted afterwards.
+
+func chunkKey(key, version string, n int) string {
+	return key + "#" + version + "#" + strconv.Itoa(n)
+}
+
+func newVersion() (string, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// large returns true if a value of that size is not saved in
+// the item itself but in chunks or in the object store.
+func (a *Adapter) large(size int) bool {
+	return size > a.chunkSize || (a.objects != nil && size > a.objectSize)
+}
+
+// split writes the chunks (or the object) of a large item and returns
+// the manifest, which still needs to be put. Small items are returned
+// unchanged.
+func (a *Adapter) split(i item) (item, error) {
+	if !a.large(len(i.Data)) {
+		return i, nil
+	}
+
+	version, err := newVersion()
+	if err != nil {
+		return item{}, err
+	}
+	if a.objects != nil && len(i.Data) > a.objectSize {
+		return a.offload(i, version)
+	}
+
+	var requests []*dynamodb.WriteRequest
+	var n int
+	for data := i.Data; len(data) > 0; n++ {
+		size := a.chunkSize
+		if len(data) < size {
+			size = len(data)
+		}
+
+		chunk := item{Key: chunkKey(i.Key, version, n), TTL: i.TTL, Data: data[:size], Chunk: true}
+		av, err := chunk.marshal()
+		if err != nil {
+			return item{}, err
+		}
+		requests = append(requests, &dynamodb.WriteRequest{
+			PutRequest: &dynamodb.PutRequest{Item: av},
+		})
+		data = data[size:]
+	}
+
+	err = a.batchWrite(requests)
+	if err != nil {
+		return item{}, err
+	}
+
+	manifest := i
+	manifest.Data = nil
+	manifest.Chunks = n
+	manifest.Version = version
+	manifest.Checksum = checksum(i.Data)
+	return manifest, nil
+}
+
+// join reads the chunks (or the object) of the manifest
+// and checks the checksum. A batch only has as many chunks
+// as fit in the response of one BatchGetItem.
+func (a *Adapter) join(manifest item) ([]byte, error) {
+	if manifest.Object != "" {
+		return a.fetch(manifest)
+	}
+
+	keys := make([]map[string]*dynamodb.AttributeValue, manifest.Chunks)
+	for n := range keys {
+		keys[n] = map[string]*dynamodb.AttributeValue{
+			"Key": {S: aws.String(chunkKey(manifest.Key, manifest.Version, n))},
+		}
+	}
+
+	chunks := make(map[string][]byte, manifest.Chunks)
+	for len(keys) > 0 {
+		size := a.batchGetSize()
+		if len(keys) < size {
+			size = len(keys)
+		}
+		batch := keys[:size]
+		keys = keys[size:]
+
+		err := a.batchGet(batch, func(chunk item) {
+			chunks[chunk.Key] = chunk.Data
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var data []byte
+	for n := 0; n < manifest.Chunks; n++ {
+		chunk, ok := chunks[chunkKey(manifest.Key, manifest.Version, n)]
+		if !ok {
+			return nil, errMissingPart
+		}
+		data = append(data, chunk...)
+	}
+
+	if checksum(data) != manifest.Checksum {
+		return nil, ErrChecksum
+	}
+	return data, nil
+}
+
+// batchGetSize returns the number of chunks whose
+// data fits in the response of one BatchGetItem.
+func (a *Adapter) batchGetSize() int {
+	// leave room for the key and the other attributes
+	size := batchGetBytes / (a.chunkSize + 1024)
+	if size > batchGetSize {
+		size = batchGetSize
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// batchGet reads the keys with BatchGetItem and calls fn for every
+// item. Unprocessed keys, which are returned if the response got too
+// large, are requested again right away. Only if DynamoDB made no
+// progress because the table is throttled it backs off, up to
+// MaxRetries times.
+func (a *Adapter) batchGet(batch []map[string]*dynamodb.AttributeValue, fn func(i item)) error {
+	for n := 1; len(batch) > 0; {
+		result, err := a.client.BatchGetItem(&dynamodb.BatchGetItemInput{
+			RequestItems: map[string]*dynamodb.KeysAndAttributes{
+				a.table: {Keys: batch, ConsistentRead: aws.Bool(true)},
+			},
+		})
+		if isThrottled(err) {
+			if !retry(n) {
+				return ErrUnprocessed
+			}
+			n++
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		responses := result.Responses[a.table]
+		for _, attributes := range responses {
+			var i item
+			err = i.unmarshal(attributes)
+			if err != nil {
+				return err
+			}
+			fn(i)
+		}
+
+		var unprocessed []map[string]*dynamodb.AttributeValue
+		if keys := result.UnprocessedKeys[a.table]; keys != nil {
+			unprocessed = keys.Keys
+		}
+		if len(unprocessed) > 0 && len(responses) == 0 {
+			// nothing was read, so this is throttling as well
+			if !retry(n) {
+				return ErrUnprocessed
+			}
+			n++
+		}
+		batch = unprocessed
+	}
+	return nil
+}
+
+// delParts deletes the chunks (or the object) of a manifest.
+// It does nothing for items that are not split.
+func (a *Adapter) delParts(manifest item) error {
+	if manifest.Object != "" && a.objects != nil {
+		return a.objects.DeleteObject(manifest.Object)
+	}
+	if manifest.Chunks == 0 {
+		return nil
+	}
+
+	keys := make([]string, manifest.Chunks)
+	for n := range keys {
+		keys[n] = chunkKey(manifest.Key, manifest.Version, n)
+	}
+	return a.delBatch(keys)
+}
+
+// replaced deletes the chunks (or the object) of the item that
+// was replaced. The new value is already saved, so an error is
+// ignored and the chunks are left for the TTL of the table.
+func (a *Adapter) replaced(old, manifest item) {
+	if old.Version != manifest.Version {
+		a.delParts(old)
+	}
+}
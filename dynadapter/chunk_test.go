@@ -0,0 +1,342 @@
+package dynadapter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// newTableMock returns a mock that saves the items in the map
+// and ignores condition expressions.
+func newTableMock(items map[string]map[string]*dynamodb.AttributeValue) *mockDynamoDBClient {
+	key := func(attributes map[string]*dynamodb.AttributeValue) string {
+		return *attributes["Key"].S
+	}
+
+	return &mockDynamoDBClient{
+		GetItemFunc: func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: items[key(input.Key)]}, nil
+		},
+		PutItemFunc: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			old := items[key(input.Item)]
+			items[key(input.Item)] = input.Item
+			return &dynamodb.PutItemOutput{Attributes: old}, nil
+		},
+		DeleteItemFunc: func(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+			old := items[key(input.Key)]
+			delete(items, key(input.Key))
+			return &dynamodb.DeleteItemOutput{Attributes: old}, nil
+		},
+		BatchWriteItemFunc: func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			for _, req := range input.RequestItems["TestCache"] {
+				if req.PutRequest != nil {
+					items[key(req.PutRequest.Item)] = req.PutRequest.Item
+				} else {
+					delete(items, key(req.DeleteRequest.Key))
+				}
+			}
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+		BatchGetItemFunc: func(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+			var found []map[string]*dynamodb.AttributeValue
+			for _, k := range input.RequestItems["TestCache"].Keys {
+				if item, ok := items[key(k)]; ok {
+					found = append(found, item)
+				}
+			}
+			return &dynamodb.BatchGetItemOutput{
+				Responses: map[string][]map[string]*dynamodb.AttributeValue{"TestCache": found},
+			}, nil
+		},
+	}
+}
+
+func countChunks(items map[string]map[string]*dynamodb.AttributeValue) int {
+	var n int
+	for _, item := range items {
+		if item["Chunk"] != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func TestChunks_SetGet(t *testing.T) {
+	items := make(map[string]map[string]*dynamodb.AttributeValue)
+	a, err := New(newTableMock(items), "TestCache", time.Hour, WithChunkSize(4))()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = a.Set("small", []byte("abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if countChunks(items) != 0 {
+		t.Error("expected a small value not to be split")
+	}
+
+	err = a.Set("large", []byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if countChunks(items) != 3 {
+		t.Errorf("expected 3 chunks but got %d", countChunks(items))
+	}
+	if items["large"]["Data"] != nil || *items["large"]["Chunks"].N != "3" {
+		t.Error("expected a manifest without data")
+	}
+
+	data, err := a.Get("large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "0123456789" {
+		t.Errorf("got wrong data '%s'", data)
+	}
+
+	// the chunks of the replaced value are deleted
+	err = a.Set("large", []byte("abcdefgh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if countChunks(items) != 2 {
+		t.Errorf("expected 2 chunks but got %d", countChunks(items))
+	}
+
+	err = a.Del("large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if countChunks(items) != 0 {
+		t.Errorf("expected the chunks to be deleted but got %d", countChunks(items))
+	}
+}
+
+func TestChunks_Checksum(t *testing.T) {
+	items := make(map[string]map[string]*dynamodb.AttributeValue)
+	a, err := New(newTableMock(items), "TestCache", time.Hour, WithChunkSize(4))()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = a.Set("large", []byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, item := range items {
+		if strings.HasSuffix(key, "#1") {
+			item["Data"] = &dynamodb.AttributeValue{B: []byte("xxxx")}
+		}
+	}
+
+	_, err = a.Get("large")
+	if err != ErrChecksum {
+		t.Errorf("expected ErrChecksum but got %v", err)
+	}
+}
+
+func TestChunks_Missing(t *testing.T) {
+	items := make(map[string]map[string]*dynamodb.AttributeValue)
+	a, err := New(newTableMock(items), "TestCache", time.Hour, WithChunkSize(4))()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = a.Set("large", []byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key := range items {
+		if strings.HasSuffix(key, "#2") {
+			delete(items, key)
+		}
+	}
+
+	_, err = a.Get("large")
+	if err != cache.ErrNotFound {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+}
+
+func TestChunks_PutError(t *testing.T) {
+	items := make(map[string]map[string]*dynamodb.AttributeValue)
+	mock := newTableMock(items)
+	e := errors.New("put failed")
+	mock.PutItemFunc = func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+		return nil, e
+	}
+	a, err := New(mock, "TestCache", time.Hour, WithChunkSize(4))()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = a.Set("large", []byte("0123456789"))
+	if err != e {
+		t.Errorf("expected put error but got %v", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("expected the chunks to be deleted but got %d items", len(items))
+	}
+}
+
+func TestChunks_CompareAndSwap(t *testing.T) {
+	items := make(map[string]map[string]*dynamodb.AttributeValue)
+	mock := newTableMock(items)
+	put := mock.PutItemFunc
+	mock.PutItemFunc = func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+		if input.ConditionExpression != nil {
+			if !strings.HasPrefix(*input.ConditionExpression, "#s = :old") {
+				t.Errorf("expected the checksum to be compared: %s", *input.ConditionExpression)
+			}
+			if *input.ExpressionAttributeValues[":old"].S != checksum([]byte("0123456789")) {
+				t.Error("got wrong checksum")
+			}
+		}
+		return put(input)
+	}
+	a, err := New(mock, "TestCache", time.Hour, WithChunkSize(4))()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = a.Set("large", []byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.(cache.Swapper).CompareAndSwap("large", []byte("0123456789"), []byte("abcdefghij"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := a.Get("large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "abcdefghij" {
+		t.Errorf("got wrong data '%s'", data)
+	}
+	if countChunks(items) != 3 {
+		t.Errorf("expected 3 chunks but got %d", countChunks(items))
+	}
+	if aws.StringValue(items["large"]["Checksum"].S) != checksum([]byte("abcdefghij")) {
+		t.Error("expected the checksum of the new value")
+	}
+}
+
+func TestChunks_BatchGetSize(t *testing.T) {
+	tests := []struct {
+		chunkSize int
+		expected  int
+	}{
+		{4, batchGetSize},
+		{DefaultChunkSize, 46},
+		{1024 * 1024, 15},
+		{32 * 1024 * 1024, 1},
+	}
+	for _, test := range tests {
+		a := &Adapter{chunkSize: test.chunkSize}
+		if size := a.batchGetSize(); size != test.expected {
+			t.Errorf("chunk size %d: expected %d keys per batch but got %d", test.chunkSize, test.expected, size)
+		}
+	}
+}
+
+func TestChunks_Unprocessed(t *testing.T) {
+	defer func(backoff time.Duration) { RetryBackoff = backoff }(RetryBackoff)
+	RetryBackoff = time.Hour
+
+	items := make(map[string]map[string]*dynamodb.AttributeValue)
+	mock := newTableMock(items)
+	a, err := New(mock, "TestCache", time.Hour, WithChunkSize(1))()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.Set("large", []byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only one key is processed per call, like for a response
+	// that reached the size limit
+	get := mock.BatchGetItemFunc
+	var calls int
+	mock.BatchGetItemFunc = func(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+		calls++
+		keys := input.RequestItems["TestCache"].Keys
+		output, err := get(&dynamodb.BatchGetItemInput{
+			RequestItems: map[string]*dynamodb.KeysAndAttributes{
+				"TestCache": {Keys: keys[:1]},
+			},
+		})
+		if len(keys) > 1 {
+			output.UnprocessedKeys = map[string]*dynamodb.KeysAndAttributes{
+				"TestCache": {Keys: keys[1:]},
+			}
+		}
+		return output, err
+	}
+
+	// the unprocessed keys are requested again without a backoff
+	data, err := a.Get("large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "0123456789" {
+		t.Errorf("got wrong data '%s'", data)
+	}
+	if calls != 10 {
+		t.Errorf("expected 10 calls but got %d", calls)
+	}
+}
+
+func TestChunks_Throttled(t *testing.T) {
+	defer func(retries int, backoff time.Duration) {
+		MaxRetries, RetryBackoff = retries, backoff
+	}(MaxRetries, RetryBackoff)
+	MaxRetries = 2
+	RetryBackoff = time.Millisecond
+
+	items := make(map[string]map[string]*dynamodb.AttributeValue)
+	mock := newTableMock(items)
+	a, err := New(mock, "TestCache", time.Hour, WithChunkSize(4))()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.Set("large", []byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := mock.BatchGetItemFunc
+	throttled := 2
+	mock.BatchGetItemFunc = func(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+		if throttled > 0 {
+			throttled--
+			return nil, awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "throttled", nil)
+		}
+		return get(input)
+	}
+
+	data, err := a.Get("large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "0123456789" {
+		t.Errorf("got wrong data '%s'", data)
+	}
+
+	// no retries are left
+	throttled = 3
+	_, err = a.Get("large")
+	if err != ErrUnprocessed {
+		t.Errorf("expected ErrUnprocessed but got %v", err)
+	}
+}
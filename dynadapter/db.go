@@ -1,7 +1,9 @@
 package dynadapter
 
 import (
+	"context"
 	"errors"
+	"sync"
 	"time"
 
 	cache "github.com/JohannesKaufmann/dynamodb-cache"
@@ -12,9 +14,74 @@ type Adapter struct {
 	client dynamodbiface.DynamoDBAPI
 	table  string
 	ttl    time.Duration
+
+	segments   int
+	deleteRate int
+	chunkSize  int
+
+	objects    ObjectStore
+	objectSize int
+
+	generations bool
+	refresh     time.Duration
+	gen         int64
+	genFetched  time.Time
+	genM        sync.Mutex
+
+	clock func() time.Time
+}
+
+// Option changes the behaviour of the adapter.
+type Option func(*Adapter)
+
+// WithScanSegments scans the table in that many parallel
+// segments when deleting many items (for example DelPrefix).
+func WithScanSegments(segments int) Option {
+	return func(a *Adapter) {
+		a.segments = segments
+	}
+}
+
+// WithDeleteRate limits how many items are deleted per second
+// when deleting many items (for example DelPrefix), so that the
+// write capacity of the table is not used up. Zero means no limit.
+func WithDeleteRate(itemsPerSecond int) Option {
+	return func(a *Adapter) {
+		a.deleteRate = itemsPerSecond
+	}
+}
+
+// WithGenerations makes Clear invalidate every item logically
+// instead of deleting the items. Every item is saved with the
+// current generation and Clear increments the generation, so
+// that all older items are ignored until their TTL deletes them.
+//
+// The generation is read at most once per refresh, so other
+// instances see a Clear only after up to that duration.
+func WithGenerations(refresh time.Duration) Option {
+	return func(a *Adapter) {
+		a.generations = true
+		a.refresh = refresh
+	}
 }
 
-func New(client dynamodbiface.DynamoDBAPI, table string, ttl time.Duration) cache.InitAdapter {
+// WithClock replaces time.Now, for example with a fake clock in
+// tests. The TTL of DynamoDB still deletes items by the real time.
+func WithClock(now func() time.Time) Option {
+	return func(a *Adapter) {
+		a.clock = now
+	}
+}
+
+// now returns the time of the clock.
+func (a *Adapter) now() time.Time {
+	if a.clock == nil {
+		return time.Now()
+	}
+	return a.clock()
+}
+
+func New(client dynamodbiface.DynamoDBAPI, table string, ttl time.Duration, opts ...Option) cache.InitAdapter {
 	return func() (cache.Adapter, error) {
 		if table == "" {
 			return nil, errors.New("dynamodb: name of table is empty")
@@ -23,29 +90,252 @@ func New(client dynamodbiface.DynamoDBAPI, table string, ttl time.Duration) cach
 			return nil, errors.New("dynamodb: ttl needs to be above 0 (ttl active) or -1 (no ttl)")
 		}
 
-		return &Adapter{client: client, table: table, ttl: ttl}, nil
+		a := &Adapter{client: client, table: table, ttl: ttl, segments: 1, chunkSize: DefaultChunkSize}
+		for _, opt := range opts {
+			opt(a)
+		}
+		return a, nil
 	}
 }
 
+// Name returns the name of the adapter.
+func (a *Adapter) Name() string {
+	return "dynamodb"
+}
+
 func (a *Adapter) Get(key string) ([]byte, error) {
-	i := item{Key: key}
-	err := i.get(a.client, a.table)
+	return a.GetWithContext(context.Background(), key)
+}
+
+// GetWithContext cancels the request to DynamoDB once
+// the context is canceled.
+func (a *Adapter) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	i, err := a.get(ctx, key)
 	if err != nil {
 		return nil, err
 	}
 
-	if a.ttl != -1 && time.Now().Unix() > i.TTL {
-		return nil, cache.ErrExpired
+	return i.Data, nil
+}
+
+// GetWithMetadata also returns the TTL and the creation time of the item.
+func (a *Adapter) GetWithMetadata(key string) ([]byte, cache.Metadata, error) {
+	return a.GetWithMetadataContext(context.Background(), key)
+}
+
+// GetWithMetadataContext cancels the request to DynamoDB
+// once the context is canceled.
+func (a *Adapter) GetWithMetadataContext(ctx context.Context, key string) ([]byte, cache.Metadata, error) {
+	i, err := a.get(ctx, key)
+	if err != nil {
+		return nil, cache.Metadata{}, err
+	}
+
+	var meta cache.Metadata
+	if a.ttl != -1 {
+		meta.Expires = time.Unix(i.TTL, 0)
+	}
+	if i.Created != 0 {
+		meta.Created = time.Unix(i.Created, 0)
+	}
+	return i.Data, meta, nil
+}
+
+func (a *Adapter) get(ctx context.Context, key string) (item, error) {
+	gen, err := a.generation()
+	if err != nil {
+		return item{}, err
 	}
 
-	return i.Data, nil
+	for attempt := 0; ; attempt++ {
+		i := item{Key: key}
+		err = i.getContext(ctx, a.client, a.table)
+		if err != nil {
+			return item{}, err
+		}
+
+		if gen > 0 && i.Gen != gen {
+			// the cache was cleared after the item was set
+			return item{}, cache.ErrNotFound
+		}
+		if a.ttl != -1 && a.now().Unix() > i.TTL {
+			return item{}, cache.ErrExpired
+		}
+		if i.Chunks == 0 && i.Object == "" {
+			return i, nil
+		}
+
+		i.Data, err = a.join(i)
+		if err == errMissingPart && attempt == 0 {
+			// the value was replaced, so the manifest is read again
+			continue
+		} else if err == errMissingPart {
+			return item{}, cache.ErrNotFound
+		} else if err != nil {
+			return item{}, err
+		}
+		return i, nil
+	}
+}
+
+// condition returns which existing items are valid.
+func (a *Adapter) condition(now time.Time) (condition, error) {
+	gen, err := a.generation()
+	if err != nil {
+		return condition{}, err
+	}
+
+	return condition{now: now.Unix(), expires: a.ttl != -1, gen: gen}, nil
 }
+
+// newItem returns an item that expires after the ttl of the adapter.
+func (a *Adapter) newItem(key string, data []byte, now time.Time, cond condition) item {
+	return item{
+		Key:     key,
+		TTL:     now.Add(a.ttl).Unix(),
+		Created: now.Unix(),
+		Gen:     cond.gen,
+		Data:    data,
+	}
+}
+
 func (a *Adapter) Set(key string, data []byte) error {
-	future := time.Now().Add(a.ttl).Unix()
-	i := item{Key: key, TTL: future, Data: data}
+	now := a.now()
+	cond, err := a.condition(now)
+	if err != nil {
+		return err
+	}
+
+	return a.put(a.newItem(key, data, now, cond))
+}
+
+// SetWithTTL puts the item with a different ttl than the one of the adapter.
+// A value for the object store can't have a longer ttl (see ErrObjectTTL).
+func (a *Adapter) SetWithTTL(key string, data []byte, ttl time.Duration) error {
+	if a.outlivesObjects(ttl) && len(data) > a.objectSize {
+		return ErrObjectTTL
+	}
+
+	now := a.now()
+	cond, err := a.condition(now)
+	if err != nil {
+		return err
+	}
+	i := a.newItem(key, data, now, cond)
+	i.TTL = now.Add(ttl).Unix()
+
+	return a.put(i)
+}
+
+// put splits large items into chunks before the item is put.
+func (a *Adapter) put(i item) error {
+	manifest, err := a.split(i)
+	if err != nil {
+		return err
+	}
 
-	return i.put(a.client, a.table)
+	old, err := manifest.put(a.client, a.table)
+	if err != nil {
+		a.delParts(manifest)
+		return err
+	}
+	a.replaced(old, manifest)
+
+	return nil
 }
+
+// Touch updates only the TTL attribute of the item. The data
+// is not written again, but the TTL of the chunks is updated.
+// A value in the object store can't get a longer ttl than the
+// one of the adapter (see ErrObjectTTL).
+func (a *Adapter) Touch(key string, ttl time.Duration) error {
+	now := a.now()
+	cond, err := a.condition(now)
+	if err != nil {
+		return err
+	}
+	cond.noObject = a.outlivesObjects(ttl)
+	i := item{Key: key, TTL: now.Add(ttl).Unix()}
+
+	manifest, err := i.touch(a.client, a.table, cond)
+	if err == cache.ErrNotFound && cond.noObject {
+		// the item could also exist with its value in the object store
+		existing := item{Key: key}
+		if existing.get(a.client, a.table) == nil && existing.Object != "" && cond.validItem(existing) {
+			return ErrObjectTTL
+		}
+		return cache.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	for n := 0; n < manifest.Chunks; n++ {
+		chunk := item{Key: chunkKey(key, manifest.Version, n), TTL: i.TTL}
+		_, err = chunk.touch(a.client, a.table, condition{})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Add puts the item with a condition expression, so that it
+// only succeeds if there is no item or the item is expired.
+func (a *Adapter) Add(key string, data []byte) error {
+	now := a.now()
+	cond, err := a.condition(now)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := a.split(a.newItem(key, data, now, cond))
+	if err != nil {
+		return err
+	}
+
+	err = manifest.add(a.client, a.table, cond)
+	if err != nil {
+		a.delParts(manifest)
+	}
+	return err
+}
+
+// CompareAndSwap puts the item with a condition expression, so that
+// it only succeeds if the item still has the old data. If the old
+// data is large its checksum is compared.
+func (a *Adapter) CompareAndSwap(key string, old, new []byte) error {
+	now := a.now()
+	cond, err := a.condition(now)
+	if err != nil {
+		return err
+	}
+
+	var oldSum string
+	if a.large(len(old)) {
+		oldSum = checksum(old)
+	}
+
+	manifest, err := a.split(a.newItem(key, new, now, cond))
+	if err != nil {
+		return err
+	}
+
+	replaced, err := manifest.swap(a.client, a.table, old, oldSum, cond)
+	if err != nil {
+		a.delParts(manifest)
+		return err
+	}
+	a.replaced(replaced, manifest)
+
+	return nil
+}
+
+// Del deletes the item and its chunks.
 func (a *Adapter) Del(key string) error {
-	return item{Key: key}.del(a.client, a.table)
+	old, err := item{Key: key}.del(a.client, a.table)
+	if err != nil {
+		return err
+	}
+	return a.delParts(old)
 }
@@ -8,9 +8,13 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/vmihailenco/msgpack"
 
 	"github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/JohannesKaufmann/dynamodb-cache/cachetest"
+	"github.com/JohannesKaufmann/dynamodb-cache/dynadapter/dynafake"
 
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
@@ -22,17 +26,37 @@ type mockDynamoDBClient struct {
 	GetItemFunc    func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
 	PutItemFunc    func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
 	DeleteItemFunc func(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+	UpdateItemFunc func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	ScanFunc       func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+
+	BatchWriteItemFunc func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
+	BatchGetItemFunc   func(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error)
 }
 
 func (m *mockDynamoDBClient) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
 	return m.GetItemFunc(input)
 }
+func (m *mockDynamoDBClient) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return m.GetItemFunc(input)
+}
 func (m *mockDynamoDBClient) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
 	return m.PutItemFunc(input)
 }
 func (m *mockDynamoDBClient) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
 	return m.DeleteItemFunc(input)
 }
+func (m *mockDynamoDBClient) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	return m.UpdateItemFunc(input)
+}
+func (m *mockDynamoDBClient) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	return m.ScanFunc(input)
+}
+func (m *mockDynamoDBClient) BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+	return m.BatchWriteItemFunc(input)
+}
+func (m *mockDynamoDBClient) BatchGetItem(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	return m.BatchGetItemFunc(input)
+}
 
 func new(mock *mockDynamoDBClient, ttl time.Duration) (cache.Adapter, error) {
 	return New(mock, "TestCache", ttl)()
@@ -131,6 +155,36 @@ func TestGet_Found(t *testing.T) {
 	}
 }
 
+func TestGetWithMetadata(t *testing.T) {
+	mockSvc := &mockDynamoDBClient{
+		GetItemFunc: func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{
+				Item: map[string]*dynamodb.AttributeValue{
+					"Key":     {S: aws.String("1")},
+					"TTL":     {N: aws.String("4102444800")},
+					"Created": {N: aws.String("1500000000")},
+					"Data":    {B: []byte("data")},
+				},
+			}, nil
+		},
+	}
+
+	c, err := new(mockSvc, time.Hour)
+	if err != nil {
+		t.Error(err)
+	}
+	data, meta, err := c.(cache.MetadataGetter).GetWithMetadata("1")
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(data, []byte("data")) {
+		t.Error("got different []byte")
+	}
+	if meta.Expires.Unix() != 4102444800 || meta.Created.Unix() != 1500000000 {
+		t.Error("wrong metadata")
+	}
+}
+
 func TestGet_FoundButExpired(t *testing.T) {
 	mockSvc := &mockDynamoDBClient{
 		GetItemFunc: func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
@@ -220,6 +274,110 @@ func TestSet_Err(t *testing.T) {
 	}
 }
 
+func TestAdd_Condition(t *testing.T) {
+	mockSvc := &mockDynamoDBClient{
+		PutItemFunc: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			if *input.ConditionExpression != "attribute_not_exists(#k) OR #t < :now" {
+				t.Error("wrong condition expression")
+			}
+			if *input.ExpressionAttributeNames["#t"] != "TTL" {
+				t.Error("expected condition on TTL")
+			}
+			return nil, nil
+		},
+	}
+
+	c, err := new(mockSvc, time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+	err = c.(cache.Adder).Add("1", []byte("One"))
+	if err != nil {
+		t.Error(err)
+	}
+}
+func TestAdd_AlreadyExists(t *testing.T) {
+	mockSvc := &mockDynamoDBClient{
+		PutItemFunc: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			if *input.ConditionExpression != "attribute_not_exists(#k)" {
+				t.Error("expected no condition on TTL")
+			}
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "failed", nil)
+		},
+	}
+
+	c, err := new(mockSvc, -1)
+	if err != nil {
+		t.Error(err)
+	}
+	err = c.(cache.Adder).Add("1", []byte("One"))
+	if err != cache.ErrAlreadyExists {
+		t.Error(err)
+	}
+}
+
+func TestCompareAndSwap_Conflict(t *testing.T) {
+	mockSvc := &mockDynamoDBClient{
+		PutItemFunc: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			if *input.ConditionExpression != "#d = :old AND #t >= :now" {
+				t.Error("wrong condition expression")
+			}
+			if !bytes.Equal(input.ExpressionAttributeValues[":old"].B, []byte("old")) {
+				t.Error("expected condition on old data")
+			}
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "failed", nil)
+		},
+	}
+
+	c, err := new(mockSvc, time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+	err = c.(cache.Swapper).CompareAndSwap("1", []byte("old"), []byte("new"))
+	if err != cache.ErrConflict {
+		t.Error(err)
+	}
+}
+
+func TestTouch(t *testing.T) {
+	mockSvc := &mockDynamoDBClient{
+		UpdateItemFunc: func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			if *input.UpdateExpression != "SET #t = :ttl" {
+				t.Error("expected to only update the TTL")
+			}
+			if len(input.Key) != 1 || *input.Key["Key"].S != "1" {
+				t.Error("wrong key")
+			}
+			return nil, nil
+		},
+	}
+
+	c, err := new(mockSvc, time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+	err = c.(cache.Toucher).Touch("1", time.Hour)
+	if err != nil {
+		t.Error(err)
+	}
+}
+func TestTouch_NotFound(t *testing.T) {
+	mockSvc := &mockDynamoDBClient{
+		UpdateItemFunc: func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "failed", nil)
+		},
+	}
+
+	c, err := new(mockSvc, time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+	err = c.(cache.Toucher).Touch("1", time.Hour)
+	if err != cache.ErrNotFound {
+		t.Error(err)
+	}
+}
+
 func TestDel_Err(t *testing.T) {
 	var e = errors.New("some error")
 	mockSvc := &mockDynamoDBClient{
@@ -237,3 +395,19 @@ func TestDel_Err(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestAdapterSuite(t *testing.T) {
+	cachetest.RunAdapterSuite(t, func(ttl time.Duration, now func() time.Time) cache.InitAdapter {
+		db := dynafake.New(dynafake.WithClock(now))
+		db.AddTable("TestCache", "Key")
+		return New(db, "TestCache", ttl, WithClock(now))
+	})
+}
+
+func TestAdapterSuite_Generations(t *testing.T) {
+	cachetest.RunAdapterSuite(t, func(ttl time.Duration, now func() time.Time) cache.InitAdapter {
+		db := dynafake.New(dynafake.WithClock(now))
+		db.AddTable("TestCache", "Key")
+		return New(db, "TestCache", ttl, WithClock(now), WithGenerations(0))
+	})
+}
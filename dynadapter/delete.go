@@ -0,0 +1,200 @@
+package dynadapter
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// batchSize is the maximum number of requests in a BatchWriteItem.
+const batchSize = 25
+
+// The unprocessed items of BatchWriteItem and the throttled requests
+// of BatchGetItem are retried up to MaxRetries times. The backoff starts at
+// RetryBackoff and doubles up to MaxRetryBackoff.
+var (
+	MaxRetries      = 8
+	RetryBackoff    = time.Millisecond * 50
+	MaxRetryBackoff = time.Second * 2
+)
+
+// ErrUnprocessed is returned if a batch was still not processed after
+// MaxRetries, for example because the table is throttled.
+var ErrUnprocessed = errors.New("dynamodb: batch was not processed after retrying")
+
+// retry sleeps before the retry with that number (starting at 1)
+// and returns false if there are no retries left.
+func retry(n int) bool {
+	if n > MaxRetries {
+		return false
+	}
+	time.Sleep(backoff(n))
+	return true
+}
+
+// backoff returns the delay before the retry with that number.
+func backoff(n int) time.Duration {
+	d := RetryBackoff
+	for i := 1; i < n && d < MaxRetryBackoff; i++ {
+		d *= 2
+	}
+	if d > MaxRetryBackoff {
+		d = MaxRetryBackoff
+	}
+	return d
+}
+
+// isThrottled returns true if the request was
+// rejected because the table is throttled.
+func isThrottled(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch aerr.Code() {
+	case dynamodb.ErrCodeProvisionedThroughputExceededException,
+		dynamodb.ErrCodeRequestLimitExceeded,
+		"ThrottlingException":
+		return true
+	}
+	return false
+}
+
+// throttle spreads the deletes, so that at most rate items
+// are deleted per second.
+type throttle struct {
+	rate int
+	next time.Time
+	m    sync.Mutex
+}
+
+func (t *throttle) wait(n int) {
+	if t.rate <= 0 {
+		return
+	}
+
+	t.m.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	start := t.next
+	t.next = t.next.Add(time.Duration(n) * time.Second / time.Duration(t.rate))
+	t.m.Unlock()
+
+	time.Sleep(start.Sub(now))
+}
+
+// delBatch deletes the keys with BatchWriteItem.
+func (a *Adapter) delBatch(keys []string) error {
+	var requests []*dynamodb.WriteRequest
+	for _, key := range keys {
+		requests = append(requests, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{
+				Key: map[string]*dynamodb.AttributeValue{
+					"Key": {S: aws.String(key)},
+				},
+			},
+		})
+	}
+
+	return a.batchWrite(requests)
+}
+
+// batchWrite sends the requests with BatchWriteItem, batchSize at a
+// time. Unprocessed items are retried with an exponential backoff
+// and ErrUnprocessed is returned if there are no retries left.
+func (a *Adapter) batchWrite(requests []*dynamodb.WriteRequest) error {
+	for len(requests) > 0 {
+		size := batchSize
+		if len(requests) < size {
+			size = len(requests)
+		}
+		batch := requests[:size]
+		requests = requests[size:]
+
+		for n := 1; len(batch) > 0; n++ {
+			result, err := a.client.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]*dynamodb.WriteRequest{
+					a.table: batch,
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			batch = result.UnprocessedItems[a.table]
+			if len(batch) > 0 && !retry(n) {
+				return ErrUnprocessed
+			}
+		}
+	}
+	return nil
+}
+
+// delScanned deletes every item that is returned by a scan
+// with these options and returns the number of deleted items,
+// without the chunks of large values. The objects of values in the
+// object store are deleted after their items, so that no item
+// points to a missing object.
+func (a *Adapter) delScanned(opts cache.ScanOptions) (int, error) {
+	t := &throttle{rate: a.deleteRate}
+
+	attributes := []string{"Chunk"}
+	if a.objects != nil {
+		attributes = append(attributes, "Object")
+	}
+
+	var m sync.Mutex
+	var count int
+	err := a.scanParallel(opts, a.segments, attributes, func(items []item) error {
+		for len(items) > 0 {
+			n := batchSize
+			if len(items) < n {
+				n = len(items)
+			}
+
+			keys := make([]string, n)
+			for i := range keys {
+				keys[i] = items[i].Key
+			}
+			t.wait(n)
+			err := a.delBatch(keys)
+			if err != nil {
+				return err
+			}
+			var deleted int
+			for _, i := range items[:n] {
+				if !i.Chunk {
+					deleted++
+				}
+				if i.Object != "" {
+					err = a.objects.DeleteObject(i.Object)
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			m.Lock()
+			count += deleted
+			m.Unlock()
+			items = items[n:]
+		}
+		return nil
+	})
+	return count, err
+}
+
+// DelPrefix deletes every item (also expired ones) whose key starts
+// with prefix. It scans the table and deletes the items in batches,
+// limited by WithDeleteRate. Like Scan it reads the whole table,
+// also for a prefix that only matches a few items.
+func (a *Adapter) DelPrefix(prefix string) (int, error) {
+	return a.delScanned(cache.ScanOptions{Prefix: prefix, IncludeExpired: true})
+}
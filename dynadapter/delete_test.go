@@ -0,0 +1,158 @@
+package dynadapter
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/JohannesKaufmann/dynamodb-cache/dynadapter/dynafake"
+)
+
+func TestDelPrefix(t *testing.T) {
+	var items []map[string]*dynamodb.AttributeValue
+	for i := 0; i < 30; i++ {
+		items = append(items, keyItem(fmt.Sprintf("user:%d", i)))
+	}
+
+	var m sync.Mutex
+	var deleted int
+	var retried bool
+	mockSvc := &mockDynamoDBClient{
+		ScanFunc: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			if *input.FilterExpression != "begins_with(#k, :prefix)" {
+				t.Errorf("expected expired items to be included: %s", *input.FilterExpression)
+			}
+			return &dynamodb.ScanOutput{Items: items}, nil
+		},
+		BatchWriteItemFunc: func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			m.Lock()
+			defer m.Unlock()
+
+			requests := input.RequestItems["TestCache"]
+			if len(requests) > batchSize {
+				t.Error("batch is too large")
+			}
+			if !retried {
+				// the last request was not processed
+				retried = true
+				deleted += len(requests) - 1
+				return &dynamodb.BatchWriteItemOutput{
+					UnprocessedItems: map[string][]*dynamodb.WriteRequest{
+						"TestCache": requests[len(requests)-1:],
+					},
+				}, nil
+			}
+			deleted += len(requests)
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+
+	c, err := New(mockSvc, "TestCache", time.Hour)()
+	if err != nil {
+		t.Error(err)
+	}
+	count, err := c.(*Adapter).DelPrefix("user:")
+	if err != nil {
+		t.Error(err)
+	}
+	if count != 30 || deleted != 30 {
+		t.Errorf("expected 30 deleted items but got %d (%d)", count, deleted)
+	}
+}
+
+func TestDelPrefix_Chunks(t *testing.T) {
+	db := dynafake.New()
+	db.AddTable("TestCache", "Key")
+
+	c, err := New(db, "TestCache", time.Hour, WithChunkSize(4))()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("user:1", []byte("abc"))
+	c.Set("user:2", []byte("0123456789"))
+
+	// the chunks of user:2 are deleted but not counted
+	count, err := c.(*Adapter).DelPrefix("user:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 deleted items but got %d", count)
+	}
+	if n := len(db.Items("TestCache")); n != 0 {
+		t.Errorf("expected every item to be deleted but got %d", n)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	th := &throttle{rate: 100}
+
+	start := time.Now()
+	th.wait(1)
+	th.wait(5)
+	th.wait(1)
+	if elapsed := time.Since(start); elapsed < time.Millisecond*60 {
+		t.Errorf("expected to wait for 60ms but waited %s", elapsed)
+	}
+}
+
+func TestBatchWrite_Unprocessed(t *testing.T) {
+	defer func(backoff time.Duration) { RetryBackoff = backoff }(RetryBackoff)
+	RetryBackoff = time.Millisecond
+
+	var calls int
+	mockSvc := &mockDynamoDBClient{
+		BatchWriteItemFunc: func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			calls++
+			return &dynamodb.BatchWriteItemOutput{UnprocessedItems: input.RequestItems}, nil
+		},
+		BatchGetItemFunc: func(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+			calls++
+			return &dynamodb.BatchGetItemOutput{UnprocessedKeys: input.RequestItems}, nil
+		},
+	}
+
+	c, err := New(mockSvc, "TestCache", time.Hour)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := c.(*Adapter)
+
+	err = a.delBatch([]string{"a", "b"})
+	if err != ErrUnprocessed {
+		t.Errorf("expected ErrUnprocessed but got %v", err)
+	}
+	if calls != MaxRetries+1 {
+		t.Errorf("expected %d calls but got %d", MaxRetries+1, calls)
+	}
+
+	calls = 0
+	_, err = a.join(item{Key: "large", Chunks: 2, Version: "v"})
+	if err != ErrUnprocessed {
+		t.Errorf("expected ErrUnprocessed but got %v", err)
+	}
+	if calls != MaxRetries+1 {
+		t.Errorf("expected %d calls but got %d", MaxRetries+1, calls)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	defer func(backoff, max time.Duration) {
+		RetryBackoff, MaxRetryBackoff = backoff, max
+	}(RetryBackoff, MaxRetryBackoff)
+	RetryBackoff = time.Millisecond * 10
+	MaxRetryBackoff = time.Millisecond * 30
+
+	expected := []time.Duration{10, 20, 30, 30}
+	for n, d := range expected {
+		if actual := backoff(n + 1); actual != d*time.Millisecond {
+			t.Errorf("retry %d: expected %s but got %s", n+1, d*time.Millisecond, actual)
+		}
+	}
+	if retry(MaxRetries + 1) {
+		t.Error("expected no retries left")
+	}
+}
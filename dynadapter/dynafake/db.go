@@ -0,0 +1,309 @@
+// Package dynafake is an in-memory implementation of the DynamoDB
+// API, so that code using dynamodbiface.DynamoDBAPI can be tested
+// without Docker or AWS:
+//
+//	db := dynafake.New()
+//	db.AddTable("Cache", "Key")
+//	c, err := cache.New(dynadapter.New(db, "Cache", time.Hour))
+//
+// It supports tables with a hash key and an optional range key,
+// GetItem, PutItem, DeleteItem, UpdateItem, BatchGetItem,
+// BatchWriteItem, Scan and Query (also the WithContext variants),
+// condition, filter, key condition, update and projection
+// expressions, and deleting expired items with Sweep.
+//
+// Every table behaves like it is strongly consistent. Indexes,
+// streams, transactions and capacity limits are not supported,
+// the other methods of the interface panic.
+package dynafake
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// MaxItemSize is the maximum size of an item, like in DynamoDB.
+const MaxItemSize = 400 * 1024
+
+// DB holds the tables. It is safe for concurrent use.
+type DB struct {
+	// the methods that are not implemented panic
+	dynamodbiface.DynamoDBAPI
+
+	tables map[string]*table
+	m      sync.Mutex
+
+	now func() time.Time
+}
+
+// Option changes the behaviour of the fake.
+type Option func(*DB)
+
+// WithClock replaces time.Now, which decides in Sweep whether
+// an item is expired.
+func WithClock(now func() time.Time) Option {
+	return func(db *DB) {
+		db.now = now
+	}
+}
+
+// New returns an empty fake without tables.
+func New(opts ...Option) *DB {
+	db := &DB{tables: make(map[string]*table), now: time.Now}
+	for _, opt := range opts {
+		opt(db)
+	}
+	return db
+}
+
+type table struct {
+	name     string
+	hashKey  string
+	rangeKey string
+	// ttl is the name of the TTL attribute if it is enabled
+	ttl   string
+	items map[string]attributes
+}
+
+func validationError(format string, v ...interface{}) error {
+	return awserr.New("ValidationException", fmt.Sprintf(format, v...), nil)
+}
+
+func conditionalCheckFailed() error {
+	return awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+}
+
+// AddTable creates a table with that hash key (and range key)
+// of type string and enables the TTL for the attribute "TTL".
+// It is a shortcut for CreateTable and UpdateTimeToLive.
+func (db *DB) AddTable(name, hashKey string, rangeKey ...string) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	t := &table{name: name, hashKey: hashKey, ttl: "TTL", items: make(map[string]attributes)}
+	if len(rangeKey) > 0 {
+		t.rangeKey = rangeKey[0]
+	}
+	db.tables[name] = t
+}
+
+// table returns the table and must be called with the lock held.
+func (db *DB) table(name *string) (*table, error) {
+	t, ok := db.tables[aws.StringValue(name)]
+	if !ok {
+		return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "Requested resource not found: Table: "+aws.StringValue(name)+" not found", nil)
+	}
+	return t, nil
+}
+
+func (db *DB) CreateTable(input *dynamodb.CreateTableInput) (*dynamodb.CreateTableOutput, error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	name := aws.StringValue(input.TableName)
+	if _, ok := db.tables[name]; ok {
+		return nil, awserr.New(dynamodb.ErrCodeResourceInUseException, "Table already exists: "+name, nil)
+	}
+
+	t := &table{name: name, items: make(map[string]attributes)}
+	for _, key := range input.KeySchema {
+		switch aws.StringValue(key.KeyType) {
+		case dynamodb.KeyTypeHash:
+			t.hashKey = aws.StringValue(key.AttributeName)
+		case dynamodb.KeyTypeRange:
+			t.rangeKey = aws.StringValue(key.AttributeName)
+		}
+	}
+	if t.hashKey == "" {
+		return nil, validationError("No Hash Key specified in schema")
+	}
+	db.tables[name] = t
+
+	return &dynamodb.CreateTableOutput{TableDescription: t.describe()}, nil
+}
+
+func (db *DB) DeleteTable(input *dynamodb.DeleteTableInput) (*dynamodb.DeleteTableOutput, error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	t, err := db.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	delete(db.tables, t.name)
+
+	return &dynamodb.DeleteTableOutput{TableDescription: t.describe()}, nil
+}
+
+func (db *DB) DescribeTable(input *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	t, err := db.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.DescribeTableOutput{Table: t.describe()}, nil
+}
+
+func (t *table) describe() *dynamodb.TableDescription {
+	schema := []*dynamodb.KeySchemaElement{{
+		AttributeName: aws.String(t.hashKey),
+		KeyType:       aws.String(dynamodb.KeyTypeHash),
+	}}
+	if t.rangeKey != "" {
+		schema = append(schema, &dynamodb.KeySchemaElement{
+			AttributeName: aws.String(t.rangeKey),
+			KeyType:       aws.String(dynamodb.KeyTypeRange),
+		})
+	}
+
+	return &dynamodb.TableDescription{
+		TableName:   aws.String(t.name),
+		TableStatus: aws.String(dynamodb.TableStatusActive),
+		KeySchema:   schema,
+		ItemCount:   aws.Int64(int64(len(t.items))),
+	}
+}
+
+// UpdateTimeToLive enables or disables the TTL. Expired items
+// are only deleted by Sweep.
+func (db *DB) UpdateTimeToLive(input *dynamodb.UpdateTimeToLiveInput) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	t, err := db.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	spec := input.TimeToLiveSpecification
+	if spec == nil {
+		return nil, validationError("TimeToLiveSpecification is missing")
+	}
+
+	t.ttl = ""
+	if aws.BoolValue(spec.Enabled) {
+		t.ttl = aws.StringValue(spec.AttributeName)
+	}
+	return &dynamodb.UpdateTimeToLiveOutput{TimeToLiveSpecification: spec}, nil
+}
+
+func (db *DB) DescribeTimeToLive(input *dynamodb.DescribeTimeToLiveInput) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	t, err := db.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := &dynamodb.TimeToLiveDescription{
+		TimeToLiveStatus: aws.String(dynamodb.TimeToLiveStatusDisabled),
+	}
+	if t.ttl != "" {
+		desc.TimeToLiveStatus = aws.String(dynamodb.TimeToLiveStatusEnabled)
+		desc.AttributeName = aws.String(t.ttl)
+	}
+	return &dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: desc}, nil
+}
+
+// Sweep deletes the items whose TTL attribute (a number with the
+// unix time in seconds) is in the past. DynamoDB does this in the
+// background and can take up to a few days, so code under test
+// must not rely on the items being gone. It returns the number
+// of deleted items.
+func (db *DB) Sweep() int {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	now := db.now().Unix()
+	var count int
+	for _, t := range db.tables {
+		if t.ttl == "" {
+			continue
+		}
+		for key, item := range t.items {
+			v := item[t.ttl]
+			if v == nil || v.N == nil {
+				continue
+			}
+			expires, ok := parseNumber(*v.N)
+			if ok && expires.Cmp(big.NewRat(now, 1)) < 0 {
+				delete(t.items, key)
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// Items returns a copy of every item of the table, sorted by the
+// key. It is meant for assertions in tests.
+func (db *DB) Items(tableName string) []map[string]*dynamodb.AttributeValue {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	t, ok := db.tables[tableName]
+	if !ok {
+		return nil
+	}
+
+	var items []map[string]*dynamodb.AttributeValue
+	for _, key := range t.sortedKeys() {
+		items = append(items, cloneItem(t.items[key]))
+	}
+	return items
+}
+
+// key returns the encoded primary key of the item. Other
+// attributes are only allowed if onlyKey is false.
+func (t *table) key(item attributes, onlyKey bool) (string, error) {
+	hash := item[t.hashKey]
+	if encodeKey(hash) == "" {
+		return "", validationError("One of the required keys was not given a value")
+	}
+	key := encodeKey(hash)
+
+	if t.rangeKey != "" {
+		r := item[t.rangeKey]
+		if encodeKey(r) == "" {
+			return "", validationError("One of the required keys was not given a value")
+		}
+		key += "\x00" + encodeKey(r)
+	}
+
+	if onlyKey {
+		for name := range item {
+			if name != t.hashKey && name != t.rangeKey {
+				return "", validationError("The provided key element does not match the schema")
+			}
+		}
+	}
+	return key, nil
+}
+
+// keyOf returns only the key attributes of the item.
+func (t *table) keyOf(item attributes) attributes {
+	key := attributes{t.hashKey: clone(item[t.hashKey])}
+	if t.rangeKey != "" {
+		key[t.rangeKey] = clone(item[t.rangeKey])
+	}
+	return key
+}
+
+func (t *table) sortedKeys() []string {
+	keys := make([]string, 0, len(t.items))
+	for key := range t.items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,436 @@
+package dynafake
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func code(err error) string {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code()
+	}
+	return ""
+}
+
+func put(t *testing.T, db *DB, table string, item attributes) {
+	t.Helper()
+	_, err := db.PutItem(&dynamodb.PutItemInput{TableName: aws.String(table), Item: item})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPutGetDelete(t *testing.T) {
+	db := New()
+	db.AddTable("Test", "Key")
+
+	put(t, db, "Test", attributes{"Key": s("a"), "Data": s("1")})
+
+	out, err := db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String("Test"),
+		Key:       attributes{"Key": s("a")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *out.Item["Data"].S != "1" {
+		t.Errorf("got wrong item %v", out.Item)
+	}
+
+	// the returned item is a copy
+	out.Item["Data"].S = aws.String("changed")
+	if *db.Items("Test")[0]["Data"].S != "1" {
+		t.Error("expected the stored item to be unchanged")
+	}
+
+	_, err = db.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String("Test"),
+		Key:       attributes{"Key": s("a")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String("Test"),
+		Key:       attributes{"Key": s("a")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Item != nil {
+		t.Errorf("expected no item but got %v", out.Item)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	db := New()
+	db.AddTable("Test", "Key")
+
+	_, err := db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String("Missing"),
+		Key:       attributes{"Key": s("a")},
+	})
+	if code(err) != dynamodb.ErrCodeResourceNotFoundException {
+		t.Errorf("expected ResourceNotFound but got %v", err)
+	}
+
+	_, err = db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String("Test"),
+		Key:       attributes{"Key": s("a"), "Data": s("1")},
+	})
+	if code(err) != "ValidationException" {
+		t.Errorf("expected a ValidationException for a non key attribute but got %v", err)
+	}
+
+	_, err = db.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String("Test"),
+		Item:      attributes{"Data": s("1")},
+	})
+	if code(err) != "ValidationException" {
+		t.Errorf("expected a ValidationException for a missing key but got %v", err)
+	}
+
+	_, err = db.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String("Test"),
+		Item:      attributes{"Key": s("a"), "Data": {B: make([]byte, MaxItemSize)}},
+	})
+	if code(err) != "ValidationException" {
+		t.Errorf("expected a ValidationException for a large item but got %v", err)
+	}
+
+	_, err = db.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 aws.String("Test"),
+		Key:                       attributes{"Key": s("a")},
+		UpdateExpression:          aws.String("SET #k = :v"),
+		ExpressionAttributeNames:  map[string]*string{"#k": aws.String("Key")},
+		ExpressionAttributeValues: attributes{":v": s("b")},
+	})
+	if code(err) != "ValidationException" {
+		t.Errorf("expected a ValidationException for updating the key but got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("Test"),
+		Key:       attributes{"Key": s("a")},
+	})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled but got %v", err)
+	}
+}
+
+func TestConditionalPut(t *testing.T) {
+	db := New()
+	db.AddTable("Test", "Key")
+
+	input := &dynamodb.PutItemInput{
+		TableName:                aws.String("Test"),
+		Item:                     attributes{"Key": s("a"), "Note": s("1")},
+		ConditionExpression:      aws.String("attribute_not_exists(#k)"),
+		ExpressionAttributeNames: map[string]*string{"#k": aws.String("Key")},
+	}
+	_, err := db.PutItem(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.PutItem(input)
+	if code(err) != dynamodb.ErrCodeConditionalCheckFailedException {
+		t.Errorf("expected ConditionalCheckFailed but got %v", err)
+	}
+
+	_, err = db.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName:                 aws.String("Test"),
+		Key:                       attributes{"Key": s("a")},
+		ConditionExpression:       aws.String("Note = :v"),
+		ExpressionAttributeValues: attributes{":v": s("2")},
+	})
+	if code(err) != dynamodb.ErrCodeConditionalCheckFailedException {
+		t.Errorf("expected ConditionalCheckFailed but got %v", err)
+	}
+	if len(db.Items("Test")) != 1 {
+		t.Error("expected the item to still exist")
+	}
+}
+
+func TestUpdateItem(t *testing.T) {
+	db := New()
+	db.AddTable("Test", "Key")
+
+	update := func() *dynamodb.UpdateItemOutput {
+		out, err := db.UpdateItem(&dynamodb.UpdateItemInput{
+			TableName:                 aws.String("Test"),
+			Key:                       attributes{"Key": s("counter")},
+			UpdateExpression:          aws.String("ADD Hits :one"),
+			ExpressionAttributeValues: attributes{":one": n("1")},
+			ReturnValues:              aws.String(dynamodb.ReturnValueUpdatedNew),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	// the first update creates the item
+	update()
+	out := update()
+	if *out.Attributes["Hits"].N != "2" {
+		t.Errorf("expected 2 hits but got %v", out.Attributes)
+	}
+	if out.Attributes["Key"] != nil {
+		t.Error("expected only the updated attributes")
+	}
+}
+
+func TestBatch(t *testing.T) {
+	db := New()
+	db.AddTable("Test", "Key")
+
+	var writes []*dynamodb.WriteRequest
+	for i := 0; i < 26; i++ {
+		writes = append(writes, &dynamodb.WriteRequest{
+			PutRequest: &dynamodb.PutRequest{Item: attributes{"Key": s(fmt.Sprint(i))}},
+		})
+	}
+	_, err := db.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+		RequestItems: map[string][]*dynamodb.WriteRequest{"Test": writes},
+	})
+	if code(err) != "ValidationException" {
+		t.Errorf("expected a ValidationException for 26 writes but got %v", err)
+	}
+	if len(db.Items("Test")) != 0 {
+		t.Fatal("expected nothing to be written")
+	}
+
+	_, err = db.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+		RequestItems: map[string][]*dynamodb.WriteRequest{"Test": writes[:25]},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := db.BatchGetItem(&dynamodb.BatchGetItemInput{
+		RequestItems: map[string]*dynamodb.KeysAndAttributes{
+			"Test": {Keys: []map[string]*dynamodb.AttributeValue{
+				{"Key": s("0")}, {"Key": s("24")}, {"Key": s("missing")},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Responses["Test"]) != 2 {
+		t.Errorf("expected 2 items but got %d", len(out.Responses["Test"]))
+	}
+
+	_, err = db.BatchGetItem(&dynamodb.BatchGetItemInput{
+		RequestItems: map[string]*dynamodb.KeysAndAttributes{
+			"Test": {Keys: []map[string]*dynamodb.AttributeValue{
+				{"Key": s("0")}, {"Key": s("0")},
+			}},
+		},
+	})
+	if code(err) != "ValidationException" {
+		t.Errorf("expected a ValidationException for duplicates but got %v", err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	db := New()
+	db.AddTable("Test", "Key")
+	for i := 0; i < 10; i++ {
+		put(t, db, "Test", attributes{"Key": s(fmt.Sprint(i)), "Even": {BOOL: aws.Bool(i%2 == 0)}})
+	}
+
+	// Limit counts the items before the filter
+	var keys []string
+	var start attributes
+	var pages int
+	for {
+		out, err := db.Scan(&dynamodb.ScanInput{
+			TableName:                 aws.String("Test"),
+			Limit:                     aws.Int64(3),
+			ExclusiveStartKey:         start,
+			FilterExpression:          aws.String("Even = :t"),
+			ExpressionAttributeValues: attributes{":t": {BOOL: aws.Bool(true)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, item := range out.Items {
+			keys = append(keys, *item["Key"].S)
+		}
+		if out.LastEvaluatedKey == nil {
+			break
+		}
+		start = out.LastEvaluatedKey
+	}
+	if pages != 4 {
+		t.Errorf("expected 4 pages but got %d", pages)
+	}
+	if strings.Join(keys, ",") != "0,2,4,6,8" {
+		t.Errorf("got wrong keys %v", keys)
+	}
+
+	// every item is in exactly one segment
+	var count int64
+	for segment := int64(0); segment < 3; segment++ {
+		out, err := db.Scan(&dynamodb.ScanInput{
+			TableName:     aws.String("Test"),
+			Segment:       aws.Int64(segment),
+			TotalSegments: aws.Int64(3),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		count += *out.Count
+	}
+	if count != 10 {
+		t.Errorf("expected 10 items in all segments but got %d", count)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	db := New()
+	db.AddTable("Test", "User", "Sort")
+	for _, sort := range []string{"b", "a", "c", "x"} {
+		put(t, db, "Test", attributes{"User": s("1"), "Sort": s(sort)})
+	}
+	put(t, db, "Test", attributes{"User": s("2"), "Sort": s("a")})
+
+	query := func(forward bool) string {
+		out, err := db.Query(&dynamodb.QueryInput{
+			TableName:                 aws.String("Test"),
+			KeyConditionExpression:    aws.String("#u = :u AND #s BETWEEN :from AND :to"),
+			ExpressionAttributeNames:  map[string]*string{"#u": aws.String("User"), "#s": aws.String("Sort")},
+			ExpressionAttributeValues: attributes{":u": s("1"), ":from": s("a"), ":to": s("c")},
+			ScanIndexForward:          aws.Bool(forward),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var sorts []string
+		for _, item := range out.Items {
+			sorts = append(sorts, *item["Sort"].S)
+		}
+		return strings.Join(sorts, ",")
+	}
+
+	if sorts := query(true); sorts != "a,b,c" {
+		t.Errorf("got wrong order %s", sorts)
+	}
+	if sorts := query(false); sorts != "c,b,a" {
+		t.Errorf("got wrong order %s", sorts)
+	}
+}
+
+func TestScan_DeletedStartKey(t *testing.T) {
+	db := New()
+	db.AddTable("Test", "Key")
+	for i := 0; i < 6; i++ {
+		put(t, db, "Test", attributes{"Key": s(fmt.Sprint(i))})
+	}
+
+	out, err := db.Scan(&dynamodb.ScanInput{TableName: aws.String("Test"), Limit: aws.Int64(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the last item of the page is deleted before the next page
+	_, err = db.DeleteItem(&dynamodb.DeleteItemInput{TableName: aws.String("Test"), Key: out.LastEvaluatedKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = db.Scan(&dynamodb.ScanInput{TableName: aws.String("Test"), ExclusiveStartKey: out.LastEvaluatedKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, item := range out.Items {
+		keys = append(keys, *item["Key"].S)
+	}
+	if strings.Join(keys, ",") != "3,4,5" {
+		t.Errorf("expected the scan to continue after the deleted key but got %v", keys)
+	}
+}
+
+func TestQuery_DeletedStartKey(t *testing.T) {
+	db := New()
+	db.AddTable("Test", "User", "Sort")
+	for _, sort := range []string{"d", "a", "c", "b"} {
+		put(t, db, "Test", attributes{"User": s("1"), "Sort": s(sort)})
+	}
+
+	for _, forward := range []bool{true, false} {
+		input := &dynamodb.QueryInput{
+			TableName:                 aws.String("Test"),
+			KeyConditionExpression:    aws.String("#u = :u"),
+			ExpressionAttributeNames:  map[string]*string{"#u": aws.String("User")},
+			ExpressionAttributeValues: attributes{":u": s("1")},
+			ScanIndexForward:          aws.Bool(forward),
+			Limit:                     aws.Int64(2),
+		}
+		out, err := db.Query(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.DeleteItem(&dynamodb.DeleteItemInput{TableName: aws.String("Test"), Key: out.LastEvaluatedKey})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "c,d"
+		if !forward {
+			expected = "b,a"
+		}
+
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+		input.Limit = nil
+		out, err = db.Query(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sorts []string
+		for _, item := range out.Items {
+			sorts = append(sorts, *item["Sort"].S)
+		}
+		if strings.Join(sorts, ",") != expected {
+			t.Errorf("forward %v: expected %s but got %v", forward, expected, sorts)
+		}
+
+		// the deleted item is added again for the next direction
+		put(t, db, "Test", attributes{"User": s("1"), "Sort": input.ExclusiveStartKey["Sort"]})
+	}
+}
+
+func TestSweep(t *testing.T) {
+	now := time.Unix(1000, 0)
+	db := New(WithClock(func() time.Time { return now }))
+	db.AddTable("Test", "Key")
+
+	put(t, db, "Test", attributes{"Key": s("expired"), "TTL": n("999")})
+	put(t, db, "Test", attributes{"Key": s("valid"), "TTL": n("1000")})
+	put(t, db, "Test", attributes{"Key": s("forever")})
+
+	if count := db.Sweep(); count != 1 {
+		t.Errorf("expected 1 deleted item but got %d", count)
+	}
+
+	now = now.Add(time.Hour)
+	if count := db.Sweep(); count != 1 {
+		t.Errorf("expected 1 deleted item but got %d", count)
+	}
+
+	items := db.Items("Test")
+	if len(items) != 1 || *items[0]["Key"].S != "forever" {
+		t.Errorf("got wrong items %v", items)
+	}
+}
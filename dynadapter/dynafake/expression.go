@@ -0,0 +1,983 @@
+package dynafake
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// The expressions are parsed into closures that are called with
+// the item. A missing attribute is a nil value.
+
+type condition func(item attributes) (bool, error)
+type operand func(item attributes) (*dynamodb.AttributeValue, error)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenName   // #name
+	tokenValue  // :value
+	tokenNumber // list index
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#' || c == ':' || isIdent(c):
+			j := i + 1
+			for j < len(s) && isIdent(rune(s[j])) {
+				j++
+			}
+			kind := tokenIdent
+			if c == '#' {
+				kind = tokenName
+			} else if c == ':' {
+				kind = tokenValue
+			} else if unicode.IsDigit(c) {
+				kind = tokenNumber
+			}
+			if j == i+1 && kind != tokenIdent && kind != tokenNumber {
+				return nil, fmt.Errorf("invalid token at %q", s[i:])
+			}
+			tokens = append(tokens, token{kind, s[i:j]})
+			i = j
+		case strings.HasPrefix(s[i:], "<>") || strings.HasPrefix(s[i:], "<=") || strings.HasPrefix(s[i:], ">="):
+			tokens = append(tokens, token{tokenPunct, s[i : i+2]})
+			i += 2
+		case strings.ContainsRune("()[],.=<>+-", c):
+			tokens = append(tokens, token{tokenPunct, s[i : i+1]})
+			i++
+		default:
+			return nil, fmt.Errorf("invalid character %q", c)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+func isIdent(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// reserved are some of the reserved words of DynamoDB, which need
+// a placeholder in expressions. The list is not complete.
+var reserved = map[string]bool{
+	"COUNT": true, "DATA": true, "DATE": true, "KEY": true, "NAME": true,
+	"OWNER": true, "SIZE": true, "STATUS": true, "TIME": true, "TIMESTAMP": true,
+	"TTL": true, "TYPE": true, "USER": true, "VALUE": true, "VERSION": true,
+}
+
+// placeholders resolves the #names and :values of the expressions
+// of one request and remembers which of them were used.
+type placeholders struct {
+	names  map[string]*string
+	values map[string]*dynamodb.AttributeValue
+	used   map[string]bool
+}
+
+func newPlaceholders(names map[string]*string, values map[string]*dynamodb.AttributeValue) (*placeholders, error) {
+	if names != nil && len(names) == 0 {
+		return nil, validationError("ExpressionAttributeNames must not be empty")
+	}
+	if values != nil && len(values) == 0 {
+		return nil, validationError("ExpressionAttributeValues must not be empty")
+	}
+	return &placeholders{names: names, values: values, used: make(map[string]bool)}, nil
+}
+
+// unused returns an error if a placeholder was never used,
+// like DynamoDB does.
+func (p *placeholders) unused() error {
+	var names, values []string
+	for name := range p.names {
+		if !p.used[name] {
+			names = append(names, name)
+		}
+	}
+	for value := range p.values {
+		if !p.used[value] {
+			values = append(values, value)
+		}
+	}
+	if len(names) > 0 {
+		return validationError("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}", strings.Join(names, ", "))
+	}
+	if len(values) > 0 {
+		return validationError("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}", strings.Join(values, ", "))
+	}
+	return nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	p      *placeholders
+}
+
+func (p *placeholders) parser(expr string) (*parser, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, validationError("Invalid expression: %v", err)
+	}
+	return &parser{tokens: tokens, p: p}, nil
+}
+
+func (ps *parser) peek() token {
+	return ps.tokens[ps.pos]
+}
+func (ps *parser) next() token {
+	t := ps.tokens[ps.pos]
+	if t.kind != tokenEOF {
+		ps.pos++
+	}
+	return t
+}
+
+// keyword consumes the keyword if it is next.
+func (ps *parser) keyword(word string) bool {
+	t := ps.peek()
+	if t.kind == tokenIdent && strings.EqualFold(t.text, word) {
+		ps.pos++
+		return true
+	}
+	return false
+}
+
+// punct consumes the punctuation if it is next.
+func (ps *parser) punct(p string) bool {
+	t := ps.peek()
+	if t.kind == tokenPunct && t.text == p {
+		ps.pos++
+		return true
+	}
+	return false
+}
+
+func (ps *parser) expect(p string) error {
+	if !ps.punct(p) {
+		return ps.errorf("expected %q", p)
+	}
+	return nil
+}
+
+func (ps *parser) errorf(format string, v ...interface{}) error {
+	near := ps.peek().text
+	if ps.peek().kind == tokenEOF {
+		near = "<EOF>"
+	}
+	return validationError("Invalid expression: %s near %q", fmt.Sprintf(format, v...), near)
+}
+
+func (ps *parser) end() error {
+	if ps.peek().kind != tokenEOF {
+		return ps.errorf("unexpected token")
+	}
+	return nil
+}
+
+// - - - conditions - - - //
+
+func (p *placeholders) condition(expr *string) (condition, error) {
+	if expr == nil {
+		return nil, nil
+	}
+	ps, err := p.parser(*expr)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := ps.or()
+	if err != nil {
+		return nil, err
+	}
+	return cond, ps.end()
+}
+
+func (ps *parser) or() (condition, error) {
+	left, err := ps.and()
+	if err != nil {
+		return nil, err
+	}
+	for ps.keyword("OR") {
+		right, err := ps.and()
+		if err != nil {
+			return nil, err
+		}
+		a, b := left, right
+		left = func(item attributes) (bool, error) {
+			ok, err := a(item)
+			if err != nil || ok {
+				return ok, err
+			}
+			return b(item)
+		}
+	}
+	return left, nil
+}
+
+func (ps *parser) and() (condition, error) {
+	left, err := ps.not()
+	if err != nil {
+		return nil, err
+	}
+	for ps.keyword("AND") {
+		right, err := ps.not()
+		if err != nil {
+			return nil, err
+		}
+		a, b := left, right
+		left = func(item attributes) (bool, error) {
+			ok, err := a(item)
+			if err != nil || !ok {
+				return ok, err
+			}
+			return b(item)
+		}
+	}
+	return left, nil
+}
+
+func (ps *parser) not() (condition, error) {
+	if ps.keyword("NOT") {
+		cond, err := ps.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(item attributes) (bool, error) {
+			ok, err := cond(item)
+			return !ok, err
+		}, nil
+	}
+	return ps.primary()
+}
+
+func (ps *parser) primary() (condition, error) {
+	if ps.punct("(") {
+		cond, err := ps.or()
+		if err != nil {
+			return nil, err
+		}
+		return cond, ps.expect(")")
+	}
+
+	t := ps.peek()
+	if t.kind == tokenIdent && ps.tokens[ps.pos+1].text == "(" && t.text != "size" {
+		return ps.function()
+	}
+
+	left, err := ps.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	if ps.keyword("BETWEEN") {
+		low, err := ps.operand()
+		if err != nil {
+			return nil, err
+		}
+		if !ps.keyword("AND") {
+			return nil, ps.errorf("expected AND")
+		}
+		high, err := ps.operand()
+		if err != nil {
+			return nil, err
+		}
+		return func(item attributes) (bool, error) {
+			v, a, b, err := eval3(item, left, low, high)
+			if err != nil {
+				return false, err
+			}
+			n, ok := compare(v, a)
+			m, ok2 := compare(v, b)
+			return ok && ok2 && n >= 0 && m <= 0, nil
+		}, nil
+	}
+
+	if ps.keyword("IN") {
+		if err := ps.expect("("); err != nil {
+			return nil, err
+		}
+		var list []operand
+		for {
+			o, err := ps.operand()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, o)
+			if !ps.punct(",") {
+				break
+			}
+		}
+		if err := ps.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(item attributes) (bool, error) {
+			v, err := left(item)
+			if err != nil || v == nil {
+				return false, err
+			}
+			for _, o := range list {
+				e, err := o(item)
+				if err != nil {
+					return false, err
+				}
+				if equal(v, e) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}, nil
+	}
+
+	op := ps.next()
+	if op.kind != tokenPunct {
+		return nil, ps.errorf("expected a comparator")
+	}
+	right, err := ps.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	var check func(a, b *dynamodb.AttributeValue) bool
+	switch op.text {
+	case "=":
+		check = equal
+	case "<>":
+		check = func(a, b *dynamodb.AttributeValue) bool { return !equal(a, b) }
+	case "<", "<=", ">", ">=":
+		check = func(a, b *dynamodb.AttributeValue) bool {
+			n, ok := compare(a, b)
+			if !ok {
+				return false
+			}
+			switch op.text {
+			case "<":
+				return n < 0
+			case "<=":
+				return n <= 0
+			case ">":
+				return n > 0
+			}
+			return n >= 0
+		}
+	default:
+		return nil, validationError("Invalid expression: unknown comparator %q", op.text)
+	}
+
+	return func(item attributes) (bool, error) {
+		a, b, _, err := eval3(item, left, right, nil)
+		if err != nil {
+			return false, err
+		}
+		if a == nil || b == nil {
+			// only <> is true for a missing attribute
+			return op.text == "<>" && (a != nil || b != nil), nil
+		}
+		return check(a, b), nil
+	}, nil
+}
+
+func eval3(item attributes, a, b, c operand) (x, y, z *dynamodb.AttributeValue, err error) {
+	for i, o := range []operand{a, b, c} {
+		if o == nil {
+			continue
+		}
+		v, err := o(item)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		switch i {
+		case 0:
+			x = v
+		case 1:
+			y = v
+		case 2:
+			z = v
+		}
+	}
+	return x, y, z, nil
+}
+
+// function parses the functions that are conditions.
+func (ps *parser) function() (condition, error) {
+	name := ps.next().text
+	ps.next() // (
+
+	var args []operand
+	var path path
+	for i := 0; ; i++ {
+		if i == 0 {
+			var err error
+			path, err = ps.path()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, path.operand())
+		} else {
+			o, err := ps.operand()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, o)
+		}
+		if !ps.punct(",") {
+			break
+		}
+	}
+	if err := ps.expect(")"); err != nil {
+		return nil, err
+	}
+
+	arity := map[string]int{
+		"attribute_exists": 1, "attribute_not_exists": 1,
+		"attribute_type": 2, "begins_with": 2, "contains": 2,
+	}
+	n, ok := arity[name]
+	if !ok {
+		return nil, validationError("Invalid expression: unknown function %q", name)
+	}
+	if n != len(args) {
+		return nil, validationError("Invalid expression: %s needs %d arguments", name, n)
+	}
+
+	return func(item attributes) (bool, error) {
+		v, arg, _, err := eval3(item, args[0], argAt(args, 1), nil)
+		if err != nil {
+			return false, err
+		}
+
+		switch name {
+		case "attribute_exists":
+			return v != nil, nil
+		case "attribute_not_exists":
+			return v == nil, nil
+		case "attribute_type":
+			return v != nil && arg != nil && arg.S != nil && typeOf(v) == *arg.S, nil
+		case "begins_with":
+			if v == nil || arg == nil {
+				return false, nil
+			}
+			if v.S != nil && arg.S != nil {
+				return strings.HasPrefix(*v.S, *arg.S), nil
+			}
+			if v.B != nil && arg.B != nil {
+				return strings.HasPrefix(string(v.B), string(arg.B)), nil
+			}
+			return false, nil
+		default: // contains
+			if v == nil || arg == nil {
+				return false, nil
+			}
+			switch typeOf(v) {
+			case "S":
+				return arg.S != nil && strings.Contains(*v.S, *arg.S), nil
+			case "B":
+				return arg.B != nil && strings.Contains(string(v.B), string(arg.B)), nil
+			case "L":
+				for _, e := range v.L {
+					if equal(e, arg) {
+						return true, nil
+					}
+				}
+				return false, nil
+			case "SS", "NS", "BS":
+				return containsMember(setMembers(v), arg), nil
+			}
+			return false, nil
+		}
+	}, nil
+}
+
+func argAt(args []operand, i int) operand {
+	if i < len(args) {
+		return args[i]
+	}
+	return nil
+}
+
+// - - - operands - - - //
+
+func (ps *parser) operand() (operand, error) {
+	t := ps.peek()
+	switch {
+	case t.kind == tokenValue:
+		ps.next()
+		v, ok := ps.p.values[t.text]
+		if !ok {
+			return nil, validationError("An expression attribute value used in expression is not defined; attribute value: %s", t.text)
+		}
+		ps.p.used[t.text] = true
+		return func(item attributes) (*dynamodb.AttributeValue, error) {
+			return v, nil
+		}, nil
+
+	case t.kind == tokenIdent && t.text == "size" && ps.tokens[ps.pos+1].text == "(":
+		ps.next()
+		ps.next()
+		path, err := ps.path()
+		if err != nil {
+			return nil, err
+		}
+		if err := ps.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(item attributes) (*dynamodb.AttributeValue, error) {
+			v := path.get(item)
+			var n int
+			switch typeOf(v) {
+			case "":
+				return nil, nil
+			case "S":
+				n = len(*v.S)
+			case "B":
+				n = len(v.B)
+			case "M":
+				n = len(v.M)
+			case "L":
+				n = len(v.L)
+			case "SS", "NS", "BS":
+				n = len(setMembers(v))
+			default:
+				return nil, validationError("Invalid function operand type for size: %s", typeOf(v))
+			}
+			return &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(n))}, nil
+		}, nil
+
+	case t.kind == tokenIdent || t.kind == tokenName:
+		path, err := ps.path()
+		if err != nil {
+			return nil, err
+		}
+		return path.operand(), nil
+	}
+	return nil, ps.errorf("expected an operand")
+}
+
+// pathPart is an attribute name or a list index.
+type pathPart struct {
+	name  string
+	index int
+	list  bool
+}
+
+type path []pathPart
+
+func (ps *parser) name() (string, error) {
+	t := ps.next()
+	switch t.kind {
+	case tokenName:
+		name, ok := ps.p.names[t.text]
+		if !ok {
+			return "", validationError("An expression attribute name used in the document path is not defined; attribute name: %s", t.text)
+		}
+		ps.p.used[t.text] = true
+		return aws.StringValue(name), nil
+	case tokenIdent:
+		if reserved[strings.ToUpper(t.text)] {
+			return "", validationError("Attribute name is a reserved keyword; reserved keyword: %s", t.text)
+		}
+		return t.text, nil
+	}
+	ps.pos--
+	return "", ps.errorf("expected an attribute name")
+}
+
+func (ps *parser) path() (path, error) {
+	name, err := ps.name()
+	if err != nil {
+		return nil, err
+	}
+	p := path{{name: name}}
+
+	for {
+		if ps.punct(".") {
+			name, err := ps.name()
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, pathPart{name: name})
+		} else if ps.punct("[") {
+			t := ps.next()
+			index, err := strconv.Atoi(t.text)
+			if t.kind != tokenNumber || err != nil {
+				return nil, ps.errorf("expected a list index")
+			}
+			if err := ps.expect("]"); err != nil {
+				return nil, err
+			}
+			p = append(p, pathPart{index: index, list: true})
+		} else {
+			return p, nil
+		}
+	}
+}
+
+func (p path) operand() operand {
+	return func(item attributes) (*dynamodb.AttributeValue, error) {
+		return p.get(item), nil
+	}
+}
+
+func (p path) get(item attributes) *dynamodb.AttributeValue {
+	v := item[p[0].name]
+	for _, part := range p[1:] {
+		if v == nil {
+			return nil
+		}
+		if part.list {
+			if part.index >= len(v.L) {
+				return nil
+			}
+			v = v.L[part.index]
+		} else {
+			v = v.M[part.name]
+		}
+	}
+	return v
+}
+
+// parent returns the map or list that holds the last part of the path.
+func (p path) parent(item attributes) (*dynamodb.AttributeValue, error) {
+	if len(p) == 1 {
+		return &dynamodb.AttributeValue{M: item}, nil
+	}
+	parent := path(p[:len(p)-1]).get(item)
+	last := p[len(p)-1]
+	if parent == nil || (last.list && parent.L == nil) || (!last.list && parent.M == nil) {
+		return nil, validationError("The document path provided in the update expression is invalid for update")
+	}
+	return parent, nil
+}
+
+func (p path) set(item attributes, v *dynamodb.AttributeValue) error {
+	parent, err := p.parent(item)
+	if err != nil {
+		return err
+	}
+	last := p[len(p)-1]
+	if !last.list {
+		parent.M[last.name] = v
+		return nil
+	}
+	if last.index < len(parent.L) {
+		parent.L[last.index] = v
+	} else {
+		parent.L = append(parent.L, v)
+	}
+	return nil
+}
+
+func (p path) remove(item attributes) error {
+	parent, err := p.parent(item)
+	if err != nil {
+		return nil
+	}
+	last := p[len(p)-1]
+	if !last.list {
+		delete(parent.M, last.name)
+	} else if last.index < len(parent.L) {
+		parent.L = append(parent.L[:last.index], parent.L[last.index+1:]...)
+	}
+	return nil
+}
+
+// - - - updates - - - //
+
+// update changes the item in place.
+type update func(item attributes) error
+
+// update parses an update expression. The values are computed from
+// the item before any action is applied, like in DynamoDB. It also
+// returns the names of the top level attributes that are changed.
+func (p *placeholders) update(expr *string) (update, map[string]bool, error) {
+	if expr == nil {
+		return func(attributes) error { return nil }, nil, nil
+	}
+	ps, err := p.parser(*expr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type action struct {
+		clause string
+		path   path
+		value  operand
+	}
+	var actions []action
+	changed := make(map[string]bool)
+
+	for ps.peek().kind != tokenEOF {
+		clause := strings.ToUpper(ps.next().text)
+		switch clause {
+		case "SET", "REMOVE", "ADD", "DELETE":
+		default:
+			ps.pos--
+			return nil, nil, ps.errorf("expected SET, REMOVE, ADD or DELETE")
+		}
+
+		for {
+			path, err := ps.path()
+			if err != nil {
+				return nil, nil, err
+			}
+			a := action{clause: clause, path: path}
+			changed[path[0].name] = true
+
+			switch clause {
+			case "SET":
+				if err := ps.expect("="); err != nil {
+					return nil, nil, err
+				}
+				a.value, err = ps.setValue()
+			case "ADD", "DELETE":
+				a.value, err = ps.operand()
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			actions = append(actions, a)
+
+			if !ps.punct(",") {
+				break
+			}
+		}
+	}
+
+	return func(item attributes) error {
+		values := make([]*dynamodb.AttributeValue, len(actions))
+		for i, a := range actions {
+			if a.value == nil {
+				continue
+			}
+			v, err := a.value(item)
+			if err != nil {
+				return err
+			}
+			values[i] = clone(v)
+		}
+
+		for i, a := range actions {
+			v := values[i]
+			var err error
+			switch a.clause {
+			case "SET":
+				if v == nil {
+					return validationError("The provided expression refers to an attribute that does not exist in the item")
+				}
+				err = a.path.set(item, v)
+			case "REMOVE":
+				err = a.path.remove(item)
+			case "ADD":
+				err = add(item, a.path, v)
+			case "DELETE":
+				err = deleteMembers(item, a.path, v)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, changed, nil
+}
+
+// setValue parses the value of a SET action, which can
+// be a sum or difference and use if_not_exists or list_append.
+func (ps *parser) setValue() (operand, error) {
+	left, err := ps.setOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	var sign int64
+	if ps.punct("+") {
+		sign = 1
+	} else if ps.punct("-") {
+		sign = -1
+	} else {
+		return left, nil
+	}
+	right, err := ps.setOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(item attributes) (*dynamodb.AttributeValue, error) {
+		a, b, _, err := eval3(item, left, right, nil)
+		if err != nil {
+			return nil, err
+		}
+		if a == nil || b == nil {
+			return nil, validationError("The provided expression refers to an attribute that does not exist in the item")
+		}
+		x, okA := parseNumber(aws.StringValue(a.N))
+		y, okB := parseNumber(aws.StringValue(b.N))
+		if a.N == nil || b.N == nil || !okA || !okB {
+			return nil, validationError("An operand in the update expression has an incorrect data type")
+		}
+		y.Mul(y, big.NewRat(sign, 1))
+		return &dynamodb.AttributeValue{N: aws.String(formatNumber(x.Add(x, y)))}, nil
+	}, nil
+}
+
+func (ps *parser) setOperand() (operand, error) {
+	t := ps.peek()
+	if t.kind != tokenIdent || ps.tokens[ps.pos+1].text != "(" || t.text == "size" {
+		return ps.operand()
+	}
+
+	name := ps.next().text
+	ps.next() // (
+	first, err := ps.setOperand()
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.expect(","); err != nil {
+		return nil, err
+	}
+	second, err := ps.setOperand()
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.expect(")"); err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case "if_not_exists":
+		return func(item attributes) (*dynamodb.AttributeValue, error) {
+			v, err := first(item)
+			if err != nil || v != nil {
+				return v, err
+			}
+			return second(item)
+		}, nil
+	case "list_append":
+		return func(item attributes) (*dynamodb.AttributeValue, error) {
+			a, b, _, err := eval3(item, first, second, nil)
+			if err != nil {
+				return nil, err
+			}
+			if a == nil || b == nil || a.L == nil || b.L == nil {
+				return nil, validationError("An operand in the update expression has an incorrect data type")
+			}
+			l := append(append([]*dynamodb.AttributeValue{}, a.L...), b.L...)
+			return &dynamodb.AttributeValue{L: l}, nil
+		}, nil
+	}
+	return nil, validationError("Invalid UpdateExpression: unknown function %q", name)
+}
+
+// add adds a number or adds members to a set.
+func add(item attributes, p path, v *dynamodb.AttributeValue) error {
+	old := p.get(item)
+	switch typeOf(v) {
+	case "N":
+		sum, ok := parseNumber(*v.N)
+		if !ok {
+			return validationError("invalid number %q", *v.N)
+		}
+		if old != nil {
+			n, ok := parseNumber(aws.StringValue(old.N))
+			if old.N == nil || !ok {
+				return validationError("An operand in the update expression has an incorrect data type")
+			}
+			sum.Add(sum, n)
+		}
+		return p.set(item, &dynamodb.AttributeValue{N: aws.String(formatNumber(sum))})
+
+	case "SS", "NS", "BS":
+		if old == nil {
+			return p.set(item, v)
+		}
+		if typeOf(old) != typeOf(v) {
+			return validationError("An operand in the update expression has an incorrect data type")
+		}
+		members := setMembers(old)
+		for _, member := range setMembers(v) {
+			if !containsMember(members, member) {
+				members = append(members, member)
+			}
+		}
+		return p.set(item, newSet(typeOf(v), members))
+	}
+	return validationError("Incorrect operand type for operator or function; operator: ADD")
+}
+
+// deleteMembers removes the members from a set. An empty
+// set is removed, because DynamoDB has no empty sets.
+func deleteMembers(item attributes, p path, v *dynamodb.AttributeValue) error {
+	old := p.get(item)
+	if old == nil {
+		return nil
+	}
+	if typeOf(old) != typeOf(v) {
+		return validationError("An operand in the update expression has an incorrect data type")
+	}
+
+	remove := setMembers(v)
+	var members []*dynamodb.AttributeValue
+	for _, member := range setMembers(old) {
+		if !containsMember(remove, member) {
+			members = append(members, member)
+		}
+	}
+	if len(members) == 0 {
+		return p.remove(item)
+	}
+	return p.set(item, newSet(typeOf(v), members))
+}
+
+// - - - projections - - - //
+
+// projection returns the names of the top level attributes
+// that are returned. Nested paths return the whole attribute.
+func (p *placeholders) projection(expr *string) (map[string]bool, error) {
+	if expr == nil {
+		return nil, nil
+	}
+	ps, err := p.parser(*expr)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for {
+		path, err := ps.path()
+		if err != nil {
+			return nil, err
+		}
+		names[path[0].name] = true
+		if !ps.punct(",") {
+			break
+		}
+	}
+	return names, ps.end()
+}
+
+func project(item attributes, names map[string]bool) attributes {
+	if names == nil {
+		return cloneItem(item)
+	}
+	projected := make(attributes)
+	for name := range names {
+		if v, ok := item[name]; ok {
+			projected[name] = clone(v)
+		}
+	}
+	return projected
+}
@@ -0,0 +1,181 @@
+package dynafake
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func s(v string) *dynamodb.AttributeValue { return &dynamodb.AttributeValue{S: aws.String(v)} }
+func n(v string) *dynamodb.AttributeValue { return &dynamodb.AttributeValue{N: aws.String(v)} }
+
+func TestCondition(t *testing.T) {
+	item := attributes{
+		"Key":  s("user:1"),
+		"TTL":  n("100"),
+		"Tags": {SS: aws.StringSlice([]string{"a", "b"})},
+		"Info": {M: attributes{"Age": n("30"), "List": {L: []*dynamodb.AttributeValue{s("x"), s("y")}}}},
+	}
+	names := map[string]*string{"#k": aws.String("Key"), "#t": aws.String("TTL")}
+	values := map[string]*dynamodb.AttributeValue{
+		":now":    n("50"),
+		":later":  n("1e3"),
+		":prefix": s("user:"),
+		":a":      s("a"),
+		":y":      s("y"),
+		":30":     n("30.0"),
+		":type":   s("SS"),
+	}
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"attribute_exists(#k)", true},
+		{"attribute_not_exists(#k)", false},
+		{"attribute_not_exists(Missing)", true},
+		{"#t >= :now", true},
+		{"#t > :later", false},
+		{"#t BETWEEN :now AND :later", true},
+		{"#t < :now OR attribute_not_exists(#k)", false},
+		{"NOT (#t < :now) AND begins_with(#k, :prefix)", true},
+		{"contains(Tags, :a)", true},
+		{"attribute_type(Tags, :type)", true},
+		{"Info.Age = :30", true},
+		{"Info.List[1] = :y", true},
+		{"Info.List[5] = :y", false},
+		{"size(Tags) = :now", false},
+		{"#t IN (:now, :later, :30)", false},
+		{"Missing = :a", false},
+		{"Missing <> :a", true},
+	}
+
+	for _, test := range tests {
+		p, err := newPlaceholders(names, values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cond, err := p.condition(aws.String(test.expr))
+		if err != nil {
+			t.Errorf("%s: %v", test.expr, err)
+			continue
+		}
+		ok, err := cond(item)
+		if err != nil {
+			t.Errorf("%s: %v", test.expr, err)
+		}
+		if ok != test.expected {
+			t.Errorf("%s: expected %v but got %v", test.expr, test.expected, ok)
+		}
+	}
+}
+
+func TestCondition_Invalid(t *testing.T) {
+	values := map[string]*dynamodb.AttributeValue{":v": s("v")}
+	tests := []string{
+		"TTL = :v",          // reserved word
+		"#missing = :v",     // undefined name
+		"a = :missing",      // undefined value
+		"a = :v AND",        // incomplete
+		"unknown_fn(a, :v)", // unknown function
+		"a == :v",           // invalid comparator
+	}
+
+	for _, expr := range tests {
+		p, _ := newPlaceholders(nil, values)
+		_, err := p.condition(aws.String(expr))
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != "ValidationException" {
+			t.Errorf("%s: expected a ValidationException but got %v", expr, err)
+		}
+	}
+}
+
+func TestUnused(t *testing.T) {
+	p, _ := newPlaceholders(map[string]*string{"#a": aws.String("a")}, map[string]*dynamodb.AttributeValue{":v": s("v"), ":unused": s("u")})
+	_, err := p.condition(aws.String("#a = :v"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.unused()
+	if err == nil {
+		t.Error("expected an error for the unused value")
+	}
+
+	_, err = newPlaceholders(map[string]*string{}, nil)
+	if err == nil {
+		t.Error("expected an error for empty names")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	item := attributes{
+		"Key":   s("k"),
+		"Count": n("1"),
+		"Old":   s("remove me"),
+		"Tags":  {SS: aws.StringSlice([]string{"a", "b"})},
+		"List":  {L: []*dynamodb.AttributeValue{s("x")}},
+		"Info":  {M: attributes{}},
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":one":  n("1"),
+		":half": n("0.5"),
+		":tags": {SS: aws.StringSlice([]string{"b", "c"})},
+		":del":  {SS: aws.StringSlice([]string{"a"})},
+		":list": {L: []*dynamodb.AttributeValue{s("y")}},
+		":name": s("name"),
+	}
+
+	p, _ := newPlaceholders(map[string]*string{"#c": aws.String("Count")}, values)
+	upd, changed, err := p.update(aws.String(
+		"SET #c = #c + :one, Total = if_not_exists(Total, :half) - :one, List = list_append(List, :list), Info.Nick = :name " +
+			"REMOVE Old ADD Tags :tags DELETE Tags :del"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.unused(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 6 {
+		t.Errorf("expected 6 changed attributes but got %v", changed)
+	}
+
+	err = upd(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *item["Count"].N != "2" {
+		t.Errorf("expected Count 2 but got %s", *item["Count"].N)
+	}
+	if *item["Total"].N != "-0.5" {
+		t.Errorf("expected Total -0.5 but got %s", *item["Total"].N)
+	}
+	if len(item["List"].L) != 2 || *item["List"].L[1].S != "y" {
+		t.Errorf("expected the list to be appended but got %v", item["List"])
+	}
+	if *item["Info"].M["Nick"].S != "name" {
+		t.Error("expected the nested attribute to be set")
+	}
+	if item["Old"] != nil {
+		t.Error("expected Old to be removed")
+	}
+	// the set actions are applied in order: ADD b, c and DELETE a
+	if !equal(item["Tags"], &dynamodb.AttributeValue{SS: aws.StringSlice([]string{"b", "c"})}) {
+		t.Errorf("got wrong tags %v", item["Tags"])
+	}
+}
+
+func TestProjection(t *testing.T) {
+	p, _ := newPlaceholders(map[string]*string{"#k": aws.String("Key")}, nil)
+	names, err := p.projection(aws.String("#k, Info.Age"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := project(attributes{"Key": s("k"), "Info": {M: attributes{}}, "Data": s("d")}, names)
+	if len(item) != 2 || item["Data"] != nil {
+		t.Errorf("got wrong projection %v", item)
+	}
+}
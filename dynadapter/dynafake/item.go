@@ -0,0 +1,336 @@
+package dynafake
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// check returns ConditionalCheckFailedException if the
+// condition is false for the existing item.
+func check(cond condition, old attributes) error {
+	if cond == nil {
+		return nil
+	}
+	if old == nil {
+		old = attributes{}
+	}
+	ok, err := cond(old)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return conditionalCheckFailed()
+	}
+	return nil
+}
+
+func (db *DB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	t, err := db.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.key(input.Key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := newPlaceholders(input.ExpressionAttributeNames, nil)
+	if err != nil {
+		return nil, err
+	}
+	names, err := p.projection(input.ProjectionExpression)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.unused(); err != nil {
+		return nil, err
+	}
+
+	out := &dynamodb.GetItemOutput{}
+	if item, ok := t.items[key]; ok {
+		out.Item = project(item, names)
+	}
+	return out, nil
+}
+
+func (db *DB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	t, err := db.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.key(input.Item, false)
+	if err != nil {
+		return nil, err
+	}
+	if itemSize(input.Item) > MaxItemSize {
+		return nil, validationError("Item size has exceeded the maximum allowed size")
+	}
+
+	p, err := newPlaceholders(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := p.condition(input.ConditionExpression)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.unused(); err != nil {
+		return nil, err
+	}
+
+	old := t.items[key]
+	if err := check(cond, old); err != nil {
+		return nil, err
+	}
+	t.items[key] = cloneItem(input.Item)
+
+	out := &dynamodb.PutItemOutput{}
+	switch aws.StringValue(input.ReturnValues) {
+	case "", dynamodb.ReturnValueNone:
+	case dynamodb.ReturnValueAllOld:
+		out.Attributes = cloneItem(old)
+	default:
+		return nil, validationError("ReturnValues can only be ALL_OLD or NONE")
+	}
+	return out, nil
+}
+
+func (db *DB) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	t, err := db.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.key(input.Key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := newPlaceholders(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := p.condition(input.ConditionExpression)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.unused(); err != nil {
+		return nil, err
+	}
+
+	old := t.items[key]
+	if err := check(cond, old); err != nil {
+		return nil, err
+	}
+	delete(t.items, key)
+
+	out := &dynamodb.DeleteItemOutput{}
+	switch aws.StringValue(input.ReturnValues) {
+	case "", dynamodb.ReturnValueNone:
+	case dynamodb.ReturnValueAllOld:
+		out.Attributes = cloneItem(old)
+	default:
+		return nil, validationError("ReturnValues can only be ALL_OLD or NONE")
+	}
+	return out, nil
+}
+
+// UpdateItem creates the item if it does not exist, like DynamoDB.
+func (db *DB) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	t, err := db.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.key(input.Key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := newPlaceholders(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	upd, changed, err := p.update(input.UpdateExpression)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := p.condition(input.ConditionExpression)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.unused(); err != nil {
+		return nil, err
+	}
+	if changed[t.hashKey] || (t.rangeKey != "" && changed[t.rangeKey]) {
+		return nil, validationError("Cannot update attribute %s. This attribute is part of the key", t.hashKey)
+	}
+
+	old := t.items[key]
+	if err := check(cond, old); err != nil {
+		return nil, err
+	}
+
+	item := cloneItem(old)
+	if item == nil {
+		item = cloneItem(input.Key)
+	}
+	err = upd(item)
+	if err != nil {
+		return nil, err
+	}
+	if itemSize(item) > MaxItemSize {
+		return nil, validationError("Item size to update has exceeded the maximum allowed size")
+	}
+	t.items[key] = item
+
+	out := &dynamodb.UpdateItemOutput{}
+	switch aws.StringValue(input.ReturnValues) {
+	case "", dynamodb.ReturnValueNone:
+	case dynamodb.ReturnValueAllOld:
+		out.Attributes = cloneItem(old)
+	case dynamodb.ReturnValueAllNew:
+		out.Attributes = cloneItem(item)
+	case dynamodb.ReturnValueUpdatedOld:
+		out.Attributes = project(old, changed)
+	case dynamodb.ReturnValueUpdatedNew:
+		out.Attributes = project(item, changed)
+	default:
+		return nil, validationError("unknown ReturnValues %q", aws.StringValue(input.ReturnValues))
+	}
+	if len(out.Attributes) == 0 {
+		out.Attributes = nil
+	}
+	return out, nil
+}
+
+// BatchGetItem returns every item at once, so
+// UnprocessedKeys is always empty.
+func (db *DB) BatchGetItem(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	var count int
+	for _, request := range input.RequestItems {
+		count += len(request.Keys)
+	}
+	if count == 0 || count > 100 {
+		return nil, validationError("Too many items requested for the BatchGetItem call")
+	}
+
+	out := &dynamodb.BatchGetItemOutput{
+		Responses:       make(map[string][]map[string]*dynamodb.AttributeValue),
+		UnprocessedKeys: make(map[string]*dynamodb.KeysAndAttributes),
+	}
+	for name, request := range input.RequestItems {
+		t, err := db.table(aws.String(name))
+		if err != nil {
+			return nil, err
+		}
+
+		p, err := newPlaceholders(request.ExpressionAttributeNames, nil)
+		if err != nil {
+			return nil, err
+		}
+		names, err := p.projection(request.ProjectionExpression)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.unused(); err != nil {
+			return nil, err
+		}
+
+		seen := make(map[string]bool)
+		for _, k := range request.Keys {
+			key, err := t.key(k, true)
+			if err != nil {
+				return nil, err
+			}
+			if seen[key] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			}
+			seen[key] = true
+
+			if item, ok := t.items[key]; ok {
+				out.Responses[name] = append(out.Responses[name], project(item, names))
+			}
+		}
+	}
+	return out, nil
+}
+
+// BatchWriteItem writes every item at once, so
+// UnprocessedItems is always empty.
+func (db *DB) BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	var count int
+	for _, requests := range input.RequestItems {
+		count += len(requests)
+	}
+	if count == 0 || count > 25 {
+		return nil, validationError("Too many items requested for the BatchWriteItem call")
+	}
+
+	// everything is validated before the first write
+	type write struct {
+		t    *table
+		key  string
+		item attributes
+	}
+	var writes []write
+	for name, requests := range input.RequestItems {
+		t, err := db.table(aws.String(name))
+		if err != nil {
+			return nil, err
+		}
+
+		seen := make(map[string]bool)
+		for _, request := range requests {
+			var w write
+			switch {
+			case request.PutRequest != nil:
+				w = write{t: t, item: request.PutRequest.Item}
+				w.key, err = t.key(w.item, false)
+				if err == nil && itemSize(w.item) > MaxItemSize {
+					err = validationError("Item size has exceeded the maximum allowed size")
+				}
+			case request.DeleteRequest != nil:
+				w = write{t: t}
+				w.key, err = t.key(request.DeleteRequest.Key, true)
+			default:
+				err = validationError("Supplied AttributeValue has more than one datatypes set")
+			}
+			if err != nil {
+				return nil, err
+			}
+			if seen[w.key] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			}
+			seen[w.key] = true
+			writes = append(writes, w)
+		}
+	}
+
+	for _, w := range writes {
+		if w.item != nil {
+			w.t.items[w.key] = cloneItem(w.item)
+		} else {
+			delete(w.t.items, w.key)
+		}
+	}
+	return &dynamodb.BatchWriteItemOutput{
+		UnprocessedItems: make(map[string][]*dynamodb.WriteRequest),
+	}, nil
+}
@@ -0,0 +1,198 @@
+package dynafake
+
+import (
+	"hash/fnv"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// page evaluates the items in order, starting at the position
+// after the exclusive start key. Limit counts the evaluated items
+// before the filter, like in DynamoDB. LastEvaluatedKey is only set
+// if there are more items, while DynamoDB can also return it for the
+// last page.
+//
+// after reports whether the key comes after the start key in the
+// order of the keys. The item of the start key may have been deleted
+// since the last page, so its position is searched instead of matched.
+func (t *table) page(keys []string, start attributes, after func(startKey string, start attributes, key string) bool, limit *int64, filter condition, names map[string]bool) ([]map[string]*dynamodb.AttributeValue, attributes, error) {
+	if start != nil {
+		startKey, err := t.key(start, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		i := sort.Search(len(keys), func(i int) bool {
+			return after(startKey, start, keys[i])
+		})
+		keys = keys[i:]
+	}
+
+	var items []map[string]*dynamodb.AttributeValue
+	for i, key := range keys {
+		if limit != nil && int64(i) >= *limit {
+			return items, t.keyOf(t.items[keys[i-1]]), nil
+		}
+
+		item := t.items[key]
+		if filter != nil {
+			ok, err := filter(item)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		items = append(items, project(item, names))
+	}
+	return items, nil, nil
+}
+
+// Scan returns the items in the order of their key. With
+// TotalSegments every item is in the segment of the hash of its key.
+func (db *DB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	t, err := db.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if input.IndexName != nil {
+		return nil, validationError("indexes are not supported by dynafake")
+	}
+	if input.Limit != nil && *input.Limit < 1 {
+		return nil, validationError("Limit must be greater than or equal to 1")
+	}
+
+	p, err := newPlaceholders(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := p.condition(input.FilterExpression)
+	if err != nil {
+		return nil, err
+	}
+	names, err := p.projection(input.ProjectionExpression)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.unused(); err != nil {
+		return nil, err
+	}
+
+	keys := t.sortedKeys()
+	if input.TotalSegments != nil {
+		segment, total := aws.Int64Value(input.Segment), *input.TotalSegments
+		if total < 1 || segment < 0 || segment >= total {
+			return nil, validationError("Segment must be less than TotalSegments")
+		}
+
+		var inSegment []string
+		for _, key := range keys {
+			h := fnv.New32a()
+			h.Write([]byte(encodeKey(t.items[key][t.hashKey])))
+			if int64(h.Sum32())%total == segment {
+				inSegment = append(inSegment, key)
+			}
+		}
+		keys = inSegment
+	}
+
+	after := func(startKey string, _ attributes, key string) bool {
+		return key > startKey
+	}
+	items, last, err := t.page(keys, input.ExclusiveStartKey, after, input.Limit, filter, names)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.ScanOutput{
+		Items:            items,
+		Count:            aws.Int64(int64(len(items))),
+		LastEvaluatedKey: last,
+	}, nil
+}
+
+// Query returns the items whose key matches the key condition,
+// sorted by the range key.
+func (db *DB) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	t, err := db.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if input.IndexName != nil {
+		return nil, validationError("indexes are not supported by dynafake")
+	}
+	if input.KeyConditionExpression == nil {
+		return nil, validationError("Either the KeyConditions or KeyConditionExpression parameter must be specified in the request")
+	}
+	if input.Limit != nil && *input.Limit < 1 {
+		return nil, validationError("Limit must be greater than or equal to 1")
+	}
+
+	p, err := newPlaceholders(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	keyCond, err := p.condition(input.KeyConditionExpression)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := p.condition(input.FilterExpression)
+	if err != nil {
+		return nil, err
+	}
+	names, err := p.projection(input.ProjectionExpression)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.unused(); err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for key, item := range t.items {
+		ok, err := keyCond(t.keyOf(item))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			keys = append(keys, key)
+		}
+	}
+	forward := input.ScanIndexForward == nil || *input.ScanIndexForward
+	less := func(keyA string, a attributes, keyB string, b attributes) bool {
+		n := 0
+		if t.rangeKey != "" {
+			n, _ = compare(a[t.rangeKey], b[t.rangeKey])
+		}
+		if n == 0 {
+			return keyA < keyB
+		}
+		if !forward {
+			return n > 0
+		}
+		return n < 0
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return less(keys[i], t.items[keys[i]], keys[j], t.items[keys[j]])
+	})
+
+	after := func(startKey string, start attributes, key string) bool {
+		return less(startKey, start, key, t.items[key])
+	}
+	items, last, err := t.page(keys, input.ExclusiveStartKey, after, input.Limit, filter, names)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.QueryOutput{
+		Items:            items,
+		Count:            aws.Int64(int64(len(items))),
+		LastEvaluatedKey: last,
+	}, nil
+}
@@ -0,0 +1,291 @@
+package dynafake
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+type attributes = map[string]*dynamodb.AttributeValue
+
+// typeOf returns the type descriptor of the value, like "S" or "NS".
+func typeOf(v *dynamodb.AttributeValue) string {
+	switch {
+	case v == nil:
+		return ""
+	case v.S != nil:
+		return "S"
+	case v.N != nil:
+		return "N"
+	case v.B != nil:
+		return "B"
+	case v.BOOL != nil:
+		return "BOOL"
+	case v.NULL != nil:
+		return "NULL"
+	case v.M != nil:
+		return "M"
+	case v.L != nil:
+		return "L"
+	case v.SS != nil:
+		return "SS"
+	case v.NS != nil:
+		return "NS"
+	case v.BS != nil:
+		return "BS"
+	}
+	return ""
+}
+
+func parseNumber(s string) (*big.Rat, bool) {
+	return new(big.Rat).SetString(s)
+}
+
+func formatNumber(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	s := r.FloatString(38)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// compare orders two values of the same scalar type (S, N or B).
+// ok is false if the values can't be ordered.
+func compare(a, b *dynamodb.AttributeValue) (n int, ok bool) {
+	t := typeOf(a)
+	if t != typeOf(b) {
+		return 0, false
+	}
+
+	switch t {
+	case "S":
+		return strings.Compare(*a.S, *b.S), true
+	case "B":
+		return bytes.Compare(a.B, b.B), true
+	case "N":
+		x, okA := parseNumber(*a.N)
+		y, okB := parseNumber(*b.N)
+		if !okA || !okB {
+			return 0, false
+		}
+		return x.Cmp(y), true
+	}
+	return 0, false
+}
+
+// equal compares two values like DynamoDB: numbers by their value
+// and sets without their order.
+func equal(a, b *dynamodb.AttributeValue) bool {
+	t := typeOf(a)
+	if t != typeOf(b) {
+		return false
+	}
+
+	switch t {
+	case "S", "N", "B":
+		n, ok := compare(a, b)
+		return ok && n == 0
+	case "BOOL":
+		return *a.BOOL == *b.BOOL
+	case "NULL":
+		return true
+	case "M":
+		if len(a.M) != len(b.M) {
+			return false
+		}
+		for name, v := range a.M {
+			if !equal(v, b.M[name]) {
+				return false
+			}
+		}
+		return true
+	case "L":
+		if len(a.L) != len(b.L) {
+			return false
+		}
+		for i := range a.L {
+			if !equal(a.L[i], b.L[i]) {
+				return false
+			}
+		}
+		return true
+	case "SS", "NS", "BS":
+		x, y := setMembers(a), setMembers(b)
+		if len(x) != len(y) {
+			return false
+		}
+		for _, member := range x {
+			if !containsMember(y, member) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// setMembers returns the members of a set as scalar values.
+func setMembers(v *dynamodb.AttributeValue) []*dynamodb.AttributeValue {
+	var members []*dynamodb.AttributeValue
+	for _, s := range v.SS {
+		members = append(members, &dynamodb.AttributeValue{S: s})
+	}
+	for _, n := range v.NS {
+		members = append(members, &dynamodb.AttributeValue{N: n})
+	}
+	for _, b := range v.BS {
+		members = append(members, &dynamodb.AttributeValue{B: b})
+	}
+	return members
+}
+
+func containsMember(members []*dynamodb.AttributeValue, v *dynamodb.AttributeValue) bool {
+	for _, member := range members {
+		if equal(member, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// newSet builds a set of the type from scalar members.
+func newSet(t string, members []*dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	set := &dynamodb.AttributeValue{}
+	for _, member := range members {
+		switch t {
+		case "SS":
+			set.SS = append(set.SS, member.S)
+		case "NS":
+			set.NS = append(set.NS, member.N)
+		case "BS":
+			set.BS = append(set.BS, member.B)
+		}
+	}
+	return set
+}
+
+// clone copies the value, so that the caller and the
+// table never share slices or maps.
+func clone(v *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	if v == nil {
+		return nil
+	}
+
+	c := &dynamodb.AttributeValue{}
+	if v.S != nil {
+		c.S = aws.String(*v.S)
+	}
+	if v.N != nil {
+		c.N = aws.String(*v.N)
+	}
+	if v.B != nil {
+		c.B = append([]byte{}, v.B...)
+	}
+	if v.BOOL != nil {
+		c.BOOL = aws.Bool(*v.BOOL)
+	}
+	if v.NULL != nil {
+		c.NULL = aws.Bool(*v.NULL)
+	}
+	if v.M != nil {
+		c.M = cloneItem(v.M)
+	}
+	if v.L != nil {
+		c.L = make([]*dynamodb.AttributeValue, len(v.L))
+		for i, e := range v.L {
+			c.L[i] = clone(e)
+		}
+	}
+	for _, s := range v.SS {
+		c.SS = append(c.SS, aws.String(*s))
+	}
+	for _, n := range v.NS {
+		c.NS = append(c.NS, aws.String(*n))
+	}
+	for _, b := range v.BS {
+		c.BS = append(c.BS, append([]byte{}, b...))
+	}
+	return c
+}
+
+func cloneItem(item attributes) attributes {
+	if item == nil {
+		return nil
+	}
+	c := make(attributes, len(item))
+	for name, v := range item {
+		c[name] = clone(v)
+	}
+	return c
+}
+
+// size approximates the size of the value like DynamoDB does.
+func size(v *dynamodb.AttributeValue) int {
+	switch typeOf(v) {
+	case "S":
+		return len(*v.S)
+	case "N":
+		return (len(*v.N)+1)/2 + 1
+	case "B":
+		return len(v.B)
+	case "BOOL", "NULL":
+		return 1
+	case "M":
+		n := 3
+		for name, e := range v.M {
+			n += len(name) + size(e) + 1
+		}
+		return n
+	case "L":
+		n := 3
+		for _, e := range v.L {
+			n += size(e) + 1
+		}
+		return n
+	default:
+		var n int
+		for _, member := range setMembers(v) {
+			n += size(member)
+		}
+		return n
+	}
+}
+
+func itemSize(item attributes) int {
+	var n int
+	for name, v := range item {
+		n += len(name) + size(v)
+	}
+	return n
+}
+
+// encodeKey returns a string that identifies the value of a key
+// attribute. Numbers are normalized, so 1 and 1.0 are the same key.
+func encodeKey(v *dynamodb.AttributeValue) string {
+	switch typeOf(v) {
+	case "S":
+		return "S" + *v.S
+	case "N":
+		r, _ := parseNumber(*v.N)
+		return "N" + formatNumber(r)
+	case "B":
+		return "B" + hex.EncodeToString(v.B)
+	}
+	return ""
+}
+
+// sortedNames returns the names of the map in order.
+func sortedNames(m map[string]bool) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
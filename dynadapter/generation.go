@@ -0,0 +1,106 @@
+package dynadapter
+
+import (
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// ReservedPrefix starts the keys of the items in the table that are
+// not cache items, like the generation and the locks of the lock
+// package. Scan, DelPrefix, Clear and Dump skip these items, so
+// keys of the cache should not start with it (see cache.NewKey).
+const ReservedPrefix = cache.ReservedPrefix
+
+// GenerationKey is the key of the item that holds the
+// current generation (see WithGenerations).
+const GenerationKey = ReservedPrefix + "generation"
+
+type generationItem struct {
+	Generation int64
+}
+
+func generationKey() map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Key": {S: aws.String(GenerationKey)},
+	}
+}
+
+// generation returns the current generation. It is zero
+// if generations are not used or the cache was never cleared.
+func (a *Adapter) generation() (int64, error) {
+	if !a.generations {
+		return 0, nil
+	}
+
+	a.genM.Lock()
+	defer a.genM.Unlock()
+
+	if !a.genFetched.IsZero() && a.now().Sub(a.genFetched) < a.refresh {
+		return a.gen, nil
+	}
+
+	result, err := a.client.GetItem(&dynamodb.GetItemInput{
+		TableName:      &a.table,
+		Key:            generationKey(),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var g generationItem
+	err = dynamodbattribute.UnmarshalMap(result.Item, &g)
+	if err != nil {
+		return 0, err
+	}
+
+	a.gen = g.Generation
+	a.genFetched = a.now()
+	return a.gen, nil
+}
+
+// nextGeneration increments the generation, which
+// invalidates every item that was set before.
+func (a *Adapter) nextGeneration() error {
+	result, err := a.client.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:        &a.table,
+		Key:              generationKey(),
+		UpdateExpression: aws.String("ADD #g :one"),
+		ExpressionAttributeNames: map[string]*string{
+			"#g": aws.String("Generation"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":one": {N: aws.String("1")},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueUpdatedNew),
+	})
+	if err != nil {
+		return err
+	}
+
+	var g generationItem
+	err = dynamodbattribute.UnmarshalMap(result.Attributes, &g)
+	if err != nil {
+		return err
+	}
+
+	a.genM.Lock()
+	a.gen = g.Generation
+	a.genFetched = a.now()
+	a.genM.Unlock()
+	return nil
+}
+
+// Clear removes every item. With WithGenerations the items are only
+// invalidated, otherwise the table is scanned and every item is deleted
+// (limited by WithDeleteRate).
+func (a *Adapter) Clear() error {
+	if a.generations {
+		return a.nextGeneration()
+	}
+
+	_, err := a.delScanned(cache.ScanOptions{IncludeExpired: true})
+	return err
+}
@@ -0,0 +1,107 @@
+package dynadapter
+
+import (
+	"testing"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestClear_Generations(t *testing.T) {
+	var generation = "1"
+	mockSvc := &mockDynamoDBClient{
+		GetItemFunc: func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			if *input.Key["Key"].S == GenerationKey {
+				return &dynamodb.GetItemOutput{
+					Item: map[string]*dynamodb.AttributeValue{
+						"Key":        {S: aws.String(GenerationKey)},
+						"Generation": {N: aws.String(generation)},
+					},
+				}, nil
+			}
+			return &dynamodb.GetItemOutput{
+				Item: map[string]*dynamodb.AttributeValue{
+					"Key":  {S: aws.String("1")},
+					"TTL":  {N: aws.String("4102444800")},
+					"Gen":  {N: aws.String("1")},
+					"Data": {B: []byte("data")},
+				},
+			}, nil
+		},
+		UpdateItemFunc: func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			if *input.UpdateExpression != "ADD #g :one" {
+				t.Error("expected generation to be incremented")
+			}
+			generation = "2"
+			return &dynamodb.UpdateItemOutput{
+				Attributes: map[string]*dynamodb.AttributeValue{
+					"Generation": {N: aws.String(generation)},
+				},
+			}, nil
+		},
+		PutItemFunc: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			if *input.Item["Gen"].N != "2" {
+				t.Error("expected item to have the new generation")
+			}
+			if *input.ConditionExpression != "attribute_not_exists(#k) OR #t < :now OR attribute_not_exists(#g) OR #g <> :gen" {
+				t.Errorf("wrong condition expression: %s", *input.ConditionExpression)
+			}
+			return nil, nil
+		},
+	}
+
+	c, err := New(mockSvc, "TestCache", time.Hour, WithGenerations(time.Minute))()
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = c.Get("1")
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = c.(cache.Clearer).Clear()
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = c.Get("1")
+	if err != cache.ErrNotFound {
+		t.Error(err)
+	}
+
+	err = c.(cache.Adder).Add("1", []byte("data"))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClear_Scan(t *testing.T) {
+	var deleted int
+	mockSvc := &mockDynamoDBClient{
+		ScanFunc: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			if *input.FilterExpression != "NOT begins_with(#k, :reserved)" {
+				t.Errorf("expected only the reserved filter: %s", *input.FilterExpression)
+			}
+			return &dynamodb.ScanOutput{
+				Items: []map[string]*dynamodb.AttributeValue{keyItem("1"), keyItem("2")},
+			}, nil
+		},
+		BatchWriteItemFunc: func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			deleted += len(input.RequestItems["TestCache"])
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+
+	c, err := New(mockSvc, "TestCache", time.Hour)()
+	if err != nil {
+		t.Error(err)
+	}
+	err = c.(cache.Clearer).Clear()
+	if err != nil {
+		t.Error(err)
+	}
+	if deleted != 2 {
+		t.Errorf("expected 2 deleted items but got %d", deleted)
+	}
+}
@@ -1,17 +1,34 @@
 package dynadapter
 
 import (
+	"context"
+	"strconv"
+	"strings"
+
 	"github.com/JohannesKaufmann/dynamodb-cache"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
 type item struct {
-	Key  string
-	TTL  int64  `json:",omitempty"`
-	Data []byte `json:",omitempty"`
+	Key     string
+	TTL     int64  `json:",omitempty"`
+	Created int64  `json:",omitempty"`
+	Gen     int64  `json:",omitempty"`
+	Data    []byte `json:",omitempty"`
+
+	// the manifest of a value that is split into chunks
+	Chunks   int    `json:",omitempty"`
+	Version  string `json:",omitempty"`
+	Checksum string `json:",omitempty"`
+	// Object is the key in the object store (see WithObjectStore)
+	Object string `json:",omitempty"`
+	// Chunk marks the items that hold a part of a value
+	Chunk bool `json:",omitempty"`
 }
 
 func (i *item) marshal() (map[string]*dynamodb.AttributeValue, error) {
@@ -21,22 +38,273 @@ func (i *item) unmarshal(data map[string]*dynamodb.AttributeValue) error {
 	return dynamodbattribute.UnmarshalMap(data, i)
 }
 
-func (i *item) put(client dynamodbiface.DynamoDBAPI, table string) error {
+// oldItem returns the item that was replaced or deleted. It is
+// empty if there was none.
+func oldItem(attributes map[string]*dynamodb.AttributeValue) (item, error) {
+	var old item
+	if len(attributes) == 0 {
+		return old, nil
+	}
+	err := old.unmarshal(attributes)
+	return old, err
+}
+
+// put returns the item that was replaced.
+func (i *item) put(client dynamodbiface.DynamoDBAPI, table string) (item, error) {
+	av, err := i.marshal()
+	if err != nil {
+		return item{}, err
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:         av,
+		TableName:    &table,
+		ReturnValues: aws.String(dynamodb.ReturnValueAllOld),
+	}
+
+	result, err := client.PutItem(input)
+	if err != nil || result == nil {
+		return item{}, err
+	}
+	return oldItem(result.Attributes)
+}
+
+// condition decides whether an existing item is still valid.
+type condition struct {
+	now int64
+	// expires checks the TTL of the item.
+	expires bool
+	// gen checks the generation of the item if it is above zero.
+	gen int64
+	// noObject only accepts items whose value is not in the object store.
+	noObject bool
+}
+
+// expression holds the parts of a condition expression.
+type expression struct {
+	parts  []string
+	names  map[string]*string
+	values map[string]*dynamodb.AttributeValue
+}
+
+func newExpression() *expression {
+	return &expression{
+		names:  make(map[string]*string),
+		values: make(map[string]*dynamodb.AttributeValue),
+	}
+}
+
+func (e *expression) add(part string) {
+	e.parts = append(e.parts, part)
+}
+func (e *expression) name(placeholder, name string) {
+	e.names[placeholder] = aws.String(name)
+}
+func (e *expression) number(placeholder string, n int64) {
+	e.values[placeholder] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(n, 10))}
+}
+
+func (e *expression) join(sep string) *string {
+	return aws.String(strings.Join(e.parts, sep))
+}
+func (e *expression) valueMap() map[string]*dynamodb.AttributeValue {
+	if len(e.values) == 0 {
+		return nil
+	}
+	return e.values
+}
+
+// valid adds the parts that are true if the item exists and is
+// still valid. They need to be joined with AND.
+func (c condition) valid(e *expression) {
+	if c.expires {
+		e.add("#t >= :now")
+		e.name("#t", "TTL")
+		e.number(":now", c.now)
+	}
+	if c.gen > 0 {
+		e.add("#g = :gen")
+		e.name("#g", "Gen")
+		e.number(":gen", c.gen)
+	}
+	if c.noObject {
+		e.add("attribute_not_exists(#o)")
+		e.name("#o", "Object")
+	}
+}
+
+// validItem checks the TTL and the generation
+// like valid, but of an item that was read.
+func (c condition) validItem(i item) bool {
+	if c.expires && i.TTL < c.now {
+		return false
+	}
+	return c.gen <= 0 || i.Gen == c.gen
+}
+
+// invalid adds the parts that are true if the item does not exist
+// or is not valid anymore. They need to be joined with OR.
+func (c condition) invalid(e *expression) {
+	e.add("attribute_not_exists(#k)")
+	e.name("#k", "Key")
+	if c.expires {
+		e.add("#t < :now")
+		e.name("#t", "TTL")
+		e.number(":now", c.now)
+	}
+	if c.gen > 0 {
+		e.add("attribute_not_exists(#g)")
+		e.add("#g <> :gen")
+		e.name("#g", "Gen")
+		e.number(":gen", c.gen)
+	}
+}
+
+// add puts the item only if there is no valid item with that key.
+func (i *item) add(client dynamodbiface.DynamoDBAPI, table string, cond condition) error {
 	item, err := i.marshal()
 	if err != nil {
 		return err
 	}
 
+	e := newExpression()
+	cond.invalid(e)
+
 	input := &dynamodb.PutItemInput{
-		Item:      item,
-		TableName: &table,
+		Item:                      item,
+		TableName:                 &table,
+		ConditionExpression:       e.join(" OR "),
+		ExpressionAttributeNames:  e.names,
+		ExpressionAttributeValues: e.valueMap(),
 	}
 
 	_, err = client.PutItem(input)
+	if isConditionalCheckFailed(err) {
+		return cache.ErrAlreadyExists
+	}
 	return err
 }
 
+// swap puts the item only if the existing item is valid and
+// still has the old data. If the old data is large oldSum
+// is its checksum, which is compared instead. It returns
+// the item that was replaced.
+func (i *item) swap(client dynamodbiface.DynamoDBAPI, table string, old []byte, oldSum string, cond condition) (item, error) {
+	av, err := i.marshal()
+	if err != nil {
+		return item{}, err
+	}
+
+	e := newExpression()
+	if oldSum != "" {
+		e.add("#s = :old")
+		e.name("#s", "Checksum")
+		e.values[":old"] = &dynamodb.AttributeValue{S: aws.String(oldSum)}
+	} else {
+		e.add("#d = :old")
+		e.name("#d", "Data")
+		e.values[":old"] = &dynamodb.AttributeValue{B: old}
+	}
+	cond.valid(e)
+
+	input := &dynamodb.PutItemInput{
+		Item:                      av,
+		TableName:                 &table,
+		ConditionExpression:       e.join(" AND "),
+		ExpressionAttributeNames:  e.names,
+		ExpressionAttributeValues: e.valueMap(),
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllOld),
+	}
+
+	result, err := client.PutItem(input)
+	if isConditionalCheckFailed(err) {
+		return item{}, cache.ErrConflict
+	}
+	if err != nil || result == nil {
+		return item{}, err
+	}
+	return oldItem(result.Attributes)
+}
+
+// touch only updates the TTL attribute of the item, so that the
+// data is not written again. An invalid item is treated as not
+// existing. It returns the updated item.
+func (i *item) touch(client dynamodbiface.DynamoDBAPI, table string, cond condition) (item, error) {
+	key, err := dynamodbattribute.MarshalMap(item{Key: i.Key})
+	if err != nil {
+		return item{}, err
+	}
+
+	e := newExpression()
+	e.add("attribute_exists(#k)")
+	e.name("#k", "Key")
+	cond.valid(e)
+	e.name("#t", "TTL")
+	e.number(":ttl", i.TTL)
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 &table,
+		Key:                       key,
+		UpdateExpression:          aws.String("SET #t = :ttl"),
+		ConditionExpression:       e.join(" AND "),
+		ExpressionAttributeNames:  e.names,
+		ExpressionAttributeValues: e.valueMap(),
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	}
+
+	result, err := client.UpdateItem(input)
+	if isConditionalCheckFailed(err) {
+		return item{}, cache.ErrNotFound
+	}
+	if err != nil || result == nil {
+		return item{}, err
+	}
+	return oldItem(result.Attributes)
+}
+
+// scan returns the keys of one page. If next is empty
+// there are no more pages.
+func scan(client dynamodbiface.DynamoDBAPI, input *dynamodb.ScanInput) (keys []string, next map[string]*dynamodb.AttributeValue, err error) {
+	items, next, err := scanItems(client, input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, i := range items {
+		keys = append(keys, i.Key)
+	}
+	return keys, next, nil
+}
+
+// scanItems returns the items of one page with
+// the attributes of the projection.
+func scanItems(client dynamodbiface.DynamoDBAPI, input *dynamodb.ScanInput) (items []item, next map[string]*dynamodb.AttributeValue, err error) {
+	result, err := client.Scan(input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, attributes := range result.Items {
+		var i item
+		err = i.unmarshal(attributes)
+		if err != nil {
+			return nil, nil, err
+		}
+		items = append(items, i)
+	}
+	return items, result.LastEvaluatedKey, nil
+}
+
+func isConditionalCheckFailed(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
+
 func (i *item) get(client dynamodbiface.DynamoDBAPI, table string) error {
+	return i.getContext(context.Background(), client, table)
+}
+
+func (i *item) getContext(ctx context.Context, client dynamodbiface.DynamoDBAPI, table string) error {
 	key, err := i.marshal()
 	if err != nil {
 		return err
@@ -47,7 +315,7 @@ func (i *item) get(client dynamodbiface.DynamoDBAPI, table string) error {
 		Key:       key,
 	}
 
-	result, err := client.GetItem(input)
+	result, err := client.GetItemWithContext(ctx, input)
 	if err != nil {
 		return err
 	}
@@ -59,16 +327,21 @@ func (i *item) get(client dynamodbiface.DynamoDBAPI, table string) error {
 	return i.unmarshal(result.Item)
 }
 
-func (i item) del(client dynamodbiface.DynamoDBAPI, table string) error {
+// del returns the item that was deleted.
+func (i item) del(client dynamodbiface.DynamoDBAPI, table string) (item, error) {
 	key, err := i.marshal()
 	if err != nil {
-		return err
+		return item{}, err
 	}
 
 	input := &dynamodb.DeleteItemInput{
-		TableName: &table,
-		Key:       key,
+		TableName:    &table,
+		Key:          key,
+		ReturnValues: aws.String(dynamodb.ReturnValueAllOld),
 	}
-	_, err = client.DeleteItem(input)
-	return err
+	result, err := client.DeleteItem(input)
+	if err != nil || result == nil {
+		return item{}, err
+	}
+	return oldItem(result.Attributes)
 }
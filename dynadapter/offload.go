@@ -0,0 +1,124 @@
+package dynadapter
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// ObjectStore saves large values outside of DynamoDB, for
+// example in S3 (see NewS3Store) or any S3-compatible storage.
+type ObjectStore interface {
+	// PutObject saves the data. expires is zero if the
+	// item does not expire.
+	PutObject(key string, data []byte, expires time.Time) error
+	// GetObject returns cache.ErrNotFound if there is no object.
+	GetObject(key string) ([]byte, error)
+	DeleteObject(key string) error
+}
+
+// WithObjectStore saves values that are larger than threshold
+// (in bytes) in the object store. The item in the table only holds
+// the key of the object and a checksum, which needs far less write
+// capacity than chunks.
+//
+// The object is deleted when the item is deleted or replaced, also by
+// DelPrefix and Clear. Items that expire are deleted by DynamoDB, so
+// their objects need to be removed by a lifecycle rule of the bucket.
+// Because the rule uses the ttl of the adapter, SetWithTTL and Touch
+// return ErrObjectTTL for a longer ttl of a value in the object store.
+func WithObjectStore(store ObjectStore, threshold int) Option {
+	return func(a *Adapter) {
+		a.objects = store
+		a.objectSize = threshold
+	}
+}
+
+// ErrObjectTTL is returned by SetWithTTL and Touch if a value in the
+// object store would outlive the ttl of the adapter, after which the
+// lifecycle rule of the bucket deletes its object.
+var ErrObjectTTL = errors.New("dynamodb: ttl of a value in the object store is longer than the ttl of the adapter")
+
+// outlivesObjects returns true if an item with that ttl
+// can't be in the object store.
+func (a *Adapter) outlivesObjects(ttl time.Duration) bool {
+	return a.objects != nil && a.ttl != -1 && ttl > a.ttl
+}
+
+// offload puts the data of the item into the object store
+// and returns the manifest, which still needs to be put.
+func (a *Adapter) offload(i item, version string) (item, error) {
+	object := i.Key + "#" + version
+
+	var expires time.Time
+	if a.ttl != -1 {
+		expires = time.Unix(i.TTL, 0)
+	}
+	err := a.objects.PutObject(object, i.Data, expires)
+	if err != nil {
+		return item{}, err
+	}
+
+	manifest := i
+	manifest.Data = nil
+	manifest.Version = version
+	manifest.Checksum = checksum(i.Data)
+	manifest.Object = object
+	return manifest, nil
+}
+
+// fetch reads the data of the manifest from the object store.
+func (a *Adapter) fetch(manifest item) ([]byte, error) {
+	if a.objects == nil {
+		return nil, errors.New("dynamodb: value is in an object store but there is none")
+	}
+
+	data, err := a.objects.GetObject(manifest.Object)
+	if err == cache.ErrNotFound {
+		return nil, errMissingPart
+	} else if err != nil {
+		return nil, err
+	}
+
+	if checksum(data) != manifest.Checksum {
+		return nil, ErrChecksum
+	}
+	return data, nil
+}
+
+type memoryObjects struct {
+	objects map[string][]byte
+	m       sync.RWMutex
+}
+
+// NewMemoryObjectStore returns an object store for tests.
+func NewMemoryObjectStore() ObjectStore {
+	return &memoryObjects{objects: make(map[string][]byte)}
+}
+
+func (s *memoryObjects) PutObject(key string, data []byte, expires time.Time) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.objects[key] = append([]byte(nil), data...)
+	return nil
+}
+func (s *memoryObjects) GetObject(key string) ([]byte, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, cache.ErrNotFound
+	}
+	return data, nil
+}
+func (s *memoryObjects) DeleteObject(key string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	delete(s.objects, key)
+	return nil
+}
@@ -0,0 +1,187 @@
+package dynadapter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/JohannesKaufmann/dynamodb-cache/dynadapter/dynafake"
+)
+
+func TestObjectStore(t *testing.T) {
+	items := make(map[string]map[string]*dynamodb.AttributeValue)
+	store := NewMemoryObjectStore()
+	objects := store.(*memoryObjects).objects
+
+	a, err := New(newTableMock(items), "TestCache", time.Hour, WithChunkSize(4), WithObjectStore(store, 8))()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// between the chunk size and the threshold
+	err = a.Set("chunked", []byte("012345"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if countChunks(items) != 2 || len(objects) != 0 {
+		t.Error("expected the value to be split into chunks")
+	}
+
+	err = a.Set("large", []byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 {
+		t.Errorf("expected 1 object but got %d", len(objects))
+	}
+	if items["large"]["Data"] != nil || items["large"]["Object"] == nil {
+		t.Error("expected only a pointer to the object in the item")
+	}
+
+	data, err := a.Get("large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "0123456789" {
+		t.Errorf("got wrong data '%s'", data)
+	}
+
+	// the object of the replaced value is deleted
+	err = a.Set("large", []byte("abcdefghij"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 {
+		t.Errorf("expected 1 object but got %d", len(objects))
+	}
+
+	err = a.Del("large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("expected the object to be deleted but got %d", len(objects))
+	}
+}
+
+func TestObjectStore_Missing(t *testing.T) {
+	items := make(map[string]map[string]*dynamodb.AttributeValue)
+	store := NewMemoryObjectStore()
+
+	a, err := New(newTableMock(items), "TestCache", time.Hour, WithObjectStore(store, 4))()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = a.Set("large", []byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.DeleteObject(*items["large"]["Object"].S)
+
+	_, err = a.Get("large")
+	if err != cache.ErrNotFound {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+}
+
+func TestObjectStore_Checksum(t *testing.T) {
+	items := make(map[string]map[string]*dynamodb.AttributeValue)
+	store := NewMemoryObjectStore()
+
+	a, err := New(newTableMock(items), "TestCache", time.Hour, WithObjectStore(store, 4))()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = a.Set("large", []byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.PutObject(*items["large"]["Object"].S, []byte("corrupted"), time.Time{})
+
+	_, err = a.Get("large")
+	if err != ErrChecksum {
+		t.Errorf("expected ErrChecksum but got %v", err)
+	}
+}
+
+func TestObjectStore_TTL(t *testing.T) {
+	db := dynafake.New()
+	db.AddTable("TestCache", "Key")
+
+	a, err := New(db, "TestCache", time.Hour, WithObjectStore(NewMemoryObjectStore(), 4))()
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter := a.(*Adapter)
+
+	// the lifecycle rule would delete the object after an hour
+	err = adapter.SetWithTTL("large", []byte("0123456789"), time.Hour*2)
+	if err != ErrObjectTTL {
+		t.Errorf("expected ErrObjectTTL but got %v", err)
+	}
+	err = adapter.SetWithTTL("small", []byte("abc"), time.Hour*2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = adapter.Set("large", []byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adapter.Touch("large", time.Hour*2)
+	if err != ErrObjectTTL {
+		t.Errorf("expected ErrObjectTTL but got %v", err)
+	}
+	err = adapter.Touch("large", time.Minute)
+	if err != nil {
+		t.Error(err)
+	}
+	err = adapter.Touch("small", time.Hour*3)
+	if err != nil {
+		t.Error(err)
+	}
+	err = adapter.Touch("missing", time.Hour*2)
+	if err != cache.ErrNotFound {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+}
+
+func TestObjectStore_DelPrefix(t *testing.T) {
+	db := dynafake.New()
+	db.AddTable("TestCache", "Key")
+	store := NewMemoryObjectStore()
+	objects := store.(*memoryObjects).objects
+
+	a, err := New(db, "TestCache", time.Hour, WithObjectStore(store, 4))()
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter := a.(*Adapter)
+
+	for _, key := range []string{"a:1", "a:2", "b:1"} {
+		err = adapter.Set(key, []byte("0123456789"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = adapter.DelPrefix("a:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 {
+		t.Errorf("expected the objects of the deleted items to be deleted but got %d", len(objects))
+	}
+
+	err = adapter.Clear()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("expected every object to be deleted but got %d", len(objects))
+	}
+}
@@ -0,0 +1,68 @@
+package dynadapter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// ObjectTag is added to every object, so that a lifecycle rule
+// of the bucket can delete the objects of expired items. The rule
+// should expire objects with this tag after the ttl of the adapter.
+const ObjectTag = "dynamodb-cache=offload"
+
+// S3Store saves the objects in a bucket. It also works with
+// S3-compatible storage if the client uses its endpoint.
+type S3Store struct {
+	client s3iface.S3API
+	bucket string
+	prefix string
+}
+
+// NewS3Store returns a store that saves the objects in the bucket.
+// The prefix is put in front of every key, for example "cache/".
+func NewS3Store(client s3iface.S3API, bucket, prefix string) *S3Store {
+	return &S3Store{client: client, bucket: bucket, prefix: prefix}
+}
+
+// PutObject ignores expires: the Expires header of S3 only controls
+// how long clients may cache the object. The objects of expired items
+// are deleted by the lifecycle rule (see ObjectTag).
+func (s *S3Store) PutObject(key string, data []byte, expires time.Time) error {
+	_, err := s.client.PutObject(&s3.PutObjectInput{
+		Bucket:  &s.bucket,
+		Key:     aws.String(s.prefix + key),
+		Body:    bytes.NewReader(data),
+		Tagging: aws.String(ObjectTag),
+	})
+	return err
+}
+
+func (s *S3Store) GetObject(key string) ([]byte, error) {
+	result, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    aws.String(s.prefix + key),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, cache.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	defer result.Body.Close()
+
+	return ioutil.ReadAll(result.Body)
+}
+
+func (s *S3Store) DeleteObject(key string) error {
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: &s.bucket,
+		Key:    aws.String(s.prefix + key),
+	})
+	return err
+}
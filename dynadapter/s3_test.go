@@ -0,0 +1,76 @@
+package dynadapter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+type mockS3Client struct {
+	s3iface.S3API
+
+	objects map[string][]byte
+	put     *s3.PutObjectInput
+}
+
+func (m *mockS3Client) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	data, _ := ioutil.ReadAll(input.Body)
+	m.objects[*input.Key] = data
+	m.put = input
+	return &s3.PutObjectOutput{}, nil
+}
+func (m *mockS3Client) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	data, ok := m.objects[*input.Key]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "not found", nil)
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(data))}, nil
+}
+func (m *mockS3Client) DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	delete(m.objects, *input.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestS3Store(t *testing.T) {
+	mock := &mockS3Client{objects: make(map[string][]byte)}
+	s := NewS3Store(mock, "bucket", "cache/")
+
+	expires := time.Now().Add(time.Hour)
+	err := s.PutObject("key", []byte("data"), expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *mock.put.Bucket != "bucket" || *mock.put.Key != "cache/key" {
+		t.Errorf("wrong location %s/%s", *mock.put.Bucket, *mock.put.Key)
+	}
+	if *mock.put.Tagging != ObjectTag {
+		t.Error("expected the object to be tagged")
+	}
+	if mock.put.Expires != nil {
+		t.Error("expected no Expires header, it only controls caching")
+	}
+
+	data, err := s.GetObject("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("got wrong data '%s'", data)
+	}
+
+	err = s.DeleteObject("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.GetObject("key")
+	if err != cache.ErrNotFound {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+}
@@ -0,0 +1,252 @@
+package dynadapter
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// scanInput returns the input for a Scan that only returns
+// the keys matching the options. With generations expired
+// also means that the item is from an older generation. The
+// chunks of large values are only returned with IncludeExpired,
+// so that they are deleted together with their manifest. Keys
+// with the ReservedPrefix are never returned.
+func (a *Adapter) scanInput(opts cache.ScanOptions) (*dynamodb.ScanInput, error) {
+	input := &dynamodb.ScanInput{
+		TableName:                &a.table,
+		ProjectionExpression:     aws.String("#k"),
+		ExpressionAttributeNames: map[string]*string{"#k": aws.String("Key")},
+	}
+
+	var filters []string
+	values := make(map[string]*dynamodb.AttributeValue)
+	if opts.Prefix != "" {
+		filters = append(filters, "begins_with(#k, :prefix)")
+		values[":prefix"] = &dynamodb.AttributeValue{S: aws.String(opts.Prefix)}
+	}
+	if strings.HasPrefix(opts.Prefix, ReservedPrefix) || strings.HasPrefix(ReservedPrefix, opts.Prefix) {
+		filters = append(filters, "NOT begins_with(#k, :reserved)")
+		values[":reserved"] = &dynamodb.AttributeValue{S: aws.String(ReservedPrefix)}
+	}
+	if !opts.IncludeExpired && a.ttl != -1 {
+		filters = append(filters, "#t >= :now")
+		input.ExpressionAttributeNames["#t"] = aws.String("TTL")
+		values[":now"] = &dynamodb.AttributeValue{
+			N: aws.String(strconv.FormatInt(a.now().Unix(), 10)),
+		}
+	}
+	if a.generations {
+		gen, err := a.generation()
+		if err != nil {
+			return nil, err
+		}
+		if !opts.IncludeExpired && gen > 0 {
+			filters = append(filters, "#g = :gen")
+			input.ExpressionAttributeNames["#g"] = aws.String("Gen")
+			values[":gen"] = &dynamodb.AttributeValue{
+				N: aws.String(strconv.FormatInt(gen, 10)),
+			}
+		}
+	}
+	if !opts.IncludeExpired {
+		filters = append(filters, "attribute_not_exists(#c)")
+		input.ExpressionAttributeNames["#c"] = aws.String("Chunk")
+	}
+	if len(filters) > 0 {
+		input.FilterExpression = aws.String(strings.Join(filters, " AND "))
+	}
+	if len(values) > 0 {
+		input.ExpressionAttributeValues = values
+	}
+
+	return input, nil
+}
+
+// Scan returns the keys with a (filtered) Scan of the table.
+// Because the filter is applied after reading, a page can
+// need several requests to DynamoDB.
+//
+// The key is the partition key, which DynamoDB can't query by a
+// prefix. So the Prefix is only a filter and scanning a prefix reads
+// (and pays for) the whole table, however few keys match it. The
+// table has no sort key, so there is no layout that a Query could
+// use instead.
+//
+// Scan reads one segment after the other, because its cursor can't
+// resume several segments. Use ScanParallel for jobs that read the
+// whole table, Keys and Iterate of the cache don't use it.
+func (a *Adapter) Scan(opts cache.ScanOptions) ([]string, string, error) {
+	input, err := a.scanInput(opts)
+	if err != nil {
+		return nil, "", err
+	}
+	if opts.Cursor != "" {
+		input.ExclusiveStartKey = map[string]*dynamodb.AttributeValue{
+			"Key": {S: aws.String(opts.Cursor)},
+		}
+	}
+
+	var keys []string
+	for {
+		if opts.Limit > 0 {
+			input.Limit = aws.Int64(int64(opts.Limit - len(keys)))
+		}
+
+		page, next, err := scan(a.client, input)
+		if err != nil {
+			return nil, "", err
+		}
+		keys = append(keys, page...)
+
+		if len(next) == 0 {
+			return keys, "", nil
+		}
+		if opts.Limit > 0 && len(keys) >= opts.Limit {
+			return keys, aws.StringValue(next["Key"].S), nil
+		}
+		input.ExclusiveStartKey = next
+	}
+}
+
+// ScanParallel scans the whole table in several segments at the
+// same time and calls fn with every page of keys. fn can be
+// called concurrently. The first error stops the scan.
+func (a *Adapter) ScanParallel(opts cache.ScanOptions, segments int, fn func(keys []string) error) error {
+	return a.scanParallel(opts, segments, nil, func(items []item) error {
+		keys := make([]string, len(items))
+		for n, i := range items {
+			keys[n] = i.Key
+		}
+		return fn(keys)
+	})
+}
+
+// scanParallel is ScanParallel for the items,
+// which also have the attributes besides the key.
+func (a *Adapter) scanParallel(opts cache.ScanOptions, segments int, attributes []string, fn func(items []item) error) error {
+	if segments < 1 {
+		segments = 1
+	}
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	done := make(chan struct{})
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			close(done)
+		})
+	}
+
+	for segment := 0; segment < segments; segment++ {
+		input, err := a.scanInput(opts)
+		if err != nil {
+			fail(err)
+			break
+		}
+		for n, name := range attributes {
+			placeholder := "#a" + strconv.Itoa(n)
+			input.ProjectionExpression = aws.String(*input.ProjectionExpression + ", " + placeholder)
+			input.ExpressionAttributeNames[placeholder] = aws.String(name)
+		}
+		input.Segment = aws.Int64(int64(segment))
+		input.TotalSegments = aws.Int64(int64(segments))
+		if opts.Limit > 0 {
+			input.Limit = aws.Int64(int64(opts.Limit))
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				items, next, err := scanItems(a.client, input)
+				if err != nil {
+					fail(err)
+					return
+				}
+				if len(items) > 0 {
+					err = fn(items)
+					if err != nil {
+						fail(err)
+						return
+					}
+				}
+				if len(next) == 0 {
+					return
+				}
+				input.ExclusiveStartKey = next
+			}
+		}()
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// Dump scans the whole table and calls fn with every item
+// that is not expired. Large values are read from their chunks
+// or from the object store.
+func (a *Adapter) Dump(fn func(rec cache.Record) error) error {
+	input, err := a.scanInput(cache.ScanOptions{})
+	if err != nil {
+		return err
+	}
+	input.ProjectionExpression = aws.String("#k, #t, #d, #n, #v, #s, #o")
+	input.ExpressionAttributeNames["#t"] = aws.String("TTL")
+	input.ExpressionAttributeNames["#d"] = aws.String("Data")
+	input.ExpressionAttributeNames["#n"] = aws.String("Chunks")
+	input.ExpressionAttributeNames["#v"] = aws.String("Version")
+	input.ExpressionAttributeNames["#s"] = aws.String("Checksum")
+	input.ExpressionAttributeNames["#o"] = aws.String("Object")
+
+	for {
+		result, err := a.client.Scan(input)
+		if err != nil {
+			return err
+		}
+
+		for _, attributes := range result.Items {
+			var i item
+			err = i.unmarshal(attributes)
+			if err != nil {
+				return err
+			}
+			if i.Chunks > 0 || i.Object != "" {
+				i.Data, err = a.join(i)
+				if err == errMissingPart {
+					// replaced or deleted since the scan
+					continue
+				} else if err != nil {
+					return err
+				}
+			}
+
+			rec := cache.Record{Key: i.Key, Data: i.Data}
+			if a.ttl != -1 {
+				rec.Expires = time.Unix(i.TTL, 0)
+			}
+			err = fn(rec)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
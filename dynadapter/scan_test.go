@@ -0,0 +1,137 @@
+package dynadapter
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func keyItem(key string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Key": {S: aws.String(key)},
+	}
+}
+
+func TestScan(t *testing.T) {
+	var calls int
+	mockSvc := &mockDynamoDBClient{
+		ScanFunc: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			calls++
+			if *input.FilterExpression != "begins_with(#k, :prefix) AND #t >= :now AND attribute_not_exists(#c)" {
+				t.Errorf("wrong filter expression: %s", *input.FilterExpression)
+			}
+
+			switch calls {
+			case 1:
+				if input.ExclusiveStartKey != nil {
+					t.Error("expected to start at the beginning")
+				}
+				// nothing matched the filter
+				return &dynamodb.ScanOutput{LastEvaluatedKey: keyItem("a")}, nil
+			case 2:
+				if *input.Limit != 2 {
+					t.Error("wrong limit")
+				}
+				return &dynamodb.ScanOutput{
+					Items:            []map[string]*dynamodb.AttributeValue{keyItem("user:1"), keyItem("user:2")},
+					LastEvaluatedKey: keyItem("user:2"),
+				}, nil
+			}
+			t.Error("too many calls")
+			return nil, nil
+		},
+	}
+
+	c, err := new(mockSvc, time.Hour)
+	if err != nil {
+		t.Error(err)
+	}
+	keys, next, err := c.(cache.Scanner).Scan(cache.ScanOptions{Prefix: "user:", Limit: 2})
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(keys, []string{"user:1", "user:2"}) {
+		t.Errorf("got different keys: %v", keys)
+	}
+	if next != "user:2" {
+		t.Errorf("wrong cursor: %s", next)
+	}
+}
+
+func TestScanParallel(t *testing.T) {
+	mockSvc := &mockDynamoDBClient{
+		ScanFunc: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			if *input.FilterExpression != "NOT begins_with(#k, :reserved) AND attribute_not_exists(#c)" {
+				t.Errorf("expected only the reserved and the chunk filter: %s", *input.FilterExpression)
+			}
+			if len(input.ExpressionAttributeValues) != 1 {
+				t.Error("expected only the reserved prefix")
+			}
+			key := "segment-" + string(rune('0'+*input.Segment))
+			return &dynamodb.ScanOutput{
+				Items: []map[string]*dynamodb.AttributeValue{keyItem(key)},
+			}, nil
+		},
+	}
+
+	c, err := new(mockSvc, -1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var m sync.Mutex
+	var keys []string
+	err = c.(*Adapter).ScanParallel(cache.ScanOptions{}, 3, func(page []string) error {
+		m.Lock()
+		keys = append(keys, page...)
+		m.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"segment-0", "segment-1", "segment-2"}) {
+		t.Errorf("got different keys: %v", keys)
+	}
+}
+
+func TestDump(t *testing.T) {
+	mockSvc := &mockDynamoDBClient{
+		ScanFunc: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			if *input.ProjectionExpression != "#k, #t, #d, #n, #v, #s, #o" {
+				t.Error("wrong projection")
+			}
+			return &dynamodb.ScanOutput{
+				Items: []map[string]*dynamodb.AttributeValue{{
+					"Key":  {S: aws.String("1")},
+					"TTL":  {N: aws.String("4102444800")},
+					"Data": {B: []byte("data")},
+				}},
+			}, nil
+		},
+	}
+
+	c, err := new(mockSvc, time.Hour)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var records []cache.Record
+	err = c.(cache.Dumper).Dump(func(rec cache.Record) error {
+		records = append(records, rec)
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(records) != 1 || records[0].Expires.Unix() != 4102444800 || string(records[0].Data) != "data" {
+		t.Errorf("unexpected records: %+v", records)
+	}
+}
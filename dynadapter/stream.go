@@ -0,0 +1,308 @@
+package dynadapter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
+)
+
+// DefaultPollInterval is the time between two polls of the stream.
+const DefaultPollInterval = time.Second
+
+// Evicter removes items from the upper tiers of a cache.
+// It is implemented by *cache.Cache.
+type Evicter interface {
+	Evict(keys ...string) error
+}
+
+// Checkpoints saves the sequence number of the last record that
+// was processed for every shard, so that a restarted consumer
+// continues where it stopped.
+type Checkpoints interface {
+	// Load returns an empty string if there is no checkpoint.
+	Load(shardID string) (string, error)
+	Save(shardID, sequenceNumber string) error
+}
+
+type memoryCheckpoints struct {
+	sequences map[string]string
+	m         sync.Mutex
+}
+
+// NewMemoryCheckpoints returns checkpoints that are lost on restart.
+func NewMemoryCheckpoints() Checkpoints {
+	return &memoryCheckpoints{sequences: make(map[string]string)}
+}
+
+func (c *memoryCheckpoints) Load(shardID string) (string, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return c.sequences[shardID], nil
+}
+func (c *memoryCheckpoints) Save(shardID, sequenceNumber string) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.sequences[shardID] = sequenceNumber
+	return nil
+}
+
+// ShardError is the error of one shard of the stream.
+type ShardError struct {
+	ShardID string
+	Err     error
+}
+
+func (e *ShardError) Error() string {
+	return fmt.Sprintf("shard %s: %v", e.ShardID, e.Err)
+}
+
+func (e *ShardError) Unwrap() error {
+	return e.Err
+}
+
+// ShardErrors holds the errors of the shards that failed in Poll.
+type ShardErrors []*ShardError
+
+func (e ShardErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e ShardErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// StreamConsumer reads the DynamoDB Stream of the cache table and
+// evicts the keys of changed (MODIFY) and deleted (REMOVE) items, so
+// that writes by other services also reach the in-memory tiers.
+//
+// The stream needs to be enabled on the table, every view type works
+// because only the keys are used.
+type StreamConsumer struct {
+	client  dynamodbstreamsiface.DynamoDBStreamsAPI
+	arn     string
+	evicter Evicter
+
+	// Checkpoints defaults to NewMemoryCheckpoints.
+	Checkpoints  Checkpoints
+	PollInterval time.Duration
+	// OnError is called by Run with the error of a poll
+	// that failed. Run is silent if it is nil.
+	OnError func(err error)
+
+	iterators map[string]*string
+	closed    map[string]bool
+	started   bool
+}
+
+// NewStreamConsumer returns a consumer for the stream with the arn.
+// Shards without a checkpoint that exist at the first poll are read
+// from the latest record, shards that are created later (after a
+// split) are read from the beginning.
+func NewStreamConsumer(client dynamodbstreamsiface.DynamoDBStreamsAPI, streamARN string, evicter Evicter) *StreamConsumer {
+	return &StreamConsumer{
+		client:  client,
+		arn:     streamARN,
+		evicter: evicter,
+
+		Checkpoints:  NewMemoryCheckpoints(),
+		PollInterval: DefaultPollInterval,
+
+		iterators: make(map[string]*string),
+		closed:    make(map[string]bool),
+	}
+}
+
+// Run polls the stream until the context is canceled. Errors
+// are passed to OnError and the next poll tries again.
+func (s *StreamConsumer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		err := s.Poll()
+		if err != nil && s.OnError != nil {
+			s.OnError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll reads one batch of records from every open shard. It
+// must not be called concurrently. A shard that fails does not
+// stop the others, the errors are returned together as ShardErrors.
+func (s *StreamConsumer) Poll() error {
+	shards, err := s.shards()
+	if err != nil {
+		return err
+	}
+
+	var errs ShardErrors
+	closed := make(map[string]bool)
+	for _, shard := range shards {
+		id := aws.StringValue(shard.ShardId)
+		if s.closed[id] {
+			closed[id] = true
+			continue
+		}
+
+		done, err := s.poll(id)
+		if err != nil {
+			errs = append(errs, &ShardError{ShardID: id, Err: err})
+			continue
+		}
+		if done {
+			closed[id] = true
+		}
+	}
+	// shards that are not described anymore are trimmed
+	s.closed = closed
+	s.started = true
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// poll reads one batch of records from the shard
+// and gets an iterator first if it has none.
+func (s *StreamConsumer) poll(id string) (bool, error) {
+	if s.iterators[id] == nil {
+		iterator, err := s.iterator(id)
+		if err != nil {
+			return false, err
+		}
+		s.iterators[id] = iterator
+	}
+
+	return s.read(id)
+}
+
+// shards returns every shard of the stream.
+func (s *StreamConsumer) shards() ([]*dynamodbstreams.Shard, error) {
+	var shards []*dynamodbstreams.Shard
+
+	input := &dynamodbstreams.DescribeStreamInput{StreamArn: &s.arn}
+	for {
+		result, err := s.client.DescribeStream(input)
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, result.StreamDescription.Shards...)
+
+		if result.StreamDescription.LastEvaluatedShardId == nil {
+			return shards, nil
+		}
+		input.ExclusiveStartShardId = result.StreamDescription.LastEvaluatedShardId
+	}
+}
+
+// iterator continues after the checkpoint of the shard. If the
+// checkpoint was already trimmed the shard is read from the beginning.
+func (s *StreamConsumer) iterator(id string) (*string, error) {
+	seq, err := s.Checkpoints.Load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         &s.arn,
+		ShardId:           &id,
+		ShardIteratorType: aws.String(dynamodbstreams.ShardIteratorTypeTrimHorizon),
+	}
+	if seq != "" {
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeAfterSequenceNumber)
+		input.SequenceNumber = &seq
+	} else if !s.started {
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeLatest)
+	}
+
+	result, err := s.client.GetShardIterator(input)
+	if isStreamError(err, dynamodbstreams.ErrCodeTrimmedDataAccessException) {
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeTrimHorizon)
+		input.SequenceNumber = nil
+		result, err = s.client.GetShardIterator(input)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result.ShardIterator, nil
+}
+
+// read evicts the keys of one batch of records and saves the
+// checkpoint. It returns true once the shard is closed.
+func (s *StreamConsumer) read(id string) (bool, error) {
+	result, err := s.client.GetRecords(&dynamodbstreams.GetRecordsInput{
+		ShardIterator: s.iterators[id],
+	})
+	if isStreamError(err, dynamodbstreams.ErrCodeExpiredIteratorException) {
+		// the next poll gets a new iterator from the checkpoint
+		delete(s.iterators, id)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var keys []string
+	var last string
+	for _, rec := range result.Records {
+		last = aws.StringValue(rec.Dynamodb.SequenceNumber)
+
+		switch aws.StringValue(rec.EventName) {
+		case dynamodbstreams.OperationTypeModify, dynamodbstreams.OperationTypeRemove:
+			if key := rec.Dynamodb.Keys["Key"]; key != nil && key.S != nil {
+				keys = append(keys, *key.S)
+			}
+		}
+	}
+
+	if len(keys) > 0 {
+		// the iterator is only moved on success, so
+		// that the records are read again on error
+		err = s.evicter.Evict(keys...)
+		if err != nil {
+			return false, err
+		}
+	}
+	if last != "" {
+		err = s.Checkpoints.Save(id, last)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if result.NextShardIterator == nil {
+		delete(s.iterators, id)
+		return true, nil
+	}
+	s.iterators[id] = result.NextShardIterator
+	return false, nil
+}
+
+func isStreamError(err error, code string) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == code
+}
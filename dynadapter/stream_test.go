@@ -0,0 +1,351 @@
+package dynadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
+)
+
+type fakeShard struct {
+	id      string
+	records []*dynamodbstreams.Record
+	closed  bool
+	// err makes GetRecords of the shard fail
+	err error
+}
+
+// fakeStreams is a stream with iterators of the form "shard/position".
+type fakeStreams struct {
+	dynamodbstreamsiface.DynamoDBStreamsAPI
+
+	shards []*fakeShard
+	seq    int
+	// expired makes the next GetRecords fail
+	expired bool
+}
+
+func (f *fakeStreams) shard(id string) *fakeShard {
+	for _, shard := range f.shards {
+		if shard.id == id {
+			return shard
+		}
+	}
+	return nil
+}
+
+func (f *fakeStreams) add(id, event, key string) {
+	f.seq++
+	shard := f.shard(id)
+	if shard == nil {
+		shard = &fakeShard{id: id}
+		f.shards = append(f.shards, shard)
+	}
+	shard.records = append(shard.records, &dynamodbstreams.Record{
+		EventName: aws.String(event),
+		Dynamodb: &dynamodbstreams.StreamRecord{
+			SequenceNumber: aws.String(fmt.Sprintf("%021d", f.seq)),
+			Keys: map[string]*dynamodb.AttributeValue{
+				"Key": {S: aws.String(key)},
+			},
+		},
+	})
+}
+
+// DescribeStream returns one shard per page.
+func (f *fakeStreams) DescribeStream(input *dynamodbstreams.DescribeStreamInput) (*dynamodbstreams.DescribeStreamOutput, error) {
+	start := 0
+	if input.ExclusiveStartShardId != nil {
+		for i, shard := range f.shards {
+			if shard.id == *input.ExclusiveStartShardId {
+				start = i + 1
+			}
+		}
+	}
+
+	desc := &dynamodbstreams.StreamDescription{}
+	if start < len(f.shards) {
+		desc.Shards = []*dynamodbstreams.Shard{{ShardId: aws.String(f.shards[start].id)}}
+		if start+1 < len(f.shards) {
+			desc.LastEvaluatedShardId = aws.String(f.shards[start].id)
+		}
+	}
+	return &dynamodbstreams.DescribeStreamOutput{StreamDescription: desc}, nil
+}
+
+func (f *fakeStreams) GetShardIterator(input *dynamodbstreams.GetShardIteratorInput) (*dynamodbstreams.GetShardIteratorOutput, error) {
+	shard := f.shard(*input.ShardId)
+
+	var pos int
+	switch *input.ShardIteratorType {
+	case dynamodbstreams.ShardIteratorTypeLatest:
+		pos = len(shard.records)
+	case dynamodbstreams.ShardIteratorTypeAfterSequenceNumber:
+		pos = -1
+		for i, rec := range shard.records {
+			if *rec.Dynamodb.SequenceNumber == *input.SequenceNumber {
+				pos = i + 1
+			}
+		}
+		if pos == -1 {
+			return nil, awserr.New(dynamodbstreams.ErrCodeTrimmedDataAccessException, "trimmed", nil)
+		}
+	}
+	return &dynamodbstreams.GetShardIteratorOutput{
+		ShardIterator: aws.String(shard.id + "/" + strconv.Itoa(pos)),
+	}, nil
+}
+
+func (f *fakeStreams) GetRecords(input *dynamodbstreams.GetRecordsInput) (*dynamodbstreams.GetRecordsOutput, error) {
+	if f.expired {
+		f.expired = false
+		return nil, awserr.New(dynamodbstreams.ErrCodeExpiredIteratorException, "expired", nil)
+	}
+
+	parts := strings.Split(*input.ShardIterator, "/")
+	shard := f.shard(parts[0])
+	pos, _ := strconv.Atoi(parts[1])
+	if shard.err != nil {
+		return nil, shard.err
+	}
+
+	out := &dynamodbstreams.GetRecordsOutput{Records: shard.records[pos:]}
+	if !shard.closed {
+		out.NextShardIterator = aws.String(shard.id + "/" + strconv.Itoa(len(shard.records)))
+	}
+	return out, nil
+}
+
+type evicterMock struct {
+	keys []string
+	err  error
+}
+
+func (e *evicterMock) Evict(keys ...string) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.keys = append(e.keys, keys...)
+	return nil
+}
+
+func expectKeys(t *testing.T, e *evicterMock, expected ...string) {
+	t.Helper()
+
+	if strings.Join(e.keys, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected keys %v but got %v", expected, e.keys)
+	}
+	e.keys = nil
+}
+
+func TestStream_Poll(t *testing.T) {
+	f := &fakeStreams{}
+	f.add("shard-1", "MODIFY", "before")
+
+	e := &evicterMock{}
+	s := NewStreamConsumer(f, "arn", e)
+
+	// the first poll starts at the latest record
+	err := s.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, e)
+
+	f.add("shard-1", "INSERT", "new")
+	f.add("shard-1", "MODIFY", "changed")
+	f.add("shard-1", "REMOVE", "removed")
+
+	err = s.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, e, "changed", "removed")
+
+	err = s.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, e)
+}
+
+func TestStream_Checkpoints(t *testing.T) {
+	f := &fakeStreams{}
+	f.add("shard-1", "MODIFY", "a")
+
+	e := &evicterMock{}
+	s := NewStreamConsumer(f, "arn", e)
+	s.Poll()
+	f.add("shard-1", "MODIFY", "b")
+	s.Poll()
+	expectKeys(t, e, "b")
+
+	// a restarted consumer continues after the checkpoint
+	f.add("shard-1", "MODIFY", "c")
+	restarted := NewStreamConsumer(f, "arn", e)
+	restarted.Checkpoints = s.Checkpoints
+
+	err := restarted.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, e, "c")
+}
+
+func TestStream_TrimmedCheckpoint(t *testing.T) {
+	f := &fakeStreams{}
+	f.add("shard-1", "MODIFY", "a")
+
+	e := &evicterMock{}
+	s := NewStreamConsumer(f, "arn", e)
+	s.Checkpoints.Save("shard-1", "trimmed")
+
+	err := s.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, e, "a")
+}
+
+func TestStream_Split(t *testing.T) {
+	f := &fakeStreams{}
+	f.add("shard-1", "MODIFY", "a")
+
+	e := &evicterMock{}
+	s := NewStreamConsumer(f, "arn", e)
+	s.Poll()
+
+	f.add("shard-1", "MODIFY", "b")
+	f.shard("shard-1").closed = true
+	f.add("shard-2", "MODIFY", "c")
+	f.add("shard-3", "REMOVE", "d")
+
+	err := s.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, e, "b", "c", "d")
+
+	if !s.closed["shard-1"] {
+		t.Error("expected shard-1 to be closed")
+	}
+	if s.iterators["shard-1"] != nil {
+		t.Error("expected no iterator for the closed shard")
+	}
+}
+
+func TestStream_ExpiredIterator(t *testing.T) {
+	f := &fakeStreams{}
+	f.add("shard-1", "MODIFY", "a")
+
+	e := &evicterMock{}
+	s := NewStreamConsumer(f, "arn", e)
+	s.Poll()
+	f.add("shard-1", "MODIFY", "b")
+	s.Poll()
+	expectKeys(t, e, "b")
+
+	f.add("shard-1", "MODIFY", "c")
+	f.expired = true
+	err := s.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, e)
+
+	// the new iterator starts after the checkpoint
+	err = s.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, e, "c")
+}
+
+func TestStream_EvictError(t *testing.T) {
+	f := &fakeStreams{}
+	f.add("shard-1", "MODIFY", "a")
+
+	e := &evicterMock{err: errors.New("evict failed")}
+	s := NewStreamConsumer(f, "arn", e)
+	s.Poll()
+
+	f.add("shard-1", "MODIFY", "b")
+	err := s.Poll()
+	if !errors.Is(err, e.err) {
+		t.Errorf("expected evict error but got %v", err)
+	}
+
+	// the records are read again
+	e.err = nil
+	err = s.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, e, "b")
+}
+
+func TestStream_ShardErrors(t *testing.T) {
+	f := &fakeStreams{}
+	f.add("shard-1", "MODIFY", "a")
+	f.add("shard-2", "MODIFY", "b")
+
+	e := &evicterMock{}
+	s := NewStreamConsumer(f, "arn", e)
+	s.Poll()
+
+	f.add("shard-1", "MODIFY", "c")
+	f.add("shard-2", "MODIFY", "d")
+	f.shard("shard-2").closed = true
+	f.shard("shard-1").err = errors.New("get records failed")
+
+	// shard-2 is read although shard-1 failed
+	err := s.Poll()
+	errs, ok := err.(ShardErrors)
+	if !ok || len(errs) != 1 || errs[0].ShardID != "shard-1" || !errors.Is(err, f.shard("shard-1").err) {
+		t.Errorf("expected the error of shard-1 but got %v", err)
+	}
+	expectKeys(t, e, "d")
+	if !s.closed["shard-2"] {
+		t.Error("expected shard-2 to be closed")
+	}
+
+	f.shard("shard-1").err = nil
+	err = s.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, e, "c")
+}
+
+func TestStream_RunOnError(t *testing.T) {
+	f := &fakeStreams{}
+	f.add("shard-1", "MODIFY", "a")
+
+	e := &evicterMock{err: errors.New("evict failed")}
+	s := NewStreamConsumer(f, "arn", e)
+	s.Poll()
+	f.add("shard-1", "MODIFY", "b")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var errs []error
+	s.OnError = func(err error) {
+		errs = append(errs, err)
+		cancel()
+	}
+
+	err := s.Run(ctx)
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled but got %v", err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], e.err) {
+		t.Errorf("expected the evict error to be passed to OnError but got %v", errs)
+	}
+}
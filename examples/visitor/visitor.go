@@ -10,6 +10,7 @@ import (
 
 	cache "github.com/JohannesKaufmann/dynamodb-cache"
 	"github.com/JohannesKaufmann/dynamodb-cache/memadapter"
+	"github.com/JohannesKaufmann/dynamodb-cache/ratelimit"
 	randomdata "github.com/Pallinder/go-randomdata"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
@@ -18,6 +19,8 @@ import (
 
 var articles = make(map[string]string)
 
+const freeArticles = 5
+
 func init() {
 	babbler := babble.NewBabbler()
 	for i := 0; i < 10; i++ {
@@ -28,67 +31,49 @@ func init() {
 	}
 }
 
-type visitor struct {
-	Articles map[string]struct{}
-}
-
 type contextKey string
 
 var (
-	contextKeyReadArticles = contextKey("read-articles")
+	contextKeyRemaining = contextKey("remaining-articles")
 )
 
-func setReadArticles(ctx context.Context, req *http.Request, num int) context.Context {
-	return context.WithValue(ctx, contextKeyReadArticles, num)
+func setRemaining(ctx context.Context, num int) context.Context {
+	return context.WithValue(ctx, contextKeyRemaining, num)
 }
-func getReadArticles(ctx context.Context) (int, bool) {
-	num, ok := ctx.Value(contextKeyReadArticles).(int)
+func getRemaining(ctx context.Context) (int, bool) {
+	num, ok := ctx.Value(contextKeyRemaining).(int)
 	return num, ok
 }
 
-func trackVisitors(c *cache.Cache) func(http.Handler) http.Handler {
+func trackVisitors(l ratelimit.Limiter) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := r.RemoteAddr
-
-			var v visitor
-			err := c.Get(ip, &v)
-			if err != nil && (err == cache.ErrNotFound || err == cache.ErrExpired) {
-				v.Articles = make(map[string]struct{})
-			} else if err != nil {
-				log.Fatal(err)
+			res, err := l.Allow(ratelimit.ByRemoteAddr(r))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !res.Allowed {
+				w.Write([]byte("You have reached the limit."))
+				return
 			}
 
-			v.Articles[r.URL.Path] = struct{}{}
-			ctx := setReadArticles(r.Context(), r, len(v.Articles))
-
-			fmt.Printf("%+v\n", v)
-			go func() {
-				err = c.Set(ip, v)
-				if err != nil {
-					log.Fatal(err)
-				}
-			}()
+			ctx := setRemaining(r.Context(), res.Remaining)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 func articleHandler(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	count, ok := getReadArticles(r.Context())
+	remaining, ok := getRemaining(r.Context())
 	if !ok {
 		fmt.Println("not ok")
 	}
 
-	if count > 5 {
-		w.Write([]byte("You have reached the limit."))
-		return
-	}
-
 	data := map[string]interface{}{
 		"ID": id,
 		"Info": fmt.Sprintf(
-			`You have %d of %d free articles remaining.`, 5-count, 5,
+			`You have %d of %d free articles remaining.`, remaining, freeArticles,
 		),
 		"Text": articles[id],
 	}
@@ -138,7 +123,7 @@ func articlesHandler(w http.ResponseWriter, r *http.Request) {
 
 func main() {
 	c, err := cache.New(
-		memadapter.New(time.Minute*10, false),
+		memadapter.New(time.Hour*24, false),
 	)
 	if err != nil {
 		log.Fatal(err)
@@ -150,7 +135,8 @@ func main() {
 
 	r.Get("/", articlesHandler)
 
-	track := trackVisitors(c)
+	limiter := ratelimit.NewFixedWindow(c, freeArticles, time.Hour*24)
+	track := trackVisitors(limiter)
 	r.Handle("/article/{id}", track(http.HandlerFunc(articleHandler)))
 
 	http.ListenAndServe(":3000", r)
@@ -0,0 +1,258 @@
+// Package faultadapter wraps an adapter and injects faults, so that
+// tests can check how code behaves when the cache misbehaves: slow
+// calls, errors, expired items and corrupted values.
+//
+// Faults are described by Rules for key patterns. The random numbers
+// come from one seeded source, so a test that calls the adapter in
+// the same order gets the same faults for the same seed.
+package faultadapter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// ErrInjected is the default error of a Rule.
+var ErrInjected = errors.New("injected fault")
+
+// Adapter passes every call to the wrapped adapter unless
+// a Rule decides otherwise.
+//
+// It has the methods of every optional interface of the cache, but
+// it is a cache.Supporter, so the cache only uses those that the
+// wrapped adapter implements. Called directly, the others return
+// cache.ErrUnsupported.
+type Adapter struct {
+	adapter cache.Adapter
+
+	m     sync.Mutex
+	rules []*Rule
+	rand  *rand.Rand
+	seed  int64
+}
+
+// Option changes the behaviour of the adapter.
+type Option func(*Adapter)
+
+// WithSeed sets the seed of the random source. Without
+// it the seed is random and can be read with Seed.
+func WithSeed(seed int64) Option {
+	return func(a *Adapter) {
+		a.seed = seed
+	}
+}
+
+// WithRule adds the rule, see AddRule.
+func WithRule(rule Rule) Option {
+	return func(a *Adapter) {
+		a.rules = append(a.rules, &rule)
+	}
+}
+
+// Wrap returns an adapter that injects faults into the calls to
+// the adapter. The rules can still be changed afterwards, for
+// example between the steps of a test. Use Init to pass it to
+// cache.New.
+func Wrap(adapter cache.Adapter, opts ...Option) *Adapter {
+	a := &Adapter{
+		adapter: adapter,
+		seed:    time.Now().UnixNano(),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.rand = rand.New(rand.NewSource(a.seed))
+	return a
+}
+
+// New is like Wrap for adapters that are not initialized yet.
+func New(init cache.InitAdapter, opts ...Option) cache.InitAdapter {
+	return func() (cache.Adapter, error) {
+		adapter, err := init()
+		if err != nil {
+			return nil, err
+		}
+		return Wrap(adapter, opts...), nil
+	}
+}
+
+// Init returns the adapter itself and can be passed to cache.New.
+func (a *Adapter) Init() (cache.Adapter, error) {
+	return a, nil
+}
+
+// Seed returns the seed of the random source, for
+// example to log it when a test fails.
+func (a *Adapter) Seed() int64 {
+	return a.seed
+}
+
+// AddRule adds a rule after the existing ones.
+func (a *Adapter) AddRule(rule Rule) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	a.rules = append(a.rules, &rule)
+}
+
+// Reset removes every rule, so that the calls reach
+// the adapter without faults again.
+func (a *Adapter) Reset() {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	a.rules = nil
+}
+
+// fault is the decision of the rules for one call.
+type fault struct {
+	latency time.Duration
+	err     error
+	corrupt bool
+	// position is the random position of the corrupted byte.
+	position int
+}
+
+// decide applies the rules that match the call. The random
+// numbers are drawn here, in the order of the calls, and not
+// when the result of the adapter is known.
+func (a *Adapter) decide(op Op, key string) fault {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	var f fault
+	rules := a.rules[:0:0]
+	for _, rule := range a.rules {
+		if !rule.matches(op, key) {
+			rules = append(rules, rule)
+			continue
+		}
+
+		if rule.Latency != nil {
+			f.latency += rule.Latency(a.rand)
+		}
+		if f.err == nil && happens(a.rand, rule.ErrorRate) {
+			f.err = rule.Err
+			if f.err == nil {
+				f.err = ErrInjected
+			}
+		}
+		if f.err == nil && op == OpGet && happens(a.rand, rule.ExpiredRate) {
+			f.err = cache.ErrExpired
+		}
+		if !f.corrupt && happens(a.rand, rule.CorruptRate) {
+			f.corrupt = true
+			f.position = a.rand.Int()
+		}
+
+		if rule.Times > 0 {
+			rule.Times--
+			if rule.Times == 0 {
+				continue
+			}
+		}
+		rules = append(rules, rule)
+	}
+	a.rules = rules
+
+	return f
+}
+
+// corruptData returns a copy of the data with one changed byte.
+func (f fault) corruptData(data []byte) []byte {
+	if len(data) == 0 {
+		return []byte{byte(f.position)}
+	}
+
+	corrupted := make([]byte, len(data))
+	copy(corrupted, data)
+	i := f.position % len(data)
+	corrupted[i] ^= byte(1 + f.position%255)
+	return corrupted
+}
+
+// wait sleeps for the latency or until the context is done.
+func wait(ctx context.Context, latency time.Duration) error {
+	if latency <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *Adapter) Get(key string) ([]byte, error) {
+	return a.GetWithContext(context.Background(), key)
+}
+
+// GetWithContext stops waiting for the latency if the context
+// is done, for example with the Hedged read strategy.
+func (a *Adapter) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	f := a.decide(OpGet, key)
+	if err := wait(ctx, f.latency); err != nil {
+		return nil, err
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	var data []byte
+	var err error
+	if getter, ok := a.adapter.(cache.ContextGetter); ok {
+		data, err = getter.GetWithContext(ctx, key)
+	} else {
+		data, err = a.adapter.Get(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if f.corrupt {
+		data = f.corruptData(data)
+	}
+	return data, nil
+}
+
+func (a *Adapter) Set(key string, value []byte) error {
+	f := a.decide(OpSet, key)
+	time.Sleep(f.latency)
+	if f.err != nil {
+		return f.err
+	}
+
+	if f.corrupt {
+		value = f.corruptData(value)
+	}
+	return a.adapter.Set(key, value)
+}
+
+func (a *Adapter) Del(key string) error {
+	f := a.decide(OpDel, key)
+	time.Sleep(f.latency)
+	if f.err != nil {
+		return f.err
+	}
+
+	return a.adapter.Del(key)
+}
+
+// Close closes the wrapped adapter if it implements io.Closer.
+func (a *Adapter) Close() error {
+	if closer, ok := a.adapter.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
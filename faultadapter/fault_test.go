@@ -0,0 +1,292 @@
+package faultadapter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/JohannesKaufmann/dynamodb-cache/cachetest"
+	"github.com/JohannesKaufmann/dynamodb-cache/memadapter"
+)
+
+func newMemory(t *testing.T) cache.Adapter {
+	adapter, err := memadapter.New(time.Hour, false)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return adapter
+}
+
+func TestAdapterSuite(t *testing.T) {
+	// without rules the adapter must behave like the wrapped one
+	cachetest.RunAdapterSuite(t, func(ttl time.Duration, now func() time.Time) cache.InitAdapter {
+		return New(memadapter.New(ttl, false, memadapter.WithClock(now)))
+	})
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		key      string
+		expected bool
+	}{
+		{"user:1", "user:1", true},
+		{"user:1", "user:10", false},
+		{"user:*", "user:10", true},
+		{"user:*", "user:", true},
+		{"user:*", "users", false},
+		{"*/api/*", "http://host/api/users?id=1", true},
+		{"*:?", "user:1", true},
+		{"*:?", "user:12", false},
+		{"**", "", true},
+		{"a*b*c", "aXbYbc", true},
+		{"a*b*c", "aXbYc!", false},
+	}
+
+	for _, test := range tests {
+		if actual := match(test.pattern, test.key); actual != test.expected {
+			t.Errorf("%q %q: expected %v but got %v", test.pattern, test.key, test.expected, actual)
+		}
+	}
+}
+
+func TestRule(t *testing.T) {
+	errDown := errors.New("down")
+	a := Wrap(newMemory(t), WithRule(Rule{
+		Pattern:   "user:*",
+		Ops:       []Op{OpSet},
+		Times:     2,
+		ErrorRate: 1,
+		Err:       errDown,
+	}))
+
+	if err := a.Set("other", []byte("value")); err != nil {
+		t.Errorf("expected no error for a different key but got %v", err)
+	}
+	if err := a.Set("user:1", []byte("value")); err != errDown {
+		t.Errorf("expected errDown but got %v", err)
+	}
+	if _, err := a.Get("user:1"); err != cache.ErrNotFound {
+		t.Errorf("expected the rule to only apply to Set but got %v", err)
+	}
+	if err := a.Set("user:1", []byte("value")); err != errDown {
+		t.Errorf("expected errDown but got %v", err)
+	}
+
+	// the rule was removed after two calls
+	if err := a.Set("user:1", []byte("value")); err != nil {
+		t.Errorf("expected no error but got %v", err)
+	}
+
+	a.AddRule(Rule{Pattern: "user:1", ExpiredRate: 1})
+	if _, err := a.Get("user:1"); err != cache.ErrExpired {
+		t.Errorf("expected ErrExpired but got %v", err)
+	}
+
+	a.Reset()
+	if data, err := a.Get("user:1"); err != nil || string(data) != "value" {
+		t.Errorf("expected the value after Reset but got %q, %v", data, err)
+	}
+}
+
+func TestSeed(t *testing.T) {
+	run := func(seed int64) []error {
+		a := Wrap(newMemory(t),
+			WithSeed(seed),
+			WithRule(Rule{ErrorRate: 0.5}),
+			WithRule(Rule{Latency: Uniform(0, time.Microsecond), CorruptRate: 0.3}),
+		)
+
+		var errs []error
+		for i := 0; i < 50; i++ {
+			errs = append(errs, a.Set("key", []byte("value")))
+		}
+		return errs
+	}
+
+	first, second := run(42), run(42)
+	var count int
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("call %d: got %v and %v with the same seed", i, first[i], second[i])
+		}
+		if first[i] != nil {
+			count++
+		}
+	}
+	if count == 0 || count == len(first) {
+		t.Errorf("expected some calls to fail but %d failed", count)
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	a := Wrap(newMemory(t), WithRule(Rule{Ops: []Op{OpGet}, CorruptRate: 1}))
+
+	value := []byte("value")
+	if err := a.Set("key", value); err != nil {
+		t.Fatal(err)
+	}
+	data, err := a.Get("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(data, value) || len(data) != len(value) {
+		t.Errorf("expected a corrupted value but got %q", data)
+	}
+
+	// the stored value is unchanged
+	a.Reset()
+	data, _ = a.Get("key")
+	if !bytes.Equal(data, value) {
+		t.Errorf("expected %q but got %q", value, data)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	a := Wrap(newMemory(t), WithRule(Rule{Latency: Fixed(50 * time.Millisecond)}))
+
+	start := time.Now()
+	a.Set("key", []byte("value"))
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("expected a delay but Set took %s", d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err := a.GetWithContext(ctx, "key")
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected DeadlineExceeded but got %v", err)
+	}
+}
+
+func TestCache(t *testing.T) {
+	a := Wrap(newMemory(t))
+	c, err := cache.New(a.Init)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Set("key", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a corrupted payload can't be decoded
+	a.AddRule(Rule{Pattern: "key", CorruptRate: 1, Times: 1})
+	var value string
+	err = c.Get("key", &value)
+	if err == nil && value == "value" {
+		t.Error("expected the corruption to be noticed")
+	}
+
+	err = c.Get("key", &value)
+	if err != nil || value != "value" {
+		t.Errorf("expected the value but got %q, %v", value, err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	a := Wrap(newMemory(t))
+	c, err := cache.New(a.Init)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := c.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body"))
+	}))
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	a.AddRule(Rule{Pattern: "/expired*", Ops: []Op{OpGet}, ExpiredRate: 1})
+	a.AddRule(Rule{Pattern: "/broken*", Ops: []Op{OpGet}, ErrorRate: 1})
+
+	if rec := get("/expired?id=1"); rec.Header().Get("X-Cache") != "EXPIRED" || rec.Body.String() != "body" {
+		t.Errorf("expected EXPIRED but got %q %q", rec.Header().Get("X-Cache"), rec.Body.String())
+	}
+	if rec := get("/broken"); rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500 but got %d", rec.Code)
+	}
+}
+
+// plain hides the optional interfaces of the adapter.
+type plain struct {
+	cache.Adapter
+}
+
+func TestOptional(t *testing.T) {
+	a := Wrap(newMemory(t))
+	c, err := cache.New(a.Init)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.AddRule(Rule{Ops: []Op{OpAdd}, ErrorRate: 1, Times: 1})
+	if err := c.Add("a", "1"); err != ErrInjected {
+		t.Errorf("expected ErrInjected but got %v", err)
+	}
+	if err := c.Add("a", "1"); err != nil {
+		t.Errorf("expected the rule to be removed but got %v", err)
+	}
+	if err := c.Set("b", "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	a.AddRule(Rule{Pattern: "b", ExpiredRate: 1, Times: 1})
+	values := make(map[string]string)
+	err = c.GetMulti([]string{"a", "b"}, &values)
+	if err != nil || len(values) != 1 || values["a"] != "1" {
+		t.Errorf("expected only the value of a but got %v, %v", values, err)
+	}
+
+	a.AddRule(Rule{Ops: []Op{OpTouch}, ErrorRate: 1, Times: 1})
+	if err := c.Touch("a", time.Minute); err != ErrInjected {
+		t.Errorf("expected ErrInjected but got %v", err)
+	}
+	a.AddRule(Rule{Ops: []Op{OpIncr}, ErrorRate: 1, Times: 1})
+	if _, err := c.Incr("n", 1); err != ErrInjected {
+		t.Errorf("expected ErrInjected but got %v", err)
+	}
+	a.AddRule(Rule{Pattern: "a*", Ops: []Op{OpDelPrefix}, ErrorRate: 1, Times: 1})
+	if _, err := c.DelPrefix("a"); err != ErrInjected {
+		t.Errorf("expected ErrInjected but got %v", err)
+	}
+}
+
+func TestOptional_Unsupported(t *testing.T) {
+	// the wrapped adapter only has Get, Set and Del
+	a := Wrap(plain{newMemory(t)})
+	c, err := cache.New(a.Init)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Add("a", "1"); err != cache.ErrUnsupported {
+		t.Errorf("expected ErrUnsupported but got %v", err)
+	}
+	if err := c.SetWithTTL("a", "1", time.Minute); err != cache.ErrUnsupported {
+		t.Errorf("expected ErrUnsupported but got %v", err)
+	}
+	if err := a.Touch("a", time.Minute); err != cache.ErrUnsupported {
+		t.Errorf("expected ErrUnsupported but got %v", err)
+	}
+
+	// emulated with Set and Get
+	err = c.SetMulti(map[string]interface{}{"a": "1", "b": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]string)
+	err = c.GetMulti([]string{"a", "b"}, &values)
+	if err != nil || len(values) != 2 {
+		t.Errorf("expected both values but got %v, %v", values, err)
+	}
+}
@@ -0,0 +1,276 @@
+package faultadapter
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// Supports only reports the optional interfaces
+// that the wrapped adapter implements.
+func (a *Adapter) Supports(capability interface{}) bool {
+	return cache.Implements(a.adapter, capability)
+}
+
+// call decides the fault for a call without a context,
+// sleeps for its latency and returns its error.
+func (a *Adapter) call(op Op, key string) (fault, error) {
+	f := a.decide(op, key)
+	time.Sleep(f.latency)
+	return f, f.err
+}
+
+// batch decides the fault for every key of a batch call. The call
+// sleeps for the longest latency and fails with the first error,
+// except for cache.ErrExpired, which only removes that key.
+func (a *Adapter) batch(op Op, keys []string) (map[string]fault, error) {
+	faults := make(map[string]fault, len(keys))
+	var latency time.Duration
+	var err error
+	for _, key := range keys {
+		f := a.decide(op, key)
+		faults[key] = f
+
+		if f.latency > latency {
+			latency = f.latency
+		}
+		if err == nil && f.err != nil && f.err != cache.ErrExpired {
+			err = f.err
+		}
+	}
+
+	time.Sleep(latency)
+	return faults, err
+}
+
+// GetWithMetadata gets the faults of OpGet.
+func (a *Adapter) GetWithMetadata(key string) ([]byte, cache.Metadata, error) {
+	return a.GetWithMetadataContext(context.Background(), key)
+}
+
+// GetWithMetadataContext stops waiting for the
+// latency if the context is done, like GetWithContext.
+func (a *Adapter) GetWithMetadataContext(ctx context.Context, key string) ([]byte, cache.Metadata, error) {
+	getter, ok := a.adapter.(cache.MetadataGetter)
+	if !ok {
+		return nil, cache.Metadata{}, cache.ErrUnsupported
+	}
+	f := a.decide(OpGet, key)
+	if err := wait(ctx, f.latency); err != nil {
+		return nil, cache.Metadata{}, err
+	}
+	if f.err != nil {
+		return nil, cache.Metadata{}, f.err
+	}
+
+	var data []byte
+	var meta cache.Metadata
+	var err error
+	if contextGetter, ok := a.adapter.(cache.ContextMetadataGetter); ok {
+		data, meta, err = contextGetter.GetWithMetadataContext(ctx, key)
+	} else {
+		data, meta, err = getter.GetWithMetadata(key)
+	}
+	if err != nil {
+		return nil, cache.Metadata{}, err
+	}
+	if f.corrupt {
+		data = f.corruptData(data)
+	}
+	return data, meta, nil
+}
+
+// SetWithTTL gets the faults of OpSet.
+func (a *Adapter) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	setter, ok := a.adapter.(cache.TTLSetter)
+	if !ok {
+		return cache.ErrUnsupported
+	}
+	f, err := a.call(OpSet, key)
+	if err != nil {
+		return err
+	}
+
+	if f.corrupt {
+		value = f.corruptData(value)
+	}
+	return setter.SetWithTTL(key, value, ttl)
+}
+
+func (a *Adapter) Touch(key string, ttl time.Duration) error {
+	toucher, ok := a.adapter.(cache.Toucher)
+	if !ok {
+		return cache.ErrUnsupported
+	}
+	if _, err := a.call(OpTouch, key); err != nil {
+		return err
+	}
+
+	return toucher.Touch(key, ttl)
+}
+
+func (a *Adapter) Add(key string, value []byte) error {
+	adder, ok := a.adapter.(cache.Adder)
+	if !ok {
+		return cache.ErrUnsupported
+	}
+	f, err := a.call(OpAdd, key)
+	if err != nil {
+		return err
+	}
+
+	if f.corrupt {
+		value = f.corruptData(value)
+	}
+	return adder.Add(key, value)
+}
+
+// CompareAndSwap corrupts the new value, not the old one.
+func (a *Adapter) CompareAndSwap(key string, old, new []byte) error {
+	swapper, ok := a.adapter.(cache.Swapper)
+	if !ok {
+		return cache.ErrUnsupported
+	}
+	f, err := a.call(OpCAS, key)
+	if err != nil {
+		return err
+	}
+
+	if f.corrupt {
+		new = f.corruptData(new)
+	}
+	return swapper.CompareAndSwap(key, old, new)
+}
+
+func (a *Adapter) Incr(key string, delta int64) (int64, error) {
+	incrementer, ok := a.adapter.(cache.Incrementer)
+	if !ok {
+		return 0, cache.ErrUnsupported
+	}
+	if _, err := a.call(OpIncr, key); err != nil {
+		return 0, err
+	}
+
+	return incrementer.Incr(key, delta)
+}
+
+// GetMulti gets the faults of OpGet for every key.
+func (a *Adapter) GetMulti(keys []string) (map[string][]byte, error) {
+	getter, ok := a.adapter.(cache.BatchGetter)
+	if !ok {
+		return nil, cache.ErrUnsupported
+	}
+	faults, err := a.batch(OpGet, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := getter.GetMulti(keys)
+	if err != nil {
+		return nil, err
+	}
+	for key, f := range faults {
+		data, found := result[key]
+		if f.err == cache.ErrExpired {
+			delete(result, key)
+		} else if found && f.corrupt {
+			result[key] = f.corruptData(data)
+		}
+	}
+	return result, nil
+}
+
+// SetMulti gets the faults of OpSet for every key. The
+// keys are sorted, so that the faults are reproducible.
+func (a *Adapter) SetMulti(items map[string][]byte) error {
+	setter, ok := a.adapter.(cache.BatchSetter)
+	if !ok {
+		return cache.ErrUnsupported
+	}
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	faults, err := a.batch(OpSet, keys)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string][]byte, len(items))
+	for key, value := range items {
+		if f := faults[key]; f.corrupt {
+			value = f.corruptData(value)
+		}
+		values[key] = value
+	}
+	return setter.SetMulti(values)
+}
+
+// DelMulti gets the faults of OpDel for every key.
+func (a *Adapter) DelMulti(keys []string) error {
+	deleter, ok := a.adapter.(cache.BatchDeleter)
+	if !ok {
+		return cache.ErrUnsupported
+	}
+	if _, err := a.batch(OpDel, keys); err != nil {
+		return err
+	}
+
+	return deleter.DelMulti(keys)
+}
+
+// Scan gets the faults of OpScan for the prefix.
+func (a *Adapter) Scan(opts cache.ScanOptions) ([]string, string, error) {
+	scanner, ok := a.adapter.(cache.Scanner)
+	if !ok {
+		return nil, "", cache.ErrUnsupported
+	}
+	if _, err := a.call(OpScan, opts.Prefix); err != nil {
+		return nil, "", err
+	}
+
+	return scanner.Scan(opts)
+}
+
+// DelPrefix gets the faults of OpDelPrefix for the prefix.
+func (a *Adapter) DelPrefix(prefix string) (int, error) {
+	deleter, ok := a.adapter.(cache.PrefixDeleter)
+	if !ok {
+		return 0, cache.ErrUnsupported
+	}
+	if _, err := a.call(OpDelPrefix, prefix); err != nil {
+		return 0, err
+	}
+
+	return deleter.DelPrefix(prefix)
+}
+
+// Clear gets the faults of OpClear for an empty key.
+func (a *Adapter) Clear() error {
+	clearer, ok := a.adapter.(cache.Clearer)
+	if !ok {
+		return cache.ErrUnsupported
+	}
+	if _, err := a.call(OpClear, ""); err != nil {
+		return err
+	}
+
+	return clearer.Clear()
+}
+
+// Dump gets the faults of OpDump for an empty key.
+func (a *Adapter) Dump(fn func(rec cache.Record) error) error {
+	dumper, ok := a.adapter.(cache.Dumper)
+	if !ok {
+		return cache.ErrUnsupported
+	}
+	if _, err := a.call(OpDump, ""); err != nil {
+		return err
+	}
+
+	return dumper.Dump(fn)
+}
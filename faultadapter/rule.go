@@ -0,0 +1,176 @@
+package faultadapter
+
+import (
+	"math"
+	"math/rand"
+	"time"
+	"unicode/utf8"
+)
+
+// Op is an operation of the adapter that a Rule applies to.
+// GetWithMetadata and GetMulti are OpGet, SetWithTTL and SetMulti
+// are OpSet and DelMulti is OpDel. The batch calls decide the faults
+// for every key. The key of OpScan and OpDelPrefix is the prefix and
+// the key of OpClear and OpDump is empty.
+type Op string
+
+const (
+	OpGet       Op = "get"
+	OpSet       Op = "set"
+	OpDel       Op = "del"
+	OpAdd       Op = "add"
+	OpCAS       Op = "cas"
+	OpTouch     Op = "touch"
+	OpIncr      Op = "incr"
+	OpScan      Op = "scan"
+	OpDelPrefix Op = "delprefix"
+	OpClear     Op = "clear"
+	OpDump      Op = "dump"
+)
+
+// Latency returns the delay for one call. It gets the random
+// source of the adapter, so that the delays are reproducible
+// with the same seed.
+type Latency func(r *rand.Rand) time.Duration
+
+// Fixed always delays by d.
+func Fixed(d time.Duration) Latency {
+	return func(*rand.Rand) time.Duration {
+		return d
+	}
+}
+
+// Uniform delays by a random duration between min and max.
+func Uniform(min, max time.Duration) Latency {
+	return func(r *rand.Rand) time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(r.Int63n(int64(max-min)))
+	}
+}
+
+// Normal delays by a normally distributed duration. Negative
+// durations are rounded up to zero.
+func Normal(mean, stddev time.Duration) Latency {
+	return func(r *rand.Rand) time.Duration {
+		d := time.Duration(r.NormFloat64()*float64(stddev)) + mean
+		if d < 0 {
+			return 0
+		}
+		return d
+	}
+}
+
+// Exponential delays by an exponentially distributed duration,
+// which has a long tail of slow calls.
+func Exponential(mean time.Duration) Latency {
+	return func(r *rand.Rand) time.Duration {
+		d := r.ExpFloat64() * float64(mean)
+		if d > math.MaxInt64 {
+			return math.MaxInt64
+		}
+		return time.Duration(d)
+	}
+}
+
+// Rule describes the faults for the keys that match the Pattern.
+// Every matching rule is applied in the order in which they were
+// added: the latencies add up and the first fault that happens wins.
+type Rule struct {
+	// Pattern is matched against the key. A * matches any number
+	// of characters (also a /) and a ? exactly one character.
+	// An empty pattern matches every key.
+	Pattern string
+
+	// Ops limits the rule to these operations. If it is
+	// empty the rule applies to every operation.
+	Ops []Op
+
+	// Times is the number of calls the rule applies to (whether
+	// a fault happened or not) before it is removed. Zero means
+	// that the rule is never removed.
+	Times int
+
+	// Latency delays the call before it reaches the adapter.
+	Latency Latency
+
+	// ErrorRate is the probability (between 0 and 1) that the
+	// call fails with Err without reaching the adapter.
+	ErrorRate float64
+	// Err is returned for the ErrorRate. The default is ErrInjected.
+	Err error
+
+	// ExpiredRate is the probability that Get returns
+	// cache.ErrExpired without reaching the adapter.
+	// GetMulti leaves out the key instead.
+	ExpiredRate float64
+
+	// CorruptRate is the probability that one byte of the value
+	// is changed, either of the value that Get returns or of the
+	// value that Set writes (also Add and the new value of
+	// CompareAndSwap).
+	CorruptRate float64
+}
+
+func (r *Rule) matches(op Op, key string) bool {
+	if len(r.Ops) > 0 {
+		var found bool
+		for _, o := range r.Ops {
+			if o == op {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return r.Pattern == "" || match(r.Pattern, key)
+}
+
+// match reports whether the key matches the pattern.
+func match(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if match(pattern, key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if key == "" {
+				return false
+			}
+			_, size := utf8.DecodeRuneInString(key)
+			key = key[size:]
+		default:
+			if key == "" || pattern[0] != key[0] {
+				return false
+			}
+			key = key[1:]
+		}
+		pattern = pattern[1:]
+	}
+	return key == ""
+}
+
+// happens reports whether an event with that probability happens.
+// No random number is used for the rates 0 and 1, so that such
+// rules don't change the random sequence of the other rules.
+func happens(r *rand.Rand, rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	if rate >= 1 {
+		return true
+	}
+	return r.Float64() < rate
+}
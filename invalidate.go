@@ -0,0 +1,165 @@
+package cache
+
+import "sync"
+
+// Invalidation tells other instances which items changed.
+type Invalidation struct {
+	Keys []string `json:",omitempty"`
+	// Prefixes invalidates every key that starts with one of
+	// them. The empty prefix invalidates everything.
+	Prefixes []string `json:",omitempty"`
+}
+
+// Invalidator sends invalidations between the instances that
+// share the last adapter, so that they can evict their local
+// copies (see package broadcast for implementations).
+type Invalidator interface {
+	// Publish sends the invalidation to every other instance.
+	Publish(msg Invalidation) error
+	// Subscribe registers fn, which is called with the
+	// invalidations published by the other instances.
+	Subscribe(fn func(msg Invalidation)) error
+}
+
+// MaxPendingInvalidations is how many invalidations can wait to be
+// published. Once more are waiting new ones are dropped and logged.
+var MaxPendingInvalidations = 1024
+
+// SetInvalidator publishes every Set, Add, CompareAndSwap, Del,
+// DelPrefix and Clear to inv. When another instance publishes an
+// invalidation the items are evicted from the local adapters.
+//
+// The invalidations are published in the background, one after
+// another, so that a slow or unreachable instance doesn't slow
+// down the writes. A write that succeeded never fails because of
+// the invalidation, errors are only logged (see WithLogger). Close
+// waits until the pending invalidations are published.
+//
+// The last adapter is shared between the instances, every
+// adapter in front of it is treated as local.
+func (c *Cache) SetInvalidator(inv Invalidator) error {
+	err := inv.Subscribe(func(msg Invalidation) {
+		err := c.evict(msg)
+		if err != nil {
+			c.logf("invalidate err: %v", err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if c.publisher != nil {
+		c.publisher.close()
+	}
+	c.publisher = newPublisher(inv, c.logf)
+	return nil
+}
+
+// publish queues the invalidation if there is an invalidator.
+func (c *Cache) publish(msg Invalidation) {
+	if c.publisher != nil {
+		c.publisher.publish(msg)
+	}
+}
+
+// publisher publishes the invalidations in its own goroutine.
+type publisher struct {
+	inv     Invalidator
+	logf    func(format string, v ...interface{})
+	pending chan Invalidation
+	done    chan struct{}
+
+	m      sync.RWMutex
+	closed bool
+}
+
+func newPublisher(inv Invalidator, logf func(format string, v ...interface{})) *publisher {
+	p := &publisher{
+		inv:     inv,
+		logf:    logf,
+		pending: make(chan Invalidation, MaxPendingInvalidations),
+		done:    make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+func (p *publisher) run() {
+	defer close(p.done)
+
+	for msg := range p.pending {
+		err := p.inv.Publish(msg)
+		if err != nil {
+			p.logf("invalidate err: publish: %v", err)
+		}
+	}
+}
+
+// publish never blocks. If too many invalidations
+// are pending the invalidation is dropped.
+func (p *publisher) publish(msg Invalidation) {
+	p.m.RLock()
+	defer p.m.RUnlock()
+	if p.closed {
+		return
+	}
+
+	select {
+	case p.pending <- msg:
+	default:
+		p.logf("invalidate err: dropped %d keys and %d prefixes because too many invalidations are pending", len(msg.Keys), len(msg.Prefixes))
+	}
+}
+
+// close waits until the pending invalidations are published.
+func (p *publisher) close() {
+	p.m.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.pending)
+	}
+	p.m.Unlock()
+
+	<-p.done
+}
+
+// Evict deletes the items from the local adapters, which are
+// all adapters except the last one. The next Get reads the
+// item from the last adapter again. The keys are used as they
+// are stored, so WithKeyTransformer is not applied again.
+func (c *Cache) Evict(keys ...string) error {
+	for _, key := range keys {
+		err := c.delUpper(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Cache) evict(msg Invalidation) error {
+	err := c.Evict(msg.Keys...)
+	if err != nil {
+		return err
+	}
+
+	for _, prefix := range msg.Prefixes {
+		for _, adapter := range c.adapters[:len(c.adapters)-1] {
+			var deleter PrefixDeleter
+			var scanner Scanner
+			if as(adapter, &deleter) {
+				_, err = deleter.DelPrefix(prefix)
+			} else if as(adapter, &scanner) {
+				_, err = delScanned(adapter, scanner, prefix)
+			} else {
+				err = ErrUnsupported
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package cache_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+type invalidatorMock struct {
+	published []cache.Invalidation
+	fn        func(msg cache.Invalidation)
+	err       error
+}
+
+func (m *invalidatorMock) Publish(msg cache.Invalidation) error {
+	m.published = append(m.published, msg)
+	return m.err
+}
+func (m *invalidatorMock) Subscribe(fn func(msg cache.Invalidation)) error {
+	m.fn = fn
+	return nil
+}
+
+func newInvalidateCache(t *testing.T) (*cache.Cache, *AdapterMock, *AdapterMock, *invalidatorMock) {
+	local := &AdapterMock{
+		SetFunc: func(key string, data []byte) error { return nil },
+		DelFunc: func(key string) error { return nil },
+	}
+	shared := &AdapterMock{
+		SetFunc: func(key string, data []byte) error { return nil },
+		DelFunc: func(key string) error { return nil },
+	}
+	c, err := cache.New(
+		func() (cache.Adapter, error) { return local, nil },
+		func() (cache.Adapter, error) { return shared, nil },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inv := &invalidatorMock{}
+	err = c.SetInvalidator(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, local, shared, inv
+}
+
+func TestInvalidate_Publish(t *testing.T) {
+	c, _, _, inv := newInvalidateCache(t)
+
+	err := c.Set("a", "value")
+	if err != nil {
+		t.Error(err)
+	}
+	err = c.Del("b")
+	if err != nil {
+		t.Error(err)
+	}
+	// waits for the background publishing
+	c.Close()
+
+	if len(inv.published) != 2 {
+		t.Fatalf("expected 2 invalidations but got %d", len(inv.published))
+	}
+	if inv.published[0].Keys[0] != "a" || inv.published[1].Keys[0] != "b" {
+		t.Errorf("got wrong invalidations %v", inv.published)
+	}
+}
+
+func TestInvalidate_PublishError(t *testing.T) {
+	l := &logger{}
+	c, err := cache.NewWithOptions(
+		cache.WithAdapters(func() (cache.Adapter, error) {
+			return &AdapterMock{SetFunc: func(key string, data []byte) error { return nil }}, nil
+		}),
+		cache.WithLogger(l),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv := &invalidatorMock{err: errors.New("network down")}
+	c.SetInvalidator(inv)
+
+	err = c.Set("a", "value")
+	if err != nil {
+		t.Errorf("expected the set to succeed but got %v", err)
+	}
+	c.Close()
+	if len(l.lines) != 1 || l.lines[0] != "invalidate err: publish: network down" {
+		t.Errorf("unexpected log %v", l.lines)
+	}
+}
+
+// blockingInvalidator waits in Publish until it is released.
+type blockingInvalidator struct {
+	release   chan struct{}
+	published int
+}
+
+func (b *blockingInvalidator) Publish(msg cache.Invalidation) error {
+	<-b.release
+	b.published++
+	return nil
+}
+func (b *blockingInvalidator) Subscribe(fn func(msg cache.Invalidation)) error {
+	return nil
+}
+
+func TestInvalidate_SlowPublish(t *testing.T) {
+	c, err := cache.New(func() (cache.Adapter, error) {
+		return &AdapterMock{SetFunc: func(key string, data []byte) error { return nil }}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv := &blockingInvalidator{release: make(chan struct{})}
+	c.SetInvalidator(inv)
+
+	done := make(chan error)
+	go func() {
+		done <- c.Set("a", "value")
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the set not to wait for the invalidation")
+	}
+
+	close(inv.release)
+	c.Close()
+	if inv.published != 1 {
+		t.Errorf("expected the invalidation to be published on close but got %d", inv.published)
+	}
+}
+
+func TestInvalidate_Receive(t *testing.T) {
+	_, local, shared, inv := newInvalidateCache(t)
+
+	inv.fn(cache.Invalidation{Keys: []string{"a", "b"}})
+
+	if len(local.DelCalls()) != 2 {
+		t.Errorf("expected 2 deletes in the local adapter but got %d", len(local.DelCalls()))
+	}
+	if len(shared.DelCalls()) != 0 {
+		t.Error("the shared adapter should not be changed")
+	}
+}
+
+func TestEvict(t *testing.T) {
+	c, local, shared, inv := newInvalidateCache(t)
+
+	err := c.Evict("a")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(local.DelCalls()) != 1 || len(shared.DelCalls()) != 0 {
+		t.Error("expected only the local adapter to be changed")
+	}
+	if len(inv.published) != 0 {
+		t.Error("evict should not publish")
+	}
+}
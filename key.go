@@ -0,0 +1,294 @@
+package cache
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Key is a key that was built from several parts with K or NewKey.
+//
+// The parts are joined with ":" and the characters that have a meaning
+// in the encoding are escaped with "\", so that different parts never
+// give the same key: K("a:b", "c") is "a\:b:c" while K("a", "b:c") is
+// "a:b\:c". A key is also a prefix of every key that has more parts,
+// which means that string(K("user", id))+":" can be passed to DelPrefix.
+//
+// Strings are written as they are. Numbers start with #, bools with ?
+// and byte slices with %, so K("user", 1) is "user:#1" and not the same
+// key as K("user", "1"). A string that starts with one of these is
+// escaped. Slices and arrays are written as [a,b], structs as
+// {Name=value} with the fields in the order of the declaration and maps
+// as &{key=value} with the keys sorted. Pointers and interfaces are
+// followed and nil is written as ~. A value that contains itself returns
+// an error. A Key is inserted without escaping, so K(K("a", "b"), "c")
+// equals K("a", "b", "c"). Values that implement encoding.TextMarshaler,
+// like time.Time, are written as text.
+//
+// A key must not start with ReservedPrefix, NewKey returns an error.
+type Key string
+
+// String returns the key that is passed to the adapters.
+func (k Key) String() string {
+	return string(k)
+}
+
+// K is like NewKey but panics if a part can't be encoded,
+// which is a programming error.
+func K(parts ...interface{}) Key {
+	key, err := NewKey(parts...)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// ReservedPrefix starts the keys of items that adapters keep for
+// themselves, like the generation and the locks in the table of
+// dynadapter. Scan, DelPrefix, Clear and Dump of such an adapter
+// skip them, so the keys of the cache should not start with it.
+const ReservedPrefix = "__"
+
+// NewKey builds a key from the parts. Channels, functions and
+// complex numbers can't be encoded and return an error, as does
+// a key that starts with ReservedPrefix.
+func NewKey(parts ...interface{}) (Key, error) {
+	var b strings.Builder
+	seen := make(map[visit]bool)
+	for i, part := range parts {
+		if i > 0 {
+			b.WriteByte(':')
+		}
+		err := encodeKey(&b, seen, reflect.ValueOf(part))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	key := b.String()
+	if strings.HasPrefix(key, ReservedPrefix) {
+		return "", fmt.Errorf("cache: key %q starts with the reserved prefix %q", key, ReservedPrefix)
+	}
+	return Key(key), nil
+}
+
+var (
+	keyType           = reflect.TypeOf(Key(""))
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+	keyEscaper = strings.NewReplacer(
+		`\`, `\\`, `:`, `\:`, `,`, `\,`, `=`, `\=`,
+		`[`, `\[`, `]`, `\]`, `{`, `\{`, `}`, `\}`, `~`, `\~`,
+	)
+)
+
+// The type tags that start the parts that are not strings.
+const (
+	numberTag = '#'
+	boolTag   = '?'
+	bytesTag  = '%'
+	mapTag    = '&'
+)
+
+// writeString escapes the string. A type tag is only
+// escaped at the start, because only there it has a meaning.
+func writeString(b *strings.Builder, s string) {
+	if s != "" && (s[0] == numberTag || s[0] == boolTag || s[0] == bytesTag || s[0] == mapTag) {
+		b.WriteByte('\\')
+	}
+	keyEscaper.WriteString(b, s)
+}
+
+// visit is a pointer, map or slice that is being encoded.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// enter marks v as being encoded. It returns an error if v is
+// already being encoded, which means that v contains itself.
+func enter(seen map[visit]bool, v reflect.Value) (visit, error) {
+	id := visit{ptr: v.Pointer(), typ: v.Type()}
+	if seen[id] {
+		return id, fmt.Errorf("cache: can't use %s in a key because it contains itself", v.Type())
+	}
+	seen[id] = true
+	return id, nil
+}
+
+func encodeKey(b *strings.Builder, seen map[visit]bool, v reflect.Value) error {
+	if !v.IsValid() {
+		b.WriteByte('~')
+		return nil
+	}
+
+	if v.Type() == keyType {
+		b.WriteString(v.String())
+		return nil
+	}
+	if v.Type().Implements(textMarshalerType) && !isNil(v) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		writeString(b, string(text))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		writeString(b, v.String())
+	case reflect.Bool:
+		b.WriteByte(boolTag)
+		b.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b.WriteByte(numberTag)
+		b.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		b.WriteByte(numberTag)
+		b.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		b.WriteByte(numberTag)
+		b.WriteString(strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()))
+
+	case reflect.Interface:
+		if v.IsNil() {
+			b.WriteByte('~')
+			return nil
+		}
+		return encodeKey(b, seen, v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() {
+			b.WriteByte('~')
+			return nil
+		}
+		id, err := enter(seen, v)
+		if err != nil {
+			return err
+		}
+		defer delete(seen, id)
+		return encodeKey(b, seen, v.Elem())
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			b.WriteByte(bytesTag)
+			keyEscaper.WriteString(b, string(v.Bytes()))
+			return nil
+		}
+		if v.Kind() == reflect.Slice && v.Len() > 0 {
+			id, err := enter(seen, v)
+			if err != nil {
+				return err
+			}
+			defer delete(seen, id)
+		}
+
+		b.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			err := encodeKey(b, seen, v.Index(i))
+			if err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+
+	case reflect.Struct:
+		return encodeStruct(b, seen, v)
+	case reflect.Map:
+		if v.Len() > 0 {
+			id, err := enter(seen, v)
+			if err != nil {
+				return err
+			}
+			defer delete(seen, id)
+		}
+		return encodeMap(b, seen, v)
+
+	default:
+		return fmt.Errorf("cache: can't use %s in a key", v.Type())
+	}
+	return nil
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
+
+func encodeStruct(b *strings.Builder, seen map[visit]bool, v reflect.Value) error {
+	t := v.Type()
+
+	b.WriteByte('{')
+	var written int
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+
+		if written > 0 {
+			b.WriteByte(',')
+		}
+		keyEscaper.WriteString(b, field.Name)
+		b.WriteByte('=')
+		err := encodeKey(b, seen, v.Field(i))
+		if err != nil {
+			return err
+		}
+		written++
+	}
+	b.WriteByte('}')
+
+	// otherwise different values would give the same key
+	if written == 0 && t.NumField() > 0 {
+		return fmt.Errorf("cache: can't use %s in a key because it has no exported fields", t)
+	}
+	return nil
+}
+
+func encodeMap(b *strings.Builder, seen map[visit]bool, v reflect.Value) error {
+	type entry struct {
+		key, value string
+	}
+
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		var kb, vb strings.Builder
+		err := encodeKey(&kb, seen, iter.Key())
+		if err != nil {
+			return err
+		}
+		err = encodeKey(&vb, seen, iter.Value())
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{kb.String(), vb.String()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+
+	b.WriteByte(mapTag)
+	b.WriteByte('{')
+	for i, e := range entries {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(e.key)
+		b.WriteByte('=')
+		b.WriteString(e.value)
+	}
+	b.WriteByte('}')
+	return nil
+}
@@ -0,0 +1,152 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+func TestK(t *testing.T) {
+	type feed struct {
+		User   int
+		Tags   []string
+		secret string
+	}
+	id := 42
+
+	tests := []struct {
+		name     string
+		key      cache.Key
+		expected string
+	}{
+		{"Parts", cache.K("user", id, "feed"), "user:#42:feed"},
+		{"Escape", cache.K("a:b", `c\d`, "[e]"), `a\:b:c\\d:\[e\]`},
+		{"Types", cache.K(true, uint8(7), -3, 1.5, nil), "?true:#7:#-3:#1.5:~"},
+		{"Tags", cache.K("#1", "?", "a#"), `\#1:\?:a#`},
+		{"Pointer", cache.K(&id, (*int)(nil)), "#42:~"},
+		{"Slice", cache.K([]interface{}{"a", 1, []int{2, 3}}), "[a,#1,[#2,#3]]"},
+		{"Bytes", cache.K([]byte("raw")), "%raw"},
+		{"Struct", cache.K(feed{User: 1, Tags: []string{"x,y"}}), `{User=#1,Tags=[x\,y]}`},
+		{"Map", cache.K(map[string]int{"b": 2, "a": 1}), "&{a=#1,b=#2}"},
+		{"Key", cache.K(cache.K("a", "b"), "c"), "a:b:c"},
+		{"Time", cache.K(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)), `2020-01-02T03\:04\:05Z`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.key.String() != test.expected {
+				t.Errorf("expected '%s' but got '%s'", test.expected, test.key)
+			}
+		})
+	}
+}
+
+func TestK_NoCollisions(t *testing.T) {
+	keys := []cache.Key{
+		cache.K("a:b", "c"),
+		cache.K("a", "b:c"),
+		cache.K("a", "b", "c"),
+		cache.K([]string{"a", "b"}, "c"),
+		cache.K([]string{"a,b"}, "c"),
+		cache.K("~"),
+		cache.K(nil),
+		cache.K(1),
+		cache.K("1"),
+		cache.K("#1"),
+		cache.K(true),
+		cache.K("true"),
+		cache.K([]byte("a")),
+		cache.K("a"),
+		cache.K("%a"),
+		cache.K(struct{ A int }{1}),
+		cache.K(map[string]int{"A": 1}),
+		cache.K(struct{}{}),
+		cache.K(map[string]int{}),
+		cache.K("&{}"),
+	}
+
+	seen := make(map[cache.Key]bool)
+	for _, key := range keys {
+		if seen[key] {
+			t.Errorf("key '%s' was built twice", key)
+		}
+		seen[key] = true
+	}
+}
+
+func TestK_Prefix(t *testing.T) {
+	prefix := cache.K("user", 1).String() + ":"
+
+	if key := cache.K("user", 1, "feed").String(); key[:len(prefix)] != prefix {
+		t.Errorf("expected '%s' to start with '%s'", key, prefix)
+	}
+	if key := cache.K("user", 10).String(); len(key) >= len(prefix) && key[:len(prefix)] == prefix {
+		t.Errorf("expected '%s' not to start with '%s'", key, prefix)
+	}
+}
+
+func TestNewKey_Cycle(t *testing.T) {
+	type node struct {
+		Next *node
+	}
+	n := &node{}
+	n.Next = n
+	if _, err := cache.NewKey(n); err == nil {
+		t.Error("expected an error for a pointer cycle")
+	}
+
+	s := []interface{}{nil}
+	s[0] = s
+	if _, err := cache.NewKey(s); err == nil {
+		t.Error("expected an error for a slice that contains itself")
+	}
+
+	m := map[string]interface{}{}
+	m["m"] = m
+	if _, err := cache.NewKey(m); err == nil {
+		t.Error("expected an error for a map that contains itself")
+	}
+
+	// the same pointer twice is not a cycle
+	shared := &node{}
+	key, err := cache.NewKey(shared, []*node{shared, shared})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "{Next=~}:[{Next=~},{Next=~}]" {
+		t.Errorf("unexpected key '%s'", key)
+	}
+}
+
+func TestNewKey_Reserved(t *testing.T) {
+	_, err := cache.NewKey(cache.ReservedPrefix + "generation")
+	if err == nil {
+		t.Error("expected an error for the reserved prefix")
+	}
+	_, err = cache.NewKey("user", cache.ReservedPrefix)
+	if err != nil {
+		t.Errorf("expected only the start of the key to be reserved but got %v", err)
+	}
+}
+
+func TestNewKey_Unsupported(t *testing.T) {
+	_, err := cache.NewKey("user", make(chan int))
+	if err == nil {
+		t.Error("expected an error for a channel")
+	}
+
+	type private struct {
+		id int
+	}
+	_, err = cache.NewKey(private{id: 1})
+	if err == nil {
+		t.Error("expected an error for a struct without exported fields")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected K to panic")
+		}
+	}()
+	cache.K(func() {})
+}
@@ -0,0 +1,164 @@
+package cache
+
+// ScanOptions select the keys that are returned by a Scanner.
+type ScanOptions struct {
+	// Prefix only returns keys that start with it.
+	Prefix string
+	// Cursor is the position to continue from. It is
+	// empty for the first page.
+	Cursor string
+	// Limit is the maximum number of keys per page.
+	Limit int
+	// IncludeExpired also returns expired items.
+	IncludeExpired bool
+}
+
+// Scanner is implemented by adapters that can list their keys.
+// A page contains at most Limit keys and next is the cursor
+// for the following page. It is empty after the last page.
+type Scanner interface {
+	Scan(opts ScanOptions) (keys []string, next string, err error)
+}
+
+// DefaultPageSize is the number of keys that are requested
+// from an adapter at once.
+var DefaultPageSize = 100
+
+// KeyIterator iterates over the keys of every adapter that
+// implements Scanner. A key that is in several adapters is only
+// returned once. The keys are requested page by page.
+//
+// To dedupe, the keys of the adapters in front of the last one are
+// kept in memory until the last adapter returns them. These are
+// usually local caches, so the memory is bounded by their size. The
+// keys of the last adapter are never kept, however big the table.
+//
+//	it := c.Iterate("user:")
+//	for it.Next() {
+//		fmt.Println(it.Key())
+//	}
+//	if err := it.Err(); err != nil {
+//		// ...
+//	}
+type KeyIterator struct {
+	// PageSize and IncludeExpired can be changed
+	// before the first call to Next.
+	PageSize       int
+	IncludeExpired bool
+
+	scanners []Scanner
+	prefix   string
+	cursor   string
+
+	page  []string
+	final bool // page is from the last adapter
+	key   string
+	seen  map[string]struct{}
+	err   error
+}
+
+// Iterate returns an iterator over the keys that start with prefix.
+// Expired items are skipped. With WithKeyTransformer the prefix is
+// transformed and the keys are returned as they are stored, that is
+// transformed. They can't be passed to Get or Del again, because
+// those would transform them a second time.
+func (c *Cache) Iterate(prefix string) *KeyIterator {
+	it := &KeyIterator{
+		PageSize: DefaultPageSize,
+		prefix:   c.key(prefix),
+		seen:     make(map[string]struct{}),
+	}
+	for _, adapter := range c.adapters {
+		var scanner Scanner
+		if as(adapter, &scanner) {
+			it.scanners = append(it.scanners, scanner)
+		}
+	}
+	if len(it.scanners) == 0 {
+		it.err = ErrUnsupported
+	}
+
+	return it
+}
+
+// Next moves to the next key. It returns false if there
+// are no more keys or an error occurred.
+func (it *KeyIterator) Next() bool {
+	for it.err == nil {
+		if len(it.page) == 0 && !it.fetch() {
+			return false
+		}
+
+		for len(it.page) > 0 {
+			key := it.page[0]
+			it.page = it.page[1:]
+
+			if _, ok := it.seen[key]; ok {
+				if it.final {
+					// the last adapter returns every key once,
+					// so it can't come up again.
+					delete(it.seen, key)
+				}
+				continue
+			}
+			if !it.final {
+				it.seen[key] = struct{}{}
+			}
+			it.key = key
+			return true
+		}
+	}
+	return false
+}
+
+// fetch requests the next page. It returns false if
+// there are no more pages or an error occurred.
+func (it *KeyIterator) fetch() bool {
+	for len(it.scanners) > 0 {
+		keys, next, err := it.scanners[0].Scan(ScanOptions{
+			Prefix:         it.prefix,
+			Cursor:         it.cursor,
+			Limit:          it.PageSize,
+			IncludeExpired: it.IncludeExpired,
+		})
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.cursor = next
+		it.final = len(it.scanners) == 1
+		if next == "" {
+			// continue with the next adapter
+			it.scanners = it.scanners[1:]
+		}
+		if len(keys) > 0 {
+			it.page = keys
+			return true
+		}
+	}
+	return false
+}
+
+// Key returns the current key.
+func (it *KeyIterator) Key() string {
+	return it.key
+}
+
+// Err returns the error that stopped the iteration.
+func (it *KeyIterator) Err() error {
+	return it.err
+}
+
+// Keys returns all keys that start with prefix.
+// Expired items are skipped. Like Iterate it returns
+// the keys as they are stored, see WithKeyTransformer.
+func (c *Cache) Keys(prefix string) ([]string, error) {
+	var keys []string
+
+	it := c.Iterate(prefix)
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+	return keys, it.Err()
+}
@@ -0,0 +1,23 @@
+//go:build go1.23
+// +build go1.23
+
+package cache
+
+import "iter"
+
+// All returns the keys as an iter.Seq, so that they can be used
+// with range. Check Err after the loop.
+//
+//	it := c.Iterate("user:")
+//	for key := range it.All() {
+//		fmt.Println(key)
+//	}
+func (it *KeyIterator) All() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for it.Next() {
+			if !yield(it.Key()) {
+				return
+			}
+		}
+	}
+}
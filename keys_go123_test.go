@@ -0,0 +1,22 @@
+//go:build go1.23
+// +build go1.23
+
+package cache_test
+
+import "testing"
+
+func TestIterate_All(t *testing.T) {
+	c := newKeysCache(t)
+
+	var count int
+	it := c.Iterate("user:")
+	for range it.All() {
+		count++
+	}
+	if it.Err() != nil {
+		t.Error(it.Err())
+	}
+	if count != 3 {
+		t.Errorf("expected 3 keys but got %d", count)
+	}
+}
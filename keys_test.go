@@ -0,0 +1,176 @@
+package cache_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/JohannesKaufmann/dynamodb-cache/memadapter"
+)
+
+func newKeysCache(t *testing.T) *cache.Cache {
+	mem1, _ := memadapter.New(time.Hour, false)()
+	mem2, _ := memadapter.New(time.Hour, false)()
+
+	c, err := cache.New(
+		func() (cache.Adapter, error) { return mem1, nil },
+		func() (cache.Adapter, error) { return mem2, nil },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Set("user:1", 1)
+	c.Set("user:2", 2)
+	c.Set("post:1", 1)
+	// only in the second adapter
+	mem2.Set("user:3", nil)
+
+	return c
+}
+
+func TestKeys(t *testing.T) {
+	c := newKeysCache(t)
+
+	keys, err := c.Keys("user:")
+	if err != nil {
+		t.Error(err)
+	}
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"user:1", "user:2", "user:3"}) {
+		t.Errorf("got different keys: %v", keys)
+	}
+}
+
+func TestIterate_Pages(t *testing.T) {
+	c := newKeysCache(t)
+
+	it := c.Iterate("")
+	it.PageSize = 1
+
+	var keys []string
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+	if it.Err() != nil {
+		t.Error(it.Err())
+	}
+	if len(keys) != 4 {
+		t.Errorf("expected 4 unique keys but got %v", keys)
+	}
+}
+
+func TestIterate_OnlyUpper(t *testing.T) {
+	mem1, _ := memadapter.New(time.Hour, false)()
+	mem2, _ := memadapter.New(time.Hour, false)()
+	c, err := cache.New(
+		func() (cache.Adapter, error) { return mem1, nil },
+		func() (cache.Adapter, error) { return mem2, nil },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("user:1", 1)
+	// only in the first adapter
+	mem1.Set("user:2", nil)
+	// only in the second adapter
+	mem2.Set("user:3", nil)
+
+	it := c.Iterate("")
+	it.PageSize = 1
+
+	var keys []string
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+	if it.Err() != nil {
+		t.Error(it.Err())
+	}
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"user:1", "user:2", "user:3"}) {
+		t.Errorf("got different keys: %v", keys)
+	}
+}
+
+func TestKeys_Unsupported(t *testing.T) {
+	adapter1 := func() (cache.Adapter, error) {
+		return &AdapterMock{}, nil
+	}
+	c, err := cache.New(adapter1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = c.Keys("")
+	if err != cache.ErrUnsupported {
+		t.Error(err)
+	}
+}
+
+type scannerMock struct {
+	*AdapterMock
+	keys []string
+}
+
+func (m *scannerMock) Scan(opts cache.ScanOptions) ([]string, string, error) {
+	return m.keys, "", nil
+}
+
+func TestDelPrefix(t *testing.T) {
+	c := newKeysCache(t)
+
+	count, err := c.DelPrefix("user:")
+	if err != nil {
+		t.Error(err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 deleted items but got %d", count)
+	}
+
+	keys, _ := c.Keys("")
+	if len(keys) != 1 || keys[0] != "post:1" {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+}
+
+func TestDelPrefix_Scanner(t *testing.T) {
+	mock1 := &scannerMock{
+		AdapterMock: &AdapterMock{
+			DelFunc: func(key string) error {
+				return nil
+			},
+		},
+		keys: []string{"user:1", "user:2"},
+	}
+	adapter1 := func() (cache.Adapter, error) {
+		return mock1, nil
+	}
+	c, err := cache.New(adapter1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	count, err := c.DelPrefix("user:")
+	if err != nil {
+		t.Error(err)
+	}
+	if count != 2 || len(mock1.DelCalls()) != 2 {
+		t.Errorf("expected 2 deleted items but got %d", count)
+	}
+}
+
+func TestClear(t *testing.T) {
+	c := newKeysCache(t)
+
+	err := c.Clear()
+	if err != nil {
+		t.Error(err)
+	}
+
+	keys, _ := c.Keys("")
+	if len(keys) != 0 {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+}
@@ -0,0 +1,136 @@
+package lock
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/JohannesKaufmann/dynamodb-cache/dynadapter"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// KeyPrefix is put in front of the name of the lock to get the key
+// of the item in the table. It starts with dynadapter.ReservedPrefix,
+// so that the locks are not deleted by Clear or DelPrefix of the cache.
+const KeyPrefix = dynadapter.ReservedPrefix + "lock:"
+
+// DynamoStore keeps the locks in the same DynamoDB table that
+// is used by dynadapter (hash key `Key`, TTL attribute `TTL`).
+// Every lock is one item that also contains the `Owner`.
+//
+// The lease expires at `Expires`, which is saved in milliseconds.
+// The TTL is only used by DynamoDB to delete old locks.
+type DynamoStore struct {
+	client dynamodbiface.DynamoDBAPI
+	table  string
+}
+
+// NewDynamoStore returns a store that uses the table.
+func NewDynamoStore(client dynamodbiface.DynamoDBAPI, table string) *DynamoStore {
+	return &DynamoStore{client: client, table: table}
+}
+
+func (s *DynamoStore) key(name string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Key": {S: aws.String(KeyPrefix + name)},
+	}
+}
+
+func unix(t time.Time) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(t.Unix(), 10))}
+}
+
+// ttl rounds up to the next second, so that
+// DynamoDB never deletes a lock that is still held.
+func ttl(t time.Time) *dynamodb.AttributeValue {
+	return unix(t.Add(time.Second - 1))
+}
+
+func millis(t time.Time) *dynamodb.AttributeValue {
+	ms := t.UnixNano() / int64(time.Millisecond)
+	return &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(ms, 10))}
+}
+
+func isConditionalCheckFailed(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
+
+// names are the expression attribute names used by
+// Renew and Release, Renew also sets the TTL.
+func names() map[string]*string {
+	return map[string]*string{
+		"#k": aws.String("Key"),
+		"#e": aws.String("Expires"),
+		"#o": aws.String("Owner"),
+	}
+}
+
+func (s *DynamoStore) Acquire(name, owner string, expire time.Time) error {
+	item := s.key(name)
+	item["TTL"] = ttl(expire)
+	item["Expires"] = millis(expire)
+	item["Owner"] = &dynamodb.AttributeValue{S: aws.String(owner)}
+
+	input := &dynamodb.PutItemInput{
+		TableName:                &s.table,
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#k) OR #e < :now"),
+		ExpressionAttributeNames: map[string]*string{"#k": aws.String("Key"), "#e": aws.String("Expires")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": millis(time.Now()),
+		},
+	}
+
+	_, err := s.client.PutItem(input)
+	if isConditionalCheckFailed(err) {
+		return ErrLocked
+	}
+	return err
+}
+
+func (s *DynamoStore) Renew(name, owner string, expire time.Time) error {
+	attributeNames := names()
+	attributeNames["#t"] = aws.String("TTL")
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                &s.table,
+		Key:                      s.key(name),
+		UpdateExpression:         aws.String("SET #t = :ttl, #e = :expire"),
+		ConditionExpression:      aws.String("attribute_exists(#k) AND #o = :owner AND #e >= :now"),
+		ExpressionAttributeNames: attributeNames,
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":ttl":    ttl(expire),
+			":expire": millis(expire),
+			":owner":  {S: aws.String(owner)},
+			":now":    millis(time.Now()),
+		},
+	}
+
+	_, err := s.client.UpdateItem(input)
+	if isConditionalCheckFailed(err) {
+		return ErrNotOwner
+	}
+	return err
+}
+
+func (s *DynamoStore) Release(name, owner string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName:                &s.table,
+		Key:                      s.key(name),
+		ConditionExpression:      aws.String("attribute_exists(#k) AND #o = :owner AND #e >= :now"),
+		ExpressionAttributeNames: names(),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(owner)},
+			":now":   millis(time.Now()),
+		},
+	}
+
+	_, err := s.client.DeleteItem(input)
+	if isConditionalCheckFailed(err) {
+		return ErrNotOwner
+	}
+	return err
+}
@@ -0,0 +1,152 @@
+package lock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/JohannesKaufmann/dynamodb-cache/dynadapter"
+	"github.com/JohannesKaufmann/dynamodb-cache/dynadapter/dynafake"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+type mockDynamoDBClient struct {
+	dynamodbiface.DynamoDBAPI
+
+	PutItemFunc    func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	UpdateItemFunc func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	DeleteItemFunc func(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+}
+
+func (m *mockDynamoDBClient) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	return m.PutItemFunc(input)
+}
+func (m *mockDynamoDBClient) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	return m.UpdateItemFunc(input)
+}
+func (m *mockDynamoDBClient) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	return m.DeleteItemFunc(input)
+}
+
+var errConditionalCheckFailed = awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "failed", nil)
+
+func TestDynamoStore(t *testing.T) {
+	var owner string
+	mockSvc := &mockDynamoDBClient{
+		PutItemFunc: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			if *input.Item["Key"].S != "__lock:job" {
+				t.Error("wrong key")
+			}
+			if *input.ConditionExpression != "attribute_not_exists(#k) OR #e < :now" {
+				t.Error("wrong condition expression")
+			}
+			owner = *input.Item["Owner"].S
+			return nil, nil
+		},
+		UpdateItemFunc: func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			if *input.ExpressionAttributeValues[":owner"].S != owner {
+				t.Error("renew with different owner")
+			}
+			return nil, nil
+		},
+		DeleteItemFunc: func(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+			if *input.ExpressionAttributeValues[":owner"].S != owner {
+				t.Error("release with different owner")
+			}
+			return nil, nil
+		},
+	}
+
+	l := New(NewDynamoStore(mockSvc, "TestCache"))
+	lease, err := l.Acquire("job", time.Minute)
+	if err != nil {
+		t.Error(err)
+	}
+	if owner == "" {
+		t.Error("expected an owner token")
+	}
+	err = lease.Renew(time.Minute)
+	if err != nil {
+		t.Error(err)
+	}
+	err = lease.Release()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDynamoStore_ConditionFailed(t *testing.T) {
+	mockSvc := &mockDynamoDBClient{
+		PutItemFunc: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			return nil, errConditionalCheckFailed
+		},
+		UpdateItemFunc: func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			return nil, errConditionalCheckFailed
+		},
+		DeleteItemFunc: func(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+			return nil, errConditionalCheckFailed
+		},
+	}
+	s := NewDynamoStore(mockSvc, "TestCache")
+
+	err := s.Acquire("job", "a", time.Now())
+	if err != ErrLocked {
+		t.Error(err)
+	}
+	err = s.Renew("job", "a", time.Now())
+	if err != ErrNotOwner {
+		t.Error(err)
+	}
+	err = s.Release("job", "a")
+	if err != ErrNotOwner {
+		t.Error(err)
+	}
+}
+
+func TestDynamoStore_Cache(t *testing.T) {
+	db := dynafake.New()
+	db.AddTable("TestCache", "Key")
+	a, err := dynadapter.New(db, "TestCache", time.Hour)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewDynamoStore(db, "TestCache")
+
+	err = s.Acquire("job", "a", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.(cache.PrefixDeleter).DelPrefix(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.(cache.Clearer).Clear(); err != nil {
+		t.Fatal(err)
+	}
+	err = s.Acquire("job", "b", time.Now().Add(time.Hour))
+	if err != ErrLocked {
+		t.Errorf("expected the lock to survive the cache but got %v", err)
+	}
+}
+
+func TestDynamoStore_Milliseconds(t *testing.T) {
+	db := dynafake.New()
+	db.AddTable("TestCache", "Key")
+	s := NewDynamoStore(db, "TestCache")
+
+	err := s.Acquire("job", "a", time.Now().Add(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Acquire("job", "b", time.Now().Add(time.Second))
+	if err != ErrLocked {
+		t.Errorf("expected ErrLocked but got %v", err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	err = s.Acquire("job", "b", time.Now().Add(time.Second))
+	if err != nil {
+		t.Errorf("expected the lease to be expired but got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+// Package lock provides a simple distributed mutex that is based on
+// leases. A lease has to be renewed before it runs out, otherwise
+// the lock is free again and can be acquired by someone else.
+//
+// The DynamoDB store uses the same table as the cache, so no
+// additional infrastructure is needed.
+package lock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// common errors
+var (
+	ErrLocked   = errors.New("lock is held by another owner")
+	ErrNotOwner = errors.New("lock is not held by this owner")
+)
+
+// Store saves who holds which lock. Every method has to be atomic.
+type Store interface {
+	// Acquire takes the lock if it is free or if the lease of
+	// the previous owner expired. Otherwise ErrLocked is returned.
+	Acquire(name, owner string, expire time.Time) error
+
+	// Renew moves the expiry of the lease. If the lock is not
+	// held by owner (anymore) ErrNotOwner is returned.
+	Renew(name, owner string, expire time.Time) error
+
+	// Release frees the lock. If the lock is not held by
+	// owner (anymore) ErrNotOwner is returned.
+	Release(name, owner string) error
+}
+
+// Locker hands out leases for named locks.
+type Locker struct {
+	store Store
+}
+
+// New returns a Locker that saves the locks in store.
+func New(store Store) *Locker {
+	return &Locker{store: store}
+}
+
+// Lease is a lock that is held until it is released or it expires.
+type Lease struct {
+	store  Store
+	name   string
+	owner  string
+	expire time.Time
+}
+
+func newOwner() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Acquire tries to take the lock with that name for the duration
+// of lease. It does not wait: if the lock is taken ErrLocked is returned.
+func (l *Locker) Acquire(name string, lease time.Duration) (*Lease, error) {
+	owner, err := newOwner()
+	if err != nil {
+		return nil, err
+	}
+
+	expire := time.Now().Add(lease)
+	err = l.store.Acquire(name, owner, expire)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Lease{
+		store:  l.store,
+		name:   name,
+		owner:  owner,
+		expire: expire,
+	}, nil
+}
+
+// Name returns the name of the lock.
+func (l *Lease) Name() string {
+	return l.name
+}
+
+// Expires returns the time the lease runs out if it is not renewed.
+func (l *Lease) Expires() time.Time {
+	return l.expire
+}
+
+// Renew extends the lease so that it runs out lease from now.
+func (l *Lease) Renew(lease time.Duration) error {
+	expire := time.Now().Add(lease)
+	err := l.store.Renew(l.name, l.owner, expire)
+	if err != nil {
+		return err
+	}
+
+	l.expire = expire
+	return nil
+}
+
+// Release frees the lock so that it can be acquired by others.
+func (l *Lease) Release() error {
+	return l.store.Release(l.name, l.owner)
+}
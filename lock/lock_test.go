@@ -0,0 +1,98 @@
+package lock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAcquire_Locked(t *testing.T) {
+	l := New(NewMemoryStore())
+
+	lease, err := l.Acquire("job", time.Minute)
+	if err != nil {
+		t.Error(err)
+	}
+	if lease.Name() != "job" {
+		t.Error("wrong name")
+	}
+
+	_, err = l.Acquire("job", time.Minute)
+	if err != ErrLocked {
+		t.Error(err)
+	}
+
+	_, err = l.Acquire("other", time.Minute)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAcquire_Expired(t *testing.T) {
+	l := New(NewMemoryStore())
+
+	old, err := l.Acquire("job", time.Millisecond)
+	if err != nil {
+		t.Error(err)
+	}
+	time.Sleep(time.Millisecond * 5)
+
+	_, err = l.Acquire("job", time.Minute)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = old.Renew(time.Minute)
+	if err != ErrNotOwner {
+		t.Error(err)
+	}
+	err = old.Release()
+	if err != ErrNotOwner {
+		t.Error(err)
+	}
+}
+
+func TestRenew(t *testing.T) {
+	l := New(NewMemoryStore())
+
+	lease, err := l.Acquire("job", time.Millisecond*20)
+	if err != nil {
+		t.Error(err)
+	}
+	before := lease.Expires()
+
+	err = lease.Renew(time.Minute)
+	if err != nil {
+		t.Error(err)
+	}
+	if !lease.Expires().After(before) {
+		t.Error("expire did not change")
+	}
+
+	time.Sleep(time.Millisecond * 30)
+	_, err = l.Acquire("job", time.Minute)
+	if err != ErrLocked {
+		t.Error(err)
+	}
+}
+
+func TestRelease(t *testing.T) {
+	l := New(NewMemoryStore())
+
+	lease, err := l.Acquire("job", time.Minute)
+	if err != nil {
+		t.Error(err)
+	}
+	err = lease.Release()
+	if err != nil {
+		t.Error(err)
+	}
+	err = lease.Release()
+	if err != ErrNotOwner {
+		t.Error(err)
+	}
+
+	_, err = l.Acquire("job", time.Minute)
+	if err != nil {
+		t.Error(err)
+	}
+}
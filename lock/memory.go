@@ -0,0 +1,68 @@
+package lock
+
+import (
+	"sync"
+	"time"
+)
+
+type entry struct {
+	owner  string
+	expire time.Time
+}
+
+// MemoryStore keeps the locks in a map. It only works inside one
+// process and is mostly useful for tests.
+type MemoryStore struct {
+	locks map[string]entry
+	m     sync.Mutex
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		locks: make(map[string]entry),
+	}
+}
+
+// held returns whether the lock is held by owner. The caller
+// needs to hold the mutex.
+func (s *MemoryStore) held(name, owner string, now time.Time) bool {
+	e, ok := s.locks[name]
+	return ok && e.owner == owner && !now.After(e.expire)
+}
+
+func (s *MemoryStore) Acquire(name, owner string, expire time.Time) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if e, ok := s.locks[name]; ok && !time.Now().After(e.expire) {
+		return ErrLocked
+	}
+
+	s.locks[name] = entry{owner: owner, expire: expire}
+	return nil
+}
+
+func (s *MemoryStore) Renew(name, owner string, expire time.Time) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.held(name, owner, time.Now()) {
+		return ErrNotOwner
+	}
+
+	s.locks[name] = entry{owner: owner, expire: expire}
+	return nil
+}
+
+func (s *MemoryStore) Release(name, owner string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.held(name, owner, time.Now()) {
+		return ErrNotOwner
+	}
+
+	delete(s.locks, name)
+	return nil
+}
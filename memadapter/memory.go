@@ -1,7 +1,10 @@
 package memadapter
 
 import (
-	"fmt"
+	"bytes"
+	"container/heap"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -9,8 +12,9 @@ import (
 )
 
 type item struct {
-	value  []byte
-	expire time.Time
+	value   []byte
+	expire  time.Time
+	created time.Time
 }
 
 func (i item) isExpired(now time.Time) bool {
@@ -23,6 +27,36 @@ type Adapter struct {
 
 	ttl         time.Duration
 	renewOnRead bool
+
+	snapshotPath     string
+	snapshotInterval time.Duration
+
+	stop      chan struct{}
+	closeOnce sync.Once
+
+	clock   func() time.Time
+	onError func(err error)
+}
+
+// Option changes the behaviour of the adapter.
+type Option func(*Adapter)
+
+// WithClock replaces time.Now, for example with a fake clock
+// in tests. There is no background cleanup with a clock,
+// because it would run by the real time. Call Cleanup
+// instead to delete the expired items.
+func WithClock(now func() time.Time) Option {
+	return func(a *Adapter) {
+		a.clock = now
+	}
+}
+
+// now returns the time of the clock.
+func (a *Adapter) now() time.Time {
+	if a.clock == nil {
+		return time.Now()
+	}
+	return a.clock()
 }
 
 // -> https://stackoverflow.com/a/25487392
@@ -30,25 +64,36 @@ type Adapter struct {
 const NoExpiration time.Duration = -1
 const CleanupInterval = time.Second * 2
 
-func New(ttl time.Duration, renewOnRead bool) cache.InitAdapter {
+func New(ttl time.Duration, renewOnRead bool, opts ...Option) cache.InitAdapter {
 	return func() (cache.Adapter, error) {
 		i := &Adapter{
 			values:      make(map[string]*item),
 			ttl:         ttl,
 			renewOnRead: renewOnRead,
+			stop:        make(chan struct{}),
+		}
+		for _, opt := range opts {
+			opt(i)
+		}
+
+		if i.snapshotPath != "" {
+			i.restoreSnapshot()
+			if i.snapshotInterval > 0 {
+				go i.writeSnapshots()
+			}
 		}
 
-		if ttl != NoExpiration {
+		if ttl != NoExpiration && i.clock == nil {
 			go func() {
 				ticker := time.NewTicker(CleanupInterval)
 
 				for {
 					select {
-					case time := <-ticker.C:
-						i.deleteExpired(time)
-						// case <-i.stop:
-						// 	ticker.Stop()
-						// 	return
+					case <-ticker.C:
+						i.deleteExpired(i.now())
+					case <-i.stop:
+						ticker.Stop()
+						return
 					}
 				}
 			}()
@@ -58,56 +103,351 @@ func New(ttl time.Duration, renewOnRead bool) cache.InitAdapter {
 	}
 }
 
+// Close stops the background cleanup and writes
+// the snapshot if WithSnapshot is used.
+func (a *Adapter) Close() error {
+	var err error
+	a.closeOnce.Do(func() {
+		if a.stop != nil {
+			close(a.stop)
+		}
+		if a.snapshotPath != "" {
+			err = a.writeSnapshot()
+		}
+	})
+	return err
+}
+
 // func NewWithRenew(ttl time.Duration)cache.InitAdapter {
 // 	return func() (cache.Adapter, error) {
 // 		return nil,nil
 // 	}
 // }
 
-func (a Adapter) deleteExpired(now time.Time) {
+// Cleanup deletes the expired items, which is otherwise
+// done every CleanupInterval in the background.
+func (a *Adapter) Cleanup() {
+	a.deleteExpired(a.now())
+}
+
+func (a *Adapter) deleteExpired(now time.Time) {
 	a.m.Lock()
 	for key, v := range a.values {
 		if v.isExpired(now) {
-			// if now.After(v.expire) {
-			fmt.Println("cleanup: DELETE", key)
 			delete(a.values, key)
-		} else {
-			// fmt.Println("cleanup: DONT DELETE")
 		}
 	}
 	a.m.Unlock()
 }
 
-func (a Adapter) Get(key string) ([]byte, error) {
-	a.m.RLock()
-	defer a.m.RUnlock()
+// newItem returns an item that expires after the ttl of the adapter.
+func (a *Adapter) newItem(data []byte, now time.Time) *item {
+	return &item{
+		value:   data,
+		expire:  now.Add(a.ttl),
+		created: now,
+	}
+}
+
+// Name returns the name of the adapter.
+func (a *Adapter) Name() string {
+	return "memory"
+}
+
+func (a *Adapter) Get(key string) ([]byte, error) {
+	it, err := a.get(key)
+	if err != nil {
+		return nil, err
+	}
+	return it.value, nil
+}
+
+// GetWithMetadata also returns when the item expires and when it was set.
+func (a *Adapter) GetWithMetadata(key string) ([]byte, cache.Metadata, error) {
+	it, err := a.get(key)
+	if err != nil {
+		return nil, cache.Metadata{}, err
+	}
+
+	meta := cache.Metadata{Created: it.created}
+	if a.ttl != NoExpiration {
+		meta.Expires = it.expire
+	}
+	return it.value, meta, nil
+}
+
+// get returns a copy of the item.
+func (a *Adapter) get(key string) (item, error) {
+	// renewing changes the item, so it needs the write lock
+	if a.renewOnRead {
+		a.m.Lock()
+		defer a.m.Unlock()
+	} else {
+		a.m.RLock()
+		defer a.m.RUnlock()
+	}
 
 	if it, ok := a.values[key]; ok {
-		if a.ttl != NoExpiration && it.isExpired(time.Now()) {
-			return nil, cache.ErrExpired
+		if a.ttl != NoExpiration && it.isExpired(a.now()) {
+			return item{}, cache.ErrExpired
 		}
 		if a.renewOnRead {
-			it.expire = time.Now().Add(a.ttl)
+			it.expire = a.now().Add(a.ttl)
 		}
 
-		return it.value, nil
+		return *it, nil
 	}
 
-	return nil, cache.ErrNotFound
+	return item{}, cache.ErrNotFound
 }
 
-func (a Adapter) Set(key string, data []byte) error {
+func (a *Adapter) Set(key string, data []byte) error {
 	a.m.Lock()
 	defer a.m.Unlock()
 
-	a.values[key] = &item{
-		value:  data,
-		expire: time.Now().Add(a.ttl),
+	a.values[key] = a.newItem(data, a.now())
+
+	return nil
+}
+
+// SetWithTTL sets the data with a different ttl than the one of the adapter.
+func (a *Adapter) SetWithTTL(key string, data []byte, ttl time.Duration) error {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	now := a.now()
+	it := a.newItem(data, now)
+	it.expire = now.Add(ttl)
+	a.values[key] = it
+
+	return nil
+}
+
+// GetMulti returns the items that exist and are not expired.
+func (a *Adapter) GetMulti(keys []string) (map[string][]byte, error) {
+	if a.renewOnRead {
+		a.m.Lock()
+		defer a.m.Unlock()
+	} else {
+		a.m.RLock()
+		defer a.m.RUnlock()
+	}
+
+	now := a.now()
+	result := make(map[string][]byte, len(keys))
+
+	for _, key := range keys {
+		it, ok := a.values[key]
+		if !ok || (a.ttl != NoExpiration && it.isExpired(now)) {
+			continue
+		}
+		if a.renewOnRead {
+			it.expire = now.Add(a.ttl)
+		}
+		result[key] = it.value
+	}
+
+	return result, nil
+}
+
+// SetMulti sets every item under the same lock.
+func (a *Adapter) SetMulti(items map[string][]byte) error {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	now := a.now()
+	for key, data := range items {
+		a.values[key] = a.newItem(data, now)
 	}
 
 	return nil
 }
-func (a Adapter) Del(key string) error {
+
+// Incr adds delta to the counter under the lock. A new counter gets
+// the ttl of the adapter, an existing one keeps its expiry.
+func (a *Adapter) Incr(key string, delta int64) (int64, error) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	now := a.now()
+	it, ok := a.values[key]
+	if !ok || (a.ttl != NoExpiration && it.isExpired(now)) {
+		it = a.newItem(nil, now)
+		a.values[key] = it
+	}
+
+	var n int64
+	if len(it.value) > 0 {
+		var err error
+		n, err = cache.DecodeCounter(it.value)
+		if err != nil {
+			return 0, err
+		}
+	}
+	n += delta
+	it.value = cache.EncodeCounter(n)
+
+	return n, nil
+}
+
+// Dump calls fn with every item that is not expired. The items
+// are copied first, so that fn is called without holding the lock.
+func (a *Adapter) Dump(fn func(rec cache.Record) error) error {
+	a.m.RLock()
+	now := a.now()
+	var records []cache.Record
+	for key, it := range a.values {
+		rec := cache.Record{Key: key, Data: it.value}
+		if a.ttl != NoExpiration {
+			if it.isExpired(now) {
+				continue
+			}
+			rec.Expires = it.expire
+		}
+		records = append(records, rec)
+	}
+	a.m.RUnlock()
+
+	for _, rec := range records {
+		err := fn(rec)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Touch sets the expiry of the item to ttl from now.
+func (a *Adapter) Touch(key string, ttl time.Duration) error {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	now := a.now()
+	it, ok := a.values[key]
+	if !ok || (a.ttl != NoExpiration && it.isExpired(now)) {
+		return cache.ErrNotFound
+	}
+	it.expire = now.Add(ttl)
+
+	return nil
+}
+
+// Add sets the data only if the key does not exist or is expired.
+// The check and the write happen under the same lock.
+func (a *Adapter) Add(key string, data []byte) error {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	now := a.now()
+	if it, ok := a.values[key]; ok {
+		if a.ttl == NoExpiration || !it.isExpired(now) {
+			return cache.ErrAlreadyExists
+		}
+	}
+
+	a.values[key] = a.newItem(data, now)
+
+	return nil
+}
+
+// CompareAndSwap replaces the data only if the item still
+// has the old data. The check and the write happen under the same lock.
+func (a *Adapter) CompareAndSwap(key string, old, new []byte) error {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	now := a.now()
+	it, ok := a.values[key]
+	if !ok || (a.ttl != NoExpiration && it.isExpired(now)) || !bytes.Equal(it.value, old) {
+		return cache.ErrConflict
+	}
+
+	a.values[key] = a.newItem(new, now)
+
+	return nil
+}
+
+// page holds the smallest keys of a Scan in a max-heap, so that
+// only the keys of the page need to be sorted.
+type page []string
+
+func (p page) Len() int            { return len(p) }
+func (p page) Less(i, j int) bool  { return p[i] > p[j] }
+func (p page) Swap(i, j int)       { p[i], p[j] = p[j], p[i] }
+func (p *page) Push(x interface{}) { *p = append(*p, x.(string)) }
+func (p *page) Pop() interface{} {
+	old := *p
+	key := old[len(old)-1]
+	*p = old[:len(old)-1]
+	return key
+}
+
+// Scan returns the keys in lexical order, so that the last
+// key of a page can be used as the cursor.
+func (a *Adapter) Scan(opts cache.ScanOptions) ([]string, string, error) {
+	a.m.RLock()
+	now := a.now()
+	var keys page
+	var more bool
+	for key, it := range a.values {
+		if !strings.HasPrefix(key, opts.Prefix) || (opts.Cursor != "" && key <= opts.Cursor) {
+			continue
+		}
+		if !opts.IncludeExpired && a.ttl != NoExpiration && it.isExpired(now) {
+			continue
+		}
+
+		if opts.Limit <= 0 {
+			keys = append(keys, key)
+			continue
+		}
+		if len(keys) < opts.Limit {
+			heap.Push(&keys, key)
+			continue
+		}
+		more = true
+		if key < keys[0] {
+			keys[0] = key
+			heap.Fix(&keys, 0)
+		}
+	}
+	a.m.RUnlock()
+
+	sort.Strings(keys)
+	if !more {
+		return keys, "", nil
+	}
+	return keys, keys[len(keys)-1], nil
+}
+
+// DelPrefix deletes every item whose key starts with prefix.
+func (a *Adapter) DelPrefix(prefix string) (int, error) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	var count int
+	for key := range a.values {
+		if strings.HasPrefix(key, prefix) {
+			delete(a.values, key)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// Clear removes every item by replacing the map.
+func (a *Adapter) Clear() error {
+	values := make(map[string]*item)
+
+	a.m.Lock()
+	a.values = values
+	a.m.Unlock()
+
+	return nil
+}
+
+func (a *Adapter) Del(key string) error {
 	a.m.Lock()
 	defer a.m.Unlock()
 
@@ -115,3 +455,15 @@ func (a Adapter) Del(key string) error {
 
 	return nil
 }
+
+// DelMulti deletes every key under the same lock.
+func (a *Adapter) DelMulti(keys []string) error {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	for _, key := range keys {
+		delete(a.values, key)
+	}
+
+	return nil
+}
@@ -2,10 +2,12 @@ package memadapter
 
 import (
 	"bytes"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/JohannesKaufmann/dynamodb-cache/cachetest"
 )
 
 func TestNew(t *testing.T) {
@@ -88,6 +90,31 @@ func TestGet_NotFound(t *testing.T) {
 	}
 }
 
+func TestGetWithMetadata(t *testing.T) {
+	expire := time.Now().Add(time.Second)
+	created := time.Now()
+	c := Adapter{
+		ttl: time.Second,
+		values: map[string]*item{
+			"1": {
+				value:   []byte("data"),
+				expire:  expire,
+				created: created,
+			},
+		},
+	}
+	data, meta, err := c.GetWithMetadata("1")
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(data, []byte("data")) {
+		t.Fail()
+	}
+	if meta.Expires != expire || meta.Created != created {
+		t.Error("wrong metadata")
+	}
+}
+
 func TestGet_DontRenew(t *testing.T) {
 	old := time.Now().Add(time.Second * 2)
 	c := Adapter{
@@ -127,6 +154,37 @@ func TestGet_Renew(t *testing.T) {
 	}
 }
 
+func TestTouch(t *testing.T) {
+	old := time.Now().Add(time.Second)
+	c := Adapter{
+		ttl: time.Second,
+		values: map[string]*item{
+			"1": {
+				expire: old,
+			},
+			"2": {
+				expire: time.Now().Add(-time.Second),
+			},
+		},
+	}
+	err := c.Touch("1", time.Hour)
+	if err != nil {
+		t.Error(err)
+	}
+	if !c.values["1"].expire.After(old) {
+		t.Error("expire did not change")
+	}
+
+	err = c.Touch("2", time.Hour)
+	if err != cache.ErrNotFound {
+		t.Error(err)
+	}
+	err = c.Touch("3", time.Hour)
+	if err != cache.ErrNotFound {
+		t.Error(err)
+	}
+}
+
 func TestSet(t *testing.T) {
 	c := Adapter{
 		values: make(map[string]*item),
@@ -145,6 +203,197 @@ func TestSet(t *testing.T) {
 	}
 }
 
+func TestAdd(t *testing.T) {
+	c := Adapter{
+		ttl: time.Second,
+		values: map[string]*item{
+			"1": {
+				value:  []byte("data"),
+				expire: time.Now().Add(time.Second),
+			},
+			"2": {
+				value:  []byte("data"),
+				expire: time.Now().Add(-time.Second),
+			},
+		},
+	}
+	err := c.Add("1", []byte("One"))
+	if err != cache.ErrAlreadyExists {
+		t.Error(err)
+	}
+	if !bytes.Equal(c.values["1"].value, []byte("data")) {
+		t.Error("existing item was overwritten")
+	}
+
+	err = c.Add("2", []byte("Two"))
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(c.values["2"].value, []byte("Two")) {
+		t.Error("expired item was not replaced")
+	}
+
+	err = c.Add("3", []byte("Three"))
+	if err != nil {
+		t.Error(err)
+	}
+	if len(c.values) != 3 {
+		t.Error("expected length of 3")
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	c := Adapter{
+		ttl: time.Second,
+		values: map[string]*item{
+			"1": {
+				value:  []byte("data"),
+				expire: time.Now().Add(time.Second),
+			},
+		},
+	}
+	err := c.CompareAndSwap("1", []byte("other"), []byte("One"))
+	if err != cache.ErrConflict {
+		t.Error(err)
+	}
+	err = c.CompareAndSwap("2", nil, []byte("Two"))
+	if err != cache.ErrConflict {
+		t.Error(err)
+	}
+
+	err = c.CompareAndSwap("1", []byte("data"), []byte("One"))
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(c.values["1"].value, []byte("One")) {
+		t.Error("item was not replaced")
+	}
+}
+
+func TestScan(t *testing.T) {
+	c := Adapter{
+		ttl: time.Second,
+		values: map[string]*item{
+			"a:1": {expire: time.Now().Add(time.Second)},
+			"a:2": {expire: time.Now().Add(time.Second)},
+			"a:3": {expire: time.Now().Add(-time.Second)},
+			"b:1": {expire: time.Now().Add(time.Second)},
+		},
+	}
+	keys, next, err := c.Scan(cache.ScanOptions{Prefix: "a:", Limit: 1})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(keys) != 1 || keys[0] != "a:1" || next != "a:1" {
+		t.Errorf("unexpected first page: %v %s", keys, next)
+	}
+
+	keys, next, err = c.Scan(cache.ScanOptions{Prefix: "a:", Cursor: next, Limit: 1})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(keys) != 1 || keys[0] != "a:2" || next != "" {
+		t.Errorf("unexpected second page: %v %s", keys, next)
+	}
+
+	keys, _, _ = c.Scan(cache.ScanOptions{Prefix: "a:", IncludeExpired: true})
+	if len(keys) != 3 {
+		t.Error("expected expired key to be included")
+	}
+}
+
+func TestScan_Pages(t *testing.T) {
+	c := Adapter{ttl: NoExpiration, values: make(map[string]*item)}
+	for i := 0; i < 100; i++ {
+		c.values[fmt.Sprintf("key:%03d", i)] = &item{}
+	}
+
+	var all []string
+	opts := cache.ScanOptions{Prefix: "key:", Limit: 7}
+	for {
+		keys, next, err := c.Scan(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) > 7 {
+			t.Fatalf("expected at most 7 keys but got %d", len(keys))
+		}
+		all = append(all, keys...)
+		if next == "" {
+			break
+		}
+		opts.Cursor = next
+	}
+
+	if len(all) != 100 {
+		t.Fatalf("expected 100 keys but got %d", len(all))
+	}
+	for i, key := range all {
+		if key != fmt.Sprintf("key:%03d", i) {
+			t.Fatalf("expected the keys in order but got %s at %d", key, i)
+		}
+	}
+}
+
+func TestDelPrefix(t *testing.T) {
+	c := Adapter{
+		values: map[string]*item{
+			"a:1": {},
+			"a:2": {},
+			"b:1": {},
+		},
+	}
+	count, err := c.DelPrefix("a:")
+	if err != nil {
+		t.Error(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 deleted items but got %d", count)
+	}
+	if len(c.values) != 1 || c.values["b:1"] == nil {
+		t.Error("deleted the wrong items")
+	}
+}
+
+func TestClear(t *testing.T) {
+	c := Adapter{
+		values: map[string]*item{
+			"1": {},
+			"2": {},
+		},
+	}
+	err := c.Clear()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(c.values) != 0 {
+		t.Fail()
+	}
+}
+
+func TestDump(t *testing.T) {
+	expire := time.Now().Add(time.Second)
+	c := Adapter{
+		ttl: time.Second,
+		values: map[string]*item{
+			"1": {value: []byte("One"), expire: expire},
+			"2": {value: []byte("Two"), expire: time.Now().Add(-time.Second)},
+		},
+	}
+
+	var records []cache.Record
+	err := c.Dump(func(rec cache.Record) error {
+		records = append(records, rec)
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(records) != 1 || records[0].Key != "1" || records[0].Expires != expire {
+		t.Errorf("unexpected records: %+v", records)
+	}
+}
+
 func TestDel(t *testing.T) {
 	c := Adapter{
 		values: map[string]*item{
@@ -184,3 +433,103 @@ func TestExpire(t *testing.T) {
 		t.Error("item deleted to soon")
 	}
 }
+
+func TestCleanup_Clock(t *testing.T) {
+	clock := cachetest.NewClock()
+	a, err := New(time.Second, false, WithClock(clock.Now))()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.(*Adapter).Close()
+
+	a.Set("key", []byte("value"))
+	clock.Advance(time.Minute)
+
+	// no cleanup by the real time
+	time.Sleep(CleanupInterval + time.Millisecond*100)
+	if len(a.(*Adapter).values) != 1 {
+		t.Error("expected no background cleanup with a clock")
+	}
+
+	a.(*Adapter).Cleanup()
+	if len(a.(*Adapter).values) != 0 {
+		t.Error("expected the expired item to be deleted")
+	}
+}
+
+func TestMulti(t *testing.T) {
+	c := Adapter{
+		ttl: time.Second,
+		values: map[string]*item{
+			"expired": {
+				value:  []byte("data"),
+				expire: time.Now().Add(-time.Second),
+			},
+		},
+	}
+
+	err := c.SetMulti(map[string][]byte{"1": []byte("One"), "2": []byte("Two")})
+	if err != nil {
+		t.Error(err)
+	}
+
+	result, err := c.GetMulti([]string{"1", "2", "3", "expired"})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(result) != 2 || !bytes.Equal(result["1"], []byte("One")) {
+		t.Errorf("got wrong result %q", result)
+	}
+
+	err = c.DelMulti([]string{"1", "2"})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(c.values) != 1 {
+		t.Error("expected length of 1")
+	}
+}
+
+func TestIncr(t *testing.T) {
+	expire := time.Now().Add(time.Minute)
+	c := Adapter{
+		ttl: time.Second,
+		values: map[string]*item{
+			"counter": {
+				value:  cache.EncodeCounter(41),
+				expire: expire,
+			},
+			"text": {
+				value:  []byte("data"),
+				expire: expire,
+			},
+		},
+	}
+
+	n, err := c.Incr("counter", 1)
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 42 || !c.values["counter"].expire.Equal(expire) {
+		t.Errorf("expected 42 with the same expiry but got %d", n)
+	}
+
+	n, err = c.Incr("new", -1)
+	if err != nil {
+		t.Error(err)
+	}
+	if n != -1 || !bytes.Equal(c.values["new"].value, cache.EncodeCounter(-1)) {
+		t.Errorf("expected -1 but got %d", n)
+	}
+
+	_, err = c.Incr("text", 1)
+	if err != cache.ErrNotCounter {
+		t.Errorf("expected ErrNotCounter but got %v", err)
+	}
+}
+
+func TestAdapterSuite(t *testing.T) {
+	cachetest.RunAdapterSuite(t, func(ttl time.Duration, now func() time.Time) cache.InitAdapter {
+		return New(ttl, false, WithClock(now))
+	})
+}
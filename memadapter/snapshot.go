@@ -0,0 +1,156 @@
+package memadapter
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// WithSnapshot keeps the items across restarts: they are loaded
+// from the file in New and written to it every interval (zero
+// disables it) and on Close. A snapshot that can't be loaded is
+// passed to WithErrorHandler and removed, and the adapter starts
+// empty.
+func WithSnapshot(path string, interval time.Duration) Option {
+	return func(a *Adapter) {
+		a.snapshotPath = path
+		a.snapshotInterval = interval
+	}
+}
+
+// WithErrorHandler is called with the errors of the background
+// work, for example a snapshot that could not be written. Without
+// it these errors are ignored.
+func WithErrorHandler(fn func(err error)) Option {
+	return func(a *Adapter) {
+		a.onError = fn
+	}
+}
+
+func (a *Adapter) writeSnapshots() {
+	ticker := time.NewTicker(a.snapshotInterval)
+
+	for {
+		select {
+		case <-ticker.C:
+			err := a.writeSnapshot()
+			if err != nil && a.onError != nil {
+				a.onError(err)
+			}
+		case <-a.stop:
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+// writeSnapshot writes every item to a temporary file and renames
+// it afterwards, so that the snapshot is never half written.
+func (a *Adapter) writeSnapshot() error {
+	dir, base := filepath.Dir(a.snapshotPath), filepath.Base(a.snapshotPath)
+	tmp, err := ioutil.TempFile(dir, base+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	sw, err := cache.NewSnapshotWriter(tmp, a.now())
+	if err != nil {
+		return err
+	}
+	err = a.Dump(sw.Write)
+	if err != nil {
+		return err
+	}
+	err = sw.Flush()
+	if err != nil {
+		return err
+	}
+	err = tmp.Sync()
+	if err != nil {
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), a.snapshotPath)
+}
+
+// errSnapshotFuture is returned if the snapshot seems to be written
+// in the future, because the clock went backwards since then.
+var errSnapshotFuture = errors.New("memadapter: snapshot was written in the future")
+
+// restoreSnapshot loads the snapshot. A snapshot that can't be
+// loaded must not stop the start, so the error is passed to the
+// error handler, the file is removed and the adapter starts empty.
+func (a *Adapter) restoreSnapshot() {
+	err := a.loadSnapshot()
+	if err == nil {
+		return
+	}
+
+	a.values = make(map[string]*item)
+	os.Remove(a.snapshotPath)
+	if a.onError != nil {
+		a.onError(err)
+	}
+}
+
+// loadSnapshot reads the items from the snapshot file. It is
+// not an error if the file does not exist (yet).
+//
+// The snapshot has the ttl that each item had left when it was
+// written, measured with the monotonic clock. The time since then
+// spans a restart, so it can only be measured with the wall clock
+// and is subtracted from the ttl. If the clock went backwards, so
+// that the snapshot seems to be written in the future, that time
+// is unknown and errSnapshotFuture is returned.
+func (a *Adapter) loadSnapshot() error {
+	f, err := os.Open(a.snapshotPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sr, err := cache.NewSnapshotReader(f)
+	if err != nil {
+		return err
+	}
+
+	now := a.now()
+	elapsed := now.Sub(sr.WrittenAt())
+	if elapsed < 0 {
+		return errSnapshotFuture
+	}
+
+	a.m.Lock()
+	defer a.m.Unlock()
+	for {
+		rec, err := sr.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		it := a.newItem(rec.Data, now)
+		if !rec.Expires.IsZero() {
+			remaining := rec.Expires.Sub(sr.WrittenAt()) - elapsed
+			if remaining <= 0 {
+				continue
+			}
+			it.expire = now.Add(remaining)
+		}
+		a.values[rec.Key] = it
+	}
+}
@@ -0,0 +1,222 @@
+package memadapter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+func tempSnapshot(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "memadapter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "cache.snapshot"), func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSnapshot_CloseAndLoad(t *testing.T) {
+	path, cleanup := tempSnapshot(t)
+	defer cleanup()
+
+	a, err := New(time.Hour, false, WithSnapshot(path, 0))()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Set("1", []byte("One"))
+	a.(*Adapter).SetWithTTL("2", []byte("Two"), -time.Second)
+
+	err = a.(*Adapter).Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	b, err := New(time.Hour, false, WithSnapshot(path, 0))()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.(*Adapter).Close()
+
+	data, err := b.Get("1")
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(data, []byte("One")) {
+		t.Error("got different data")
+	}
+	_, err = b.Get("2")
+	if err != cache.ErrNotFound {
+		t.Error("expected expired item to be dropped")
+	}
+
+	files, _ := ioutil.ReadDir(filepath.Dir(path))
+	if len(files) != 1 {
+		t.Error("expected temporary file to be removed")
+	}
+}
+
+func writeSnapshot(t *testing.T, path string, writtenAt time.Time, records ...cache.Record) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	sw, err := cache.NewSnapshotWriter(f, writtenAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		sw.Write(rec)
+	}
+	sw.Flush()
+}
+
+func TestSnapshot_RemainingTTL(t *testing.T) {
+	path, cleanup := tempSnapshot(t)
+	defer cleanup()
+
+	writtenAt := time.Now().Add(-time.Second * 30)
+	writeSnapshot(t, path, writtenAt,
+		cache.Record{Key: "1", Expires: writtenAt.Add(time.Minute)},
+		cache.Record{Key: "2", Expires: writtenAt.Add(time.Second * 10)},
+	)
+
+	a, err := New(time.Hour, false, WithSnapshot(path, 0))()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.(*Adapter).Close()
+
+	_, meta, err := a.(*Adapter).GetWithMetadata("1")
+	if err != nil {
+		t.Error(err)
+	}
+	if remaining := time.Until(meta.Expires); remaining > time.Second*31 || remaining < time.Second*29 {
+		t.Errorf("expected 30s remaining but got %s", remaining)
+	}
+
+	_, err = a.Get("2")
+	if err != cache.ErrNotFound {
+		t.Error("expected expired item to be dropped")
+	}
+}
+
+func TestSnapshot_ClockWentBackwards(t *testing.T) {
+	path, cleanup := tempSnapshot(t)
+	defer cleanup()
+
+	// the snapshot seems to be written in the future
+	writtenAt := time.Now().Add(time.Hour)
+	writeSnapshot(t, path, writtenAt,
+		cache.Record{Key: "1", Expires: writtenAt.Add(time.Minute)},
+	)
+
+	var errs []error
+	a, err := New(time.Hour, false, WithSnapshot(path, 0), WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.(*Adapter).Close()
+
+	// the time since the snapshot is unknown
+	if _, err := a.Get("1"); err != cache.ErrNotFound {
+		t.Error("expected the snapshot not to be loaded")
+	}
+	if len(errs) != 1 || errs[0] != errSnapshotFuture {
+		t.Errorf("expected errSnapshotFuture but got %v", errs)
+	}
+}
+
+func TestSnapshot_Corrupt(t *testing.T) {
+	path, cleanup := tempSnapshot(t)
+	defer cleanup()
+
+	writeSnapshot(t, path, time.Now(),
+		cache.Record{Key: "1", Data: []byte("One")},
+		cache.Record{Key: "2", Data: []byte("Two")},
+	)
+	// cut off the second record
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Truncate(path, info.Size()-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []error
+	a, err := New(time.Hour, false, WithSnapshot(path, 0), WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))()
+	if err != nil {
+		t.Fatalf("expected a corrupt snapshot not to stop New but got %v", err)
+	}
+	defer a.(*Adapter).Close()
+
+	if _, err := a.Get("1"); err != cache.ErrNotFound {
+		t.Error("expected the adapter to start empty")
+	}
+	if len(errs) != 1 || errs[0] != cache.ErrInvalidSnapshot {
+		t.Errorf("expected ErrInvalidSnapshot but got %v", errs)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected the corrupt snapshot to be removed")
+	}
+}
+
+func TestSnapshot_Periodic(t *testing.T) {
+	path, cleanup := tempSnapshot(t)
+	defer cleanup()
+
+	a, err := New(time.Hour, false, WithSnapshot(path, time.Millisecond*10))()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.(*Adapter).Close()
+	a.Set("1", []byte("One"))
+
+	time.Sleep(time.Millisecond * 50)
+	if _, err := os.Stat(path); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSnapshot_ErrorHandler(t *testing.T) {
+	path, cleanup := tempSnapshot(t)
+	defer cleanup()
+
+	errs := make(chan error, 10)
+	// the directory does not exist, so every write fails
+	a, err := New(time.Hour, false,
+		WithSnapshot(filepath.Join(path, "missing", "cache.snapshot"), time.Millisecond*10),
+		WithErrorHandler(func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		}),
+	)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.(*Adapter).Close()
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("expected an error")
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the error handler to be called")
+	}
+}
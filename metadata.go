@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Metadata is what an adapter knows about an item
+// besides the data. Unknown times are zero.
+type Metadata struct {
+	Expires time.Time
+	Created time.Time
+}
+
+// MetadataGetter is implemented by adapters that can return
+// the metadata of an item together with the data.
+type MetadataGetter interface {
+	GetWithMetadata(key string) ([]byte, Metadata, error)
+}
+
+// ContextMetadataGetter is implemented by adapters that can
+// cancel a GetWithMetadata, like ContextGetter for Get.
+type ContextMetadataGetter interface {
+	GetWithMetadataContext(ctx context.Context, key string) ([]byte, Metadata, error)
+}
+
+// Namer is implemented by adapters that have a name
+// that is more readable than their type.
+type Namer interface {
+	Name() string
+}
+
+func adapterName(adapter Adapter) string {
+	if namer, ok := adapter.(Namer); ok {
+		return namer.Name()
+	}
+	return fmt.Sprintf("%T", adapter)
+}
+
+// ItemInfo describes where an item was found.
+type ItemInfo struct {
+	// Expires is when the item expires. It is zero if the
+	// item does not expire or the adapter does not know it.
+	Expires time.Time
+	// Created is when the item was set.
+	Created time.Time
+
+	// Tier is the index of the adapter that returned the item
+	// and TierName the name of that adapter.
+	Tier     int
+	TierName string
+
+	// Size is the length of the encoded data.
+	Size int
+	// Codec is the name of the encoding of the data.
+	Codec string
+}
+
+// GetWithMetadata works like Get but also returns
+// information about the item, for example for debugging.
+func (c *Cache) GetWithMetadata(key string, target interface{}) (ItemInfo, error) {
+	data, info, err := c.get(context.Background(), key, true)
+	if err != nil {
+		return ItemInfo{}, err
+	}
+
+	return info, c.decode(data, target)
+}
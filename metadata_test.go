@@ -0,0 +1,97 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+type metadataMock struct {
+	*AdapterMock
+	GetWithMetadataFunc func(key string) ([]byte, cache.Metadata, error)
+}
+
+func (m *metadataMock) GetWithMetadata(key string) ([]byte, cache.Metadata, error) {
+	return m.GetWithMetadataFunc(key)
+}
+func (m *metadataMock) Name() string {
+	return "mock"
+}
+
+func TestGetWithMetadata(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	created := time.Now()
+	data, _ := msgpack.Marshal("One")
+
+	mock1 := &AdapterMock{
+		GetFunc: func(key string) ([]byte, error) {
+			return nil, cache.ErrNotFound
+		},
+	}
+	mock2 := &metadataMock{
+		AdapterMock: &AdapterMock{},
+		GetWithMetadataFunc: func(key string) ([]byte, cache.Metadata, error) {
+			return data, cache.Metadata{Expires: expires, Created: created}, nil
+		},
+	}
+
+	adapter1 := func() (cache.Adapter, error) {
+		return mock1, nil
+	}
+	adapter2 := func() (cache.Adapter, error) {
+		return mock2, nil
+	}
+	c, err := cache.New(adapter1, adapter2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var target string
+	info, err := c.GetWithMetadata("1", &target)
+	if err != nil {
+		t.Error(err)
+	}
+	if target != "One" {
+		t.Errorf("expected 'One' but got '%s'", target)
+	}
+
+	if info.Tier != 1 || info.TierName != "mock" {
+		t.Errorf("wrong tier: %d %s", info.Tier, info.TierName)
+	}
+	if !info.Expires.Equal(expires) || !info.Created.Equal(created) {
+		t.Error("wrong times")
+	}
+	if info.Size != len(data) || info.Codec != "msgpack" {
+		t.Error("wrong size or codec")
+	}
+}
+
+func TestGetWithMetadata_TypeName(t *testing.T) {
+	mock1 := &AdapterMock{
+		GetFunc: func(key string) ([]byte, error) {
+			return msgpack.Marshal("One")
+		},
+	}
+	adapter1 := func() (cache.Adapter, error) {
+		return mock1, nil
+	}
+	c, err := cache.New(adapter1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var target string
+	info, err := c.GetWithMetadata("1", &target)
+	if err != nil {
+		t.Error(err)
+	}
+	if info.TierName != "*cache_test.AdapterMock" {
+		t.Errorf("unexpected name: %s", info.TierName)
+	}
+	if !info.Expires.IsZero() {
+		t.Error("expected unknown expiry")
+	}
+}
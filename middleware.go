@@ -1,7 +1,6 @@
 package cache
 
 import (
-	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -112,7 +111,7 @@ func (c *Cache) Middleware() func(http.Handler) http.Handler {
 				go func() {
 					err = c.Set(key, resp)
 					if err != nil {
-						fmt.Println("set err:", err)
+						c.logf("set err: %v", err)
 					}
 				}()
 			} else {
@@ -8,7 +8,7 @@ import (
 	"time"
 
 	cache "github.com/JohannesKaufmann/dynamodb-cache"
-	"github.com/JohannesKaufmann/dynamodb-cache/memory"
+	"github.com/JohannesKaufmann/dynamodb-cache/memadapter"
 )
 
 func TestMiddleware(t *testing.T) {
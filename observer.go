@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"time"
+)
+
+// Logger is implemented by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// logf does nothing without WithLogger.
+func (c *Cache) logf(format string, v ...interface{}) {
+	if c.logger != nil {
+		c.logger.Printf(format, v...)
+	}
+}
+
+// Event describes one operation of the cache.
+type Event struct {
+	// Op is "get", "set", "add", "cas", "touch", "incr", "del",
+	// "getmulti", "setmulti" or "delmulti".
+	Op string
+	// Key is the key as it is passed to the adapters. It is
+	// empty for the operations on several keys.
+	Key string
+	// Tier is the adapter that had the item, or -1.
+	Tier     int
+	TierName string
+	// Hit is true if a get found the item.
+	Hit      bool
+	Err      error
+	Duration time.Duration
+}
+
+// Observer is called after every operation, for example
+// to record metrics. It should return quickly.
+type Observer interface {
+	Observe(e Event)
+}
+
+// ObserverFunc is a function that is used as an Observer.
+type ObserverFunc func(e Event)
+
+func (f ObserverFunc) Observe(e Event) {
+	f(e)
+}
+
+// observe calls the observer for an operation that
+// is not about a single adapter and returns err.
+func (c *Cache) observe(op, key string, start time.Time, err error) error {
+	if c.observer != nil {
+		c.observer.Observe(Event{
+			Op:       op,
+			Key:      key,
+			Tier:     -1,
+			Err:      err,
+			Duration: time.Since(start),
+		})
+	}
+	return err
+}
@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Option configures the cache in NewWithOptions.
+type Option func(*Cache)
+
+// WithAdapters adds the adapters. Items are looked up in
+// the order of the adapters, so the fastest comes first.
+func WithAdapters(adapters ...InitAdapter) Option {
+	return func(c *Cache) {
+		c.inits = append(c.inits, adapters...)
+	}
+}
+
+// WithCodec changes how Get and Set encode the values. The
+// default is MsgpackCodec.
+func WithCodec(codec Codec) Option {
+	return func(c *Cache) {
+		c.codec = codec
+	}
+}
+
+// WithLogger sets where errors of background work are logged,
+// for example of the Middleware. Without it nothing is logged.
+func WithLogger(logger Logger) Option {
+	return func(c *Cache) {
+		c.logger = logger
+	}
+}
+
+// WithObserver calls the observer after every operation,
+// for example to record metrics.
+func WithObserver(observer Observer) Option {
+	return func(c *Cache) {
+		c.observer = observer
+	}
+}
+
+// WithKeyTransformer changes every key before it is passed to the
+// adapters, for example to add a namespace. DelPrefix and Iterate
+// transform the prefix as well, so they only work with a transformer
+// that keeps prefixes (like adding a namespace, but unlike hashing).
+// Keys and Iterate return the transformed keys, there is no inverse.
+func WithKeyTransformer(transform func(key string) string) Option {
+	return func(c *Cache) {
+		c.transform = transform
+	}
+}
+
+// WithRenewOnRead extends the expiry of an item by ttl every time
+// it is returned by Get. It works with every adapter that implements
+// Toucher. Zero (the default) disables it.
+func WithRenewOnRead(ttl time.Duration) Option {
+	return func(c *Cache) {
+		c.renewOnRead = ttl
+	}
+}
+
+// WithReadStrategy decides how Get queries the adapters. The default
+// is Sequential. hedgeDelay is how long the Hedged strategy waits for
+// an adapter before it also queries the next one; the other strategies
+// ignore it.
+func WithReadStrategy(strategy ReadStrategy, hedgeDelay time.Duration) Option {
+	return func(c *Cache) {
+		c.readStrategy = strategy
+		c.hedgeDelay = hedgeDelay
+	}
+}
+
+// WithWriteStrategy decides how Set changes the adapters.
+// The default is TopDown.
+func WithWriteStrategy(strategy WriteStrategy) Option {
+	return func(c *Cache) {
+		c.writeStrategy = strategy
+	}
+}
+
+// WithDelStrategy decides how Del changes the adapters.
+// The default is TopDown.
+func WithDelStrategy(strategy WriteStrategy) Option {
+	return func(c *Cache) {
+		c.delStrategy = strategy
+	}
+}
+
+// NewWithOptions initializes a new cache with the options.
+// At least one adapter is needed.
+func NewWithOptions(opts ...Option) (*Cache, error) {
+	c := &Cache{codec: MsgpackCodec{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if len(c.inits) == 0 {
+		return nil, errors.New("you need at least one adapter")
+	}
+
+	named := make(map[string]bool)
+	for _, init := range c.inits {
+		adapter, err := init()
+		if err != nil {
+			return nil, err
+		}
+
+		name := adapterName(adapter)
+		if tier, ok := adapter.(*namedAdapter); ok {
+			if named[tier.name] {
+				return nil, fmt.Errorf("tier %q is used twice", tier.name)
+			}
+			named[tier.name] = true
+
+			adapter = tier.Adapter
+			name = tier.name
+		}
+
+		c.adapters = append(c.adapters, adapter)
+		c.names = append(c.names, name)
+	}
+	c.inits = nil
+
+	return c, nil
+}
+
+// Tier gives the adapter a name, which is used in ItemInfo,
+// TierError and Event instead of the name of the adapter.
+//
+//	cache.NewWithOptions(cache.WithAdapters(
+//		cache.Tier("l1", memadapter.New(time.Minute, false)),
+//		cache.Tier("l2", dynadapter.New(db, "Cache", time.Hour)),
+//	))
+func Tier(name string, init InitAdapter) InitAdapter {
+	return func() (Adapter, error) {
+		adapter, err := init()
+		if err != nil {
+			return nil, err
+		}
+		return &namedAdapter{Adapter: adapter, name: name}, nil
+	}
+}
+
+// namedAdapter is unwrapped by NewWithOptions, so that the
+// optional interfaces of the adapter can still be used.
+type namedAdapter struct {
+	Adapter
+	name string
+}
+
+func (a *namedAdapter) Name() string {
+	return a.name
+}
+
+// key applies the key transformer.
+func (c *Cache) key(key string) string {
+	if c.transform == nil {
+		return key
+	}
+	return c.transform(key)
+}
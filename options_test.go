@@ -0,0 +1,189 @@
+package cache_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/JohannesKaufmann/dynamodb-cache/memadapter"
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return "json" }
+
+func TestNewWithOptions_NoAdapters(t *testing.T) {
+	_, err := cache.NewWithOptions(cache.WithCodec(jsonCodec{}))
+	if err == nil {
+		t.Error("expected NewWithOptions to fail because there is no adapter")
+	}
+}
+
+func TestTier(t *testing.T) {
+	c, err := cache.NewWithOptions(cache.WithAdapters(
+		cache.Tier("l1", memadapter.New(time.Hour, false)),
+		memadapter.New(time.Hour, false),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Set("key", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value string
+	info, err := c.GetWithMetadata("key", &value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.TierName != "l1" {
+		t.Errorf("expected tier 'l1' but got '%s'", info.TierName)
+	}
+
+	// the optional interfaces of the adapter are still used
+	err = c.Touch("key", time.Minute)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTier_Duplicate(t *testing.T) {
+	_, err := cache.NewWithOptions(cache.WithAdapters(
+		cache.Tier("l1", memadapter.New(time.Hour, false)),
+		cache.Tier("l1", memadapter.New(time.Hour, false)),
+	))
+	if err == nil {
+		t.Error("expected an error for a duplicate tier name")
+	}
+}
+
+func TestWithCodec(t *testing.T) {
+	mem := memadapter.New(time.Hour, false)
+	c, err := cache.NewWithOptions(cache.WithAdapters(mem), cache.WithCodec(jsonCodec{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Set("key", map[string]int{"a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var value map[string]int
+	info, err := c.GetWithMetadata("key", &value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value["a"] != 1 || info.Codec != "json" {
+		t.Errorf("unexpected value %v with codec '%s'", value, info.Codec)
+	}
+}
+
+func TestWithObserver(t *testing.T) {
+	var events []cache.Event
+	c, err := cache.NewWithOptions(
+		cache.WithAdapters(cache.Tier("l1", memadapter.New(time.Hour, false))),
+		cache.WithObserver(cache.ObserverFunc(func(e cache.Event) {
+			events = append(events, e)
+		})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var value string
+	c.Get("key", &value)
+	c.Set("key", "value")
+	c.Get("key", &value)
+	c.Del("key")
+
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events but got %d", len(events))
+	}
+	if events[0].Op != "get" || events[0].Hit || events[0].Err != cache.ErrNotFound || events[0].Tier != -1 {
+		t.Errorf("expected a miss but got %+v", events[0])
+	}
+	if events[1].Op != "set" || events[1].Err != nil {
+		t.Errorf("expected a set but got %+v", events[1])
+	}
+	if events[2].Op != "get" || !events[2].Hit || events[2].TierName != "l1" {
+		t.Errorf("expected a hit but got %+v", events[2])
+	}
+	if events[3].Op != "del" || events[3].Key != "key" {
+		t.Errorf("expected a del but got %+v", events[3])
+	}
+}
+
+func TestWithKeyTransformer(t *testing.T) {
+	var keys []string
+	mock := &AdapterMock{
+		SetFunc: func(key string, data []byte) error {
+			keys = append(keys, key)
+			return nil
+		},
+		GetFunc: func(key string) ([]byte, error) {
+			keys = append(keys, key)
+			return nil, cache.ErrNotFound
+		},
+		DelFunc: func(key string) error {
+			keys = append(keys, key)
+			return nil
+		},
+	}
+	c, err := cache.NewWithOptions(
+		cache.WithAdapters(func() (cache.Adapter, error) { return mock, nil }),
+		cache.WithKeyTransformer(func(key string) string { return "v2:" + key }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var value string
+	c.Set("a", "value")
+	c.Get("b", &value)
+	c.Del("c")
+
+	if strings.Join(keys, ",") != "v2:a,v2:b,v2:c" {
+		t.Errorf("expected transformed keys but got %v", keys)
+	}
+}
+
+type logger struct {
+	lines []string
+}
+
+func (l *logger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestWithLogger(t *testing.T) {
+	local := &AdapterMock{
+		DelFunc: func(key string) error { return errors.New("del failed") },
+	}
+	l := &logger{}
+	c, err := cache.NewWithOptions(
+		cache.WithAdapters(
+			func() (cache.Adapter, error) { return local, nil },
+			memadapter.New(time.Hour, false),
+		),
+		cache.WithLogger(l),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inv := &invalidatorMock{}
+	c.SetInvalidator(inv)
+	inv.fn(cache.Invalidation{Keys: []string{"key"}})
+
+	if len(l.lines) != 1 || l.lines[0] != "invalidate err: del failed" {
+		t.Errorf("unexpected log %v", l.lines)
+	}
+}
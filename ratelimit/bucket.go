@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// TokenBucket allows bursts of up to burst requests. The bucket
+// is refilled with rate tokens per second and every request
+// takes one token.
+type TokenBucket struct {
+	c     *cache.Cache
+	rate  float64
+	burst int
+	now   func() time.Time
+}
+
+// NewTokenBucket returns a TokenBucket limiter. The TTL of the
+// adapters should be at least as long as it takes to refill the bucket.
+func NewTokenBucket(c *cache.Cache, rate float64, burst int) *TokenBucket {
+	return &TokenBucket{c: c, rate: rate, burst: burst, now: time.Now}
+}
+
+func (l *TokenBucket) seconds(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+func (l *TokenBucket) Allow(key string) (Result, error) {
+	now := l.now().UnixNano()
+
+	res := Result{Limit: l.burst}
+	err := update(l.c, key, func(s *state, found bool) bool {
+		if !found {
+			*s = state{Window: now, Count: float64(l.burst)}
+		}
+
+		elapsed := float64(now-s.Window) / float64(time.Second)
+		if elapsed > 0 {
+			s.Count = math.Min(float64(l.burst), s.Count+elapsed*l.rate)
+			s.Window = now
+		}
+
+		res.Allowed = s.Count >= 1
+		if res.Allowed {
+			s.Count--
+		} else {
+			res.RetryAfter = l.seconds(1 - s.Count)
+		}
+
+		res.Remaining = int(s.Count)
+		res.Reset = l.seconds(float64(l.burst) - s.Count)
+		return res.Allowed
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	return res, nil
+}
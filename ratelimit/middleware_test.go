@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	l := NewFixedWindow(newCache(t), 1, time.Hour)
+	m := Middleware(l, ByRemoteAddr)
+
+	handler := m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	get := func(expectedCode int, expectedRemaining string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Code != expectedCode {
+			t.Errorf("expected %d but got %d", expectedCode, rec.Code)
+		}
+		if val := rec.Header().Get("RateLimit-Limit"); val != "1" {
+			t.Errorf("unexpected 'RateLimit-Limit' header: %s", val)
+		}
+		if val := rec.Header().Get("RateLimit-Remaining"); val != expectedRemaining {
+			t.Errorf("unexpected 'RateLimit-Remaining' header: %s", val)
+		}
+		if rec.Header().Get("RateLimit-Reset") == "" {
+			t.Error("header 'RateLimit-Reset' is missing")
+		}
+		return rec
+	}
+	get(http.StatusOK, "0")
+	rec := get(http.StatusTooManyRequests, "0")
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("header 'Retry-After' is missing")
+	}
+}
+
+func TestByRemoteAddr(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected string
+	}{
+		{"192.0.2.1:1234", "192.0.2.1"},
+		{"192.0.2.1:5678", "192.0.2.1"},
+		{"[2001:db8::1]:1234", "2001:db8::1"},
+		{"pipe", "pipe"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.addr
+		if key := ByRemoteAddr(r); key != test.expected {
+			t.Errorf("expected '%s' for '%s' but got '%s'", test.expected, test.addr, key)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Package ratelimit provides rate limiters that save their state
+// in a cache. With a memadapter the limit is per instance and
+// with a dynadapter it is shared by the whole fleet.
+//
+// The state is only changed with the atomic operations of the
+// cache (Add and CompareAndSwap), so concurrent requests
+// can not exceed the limit.
+package ratelimit
+
+import (
+	"errors"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// KeyPrefix is put in front of the key passed to Allow to
+// get the key of the state in the cache.
+const KeyPrefix = "ratelimit:"
+
+// MaxAttempts is how often the state is read and written
+// again if it was changed concurrently.
+var MaxAttempts = 10
+
+// ErrContention is returned if the state could not be
+// written after MaxAttempts attempts.
+var ErrContention = errors.New("ratelimit: too many concurrent updates")
+
+// Result is the outcome of a call to Allow.
+type Result struct {
+	Allowed bool
+
+	// Limit is the number of requests allowed per window (or
+	// the size of the bucket).
+	Limit int
+	// Remaining is the number of requests that are still
+	// allowed in the current window.
+	Remaining int
+	// Reset is the time until the quota is completely available again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed.
+	// It is only set if the request was not allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter decides whether a request identified by key is allowed.
+type Limiter interface {
+	Allow(key string) (Result, error)
+}
+
+// state is what is saved in the cache. The fields are
+// interpreted differently by the limiters.
+type state struct {
+	// Window is the index of the window (fixed and sliding window)
+	// or the time of the last refill in nanoseconds (token bucket).
+	Window int64
+	// Count is the number of requests in the window or
+	// the number of tokens in the bucket.
+	Count float64
+	// Prev is the number of requests in the previous window.
+	Prev float64
+}
+
+// update reads the state for that key and calls fn with it. If fn
+// returns true the changed state is written back. If the state was
+// changed concurrently it starts again.
+func update(c *cache.Cache, key string, fn func(s *state, found bool) bool) error {
+	key = KeyPrefix + key
+
+	for attempt := 0; attempt < MaxAttempts; attempt++ {
+		var old state
+		err := c.Get(key, &old)
+		if err != nil && err != cache.ErrNotFound && err != cache.ErrExpired {
+			return err
+		}
+		found := err == nil
+
+		s := old
+		if !fn(&s, found) {
+			return nil
+		}
+
+		if found {
+			err = c.CompareAndSwap(key, old, s)
+		} else {
+			err = c.Add(key, s)
+		}
+		if err == cache.ErrConflict || err == cache.ErrAlreadyExists {
+			continue
+		}
+		return err
+	}
+
+	return ErrContention
+}
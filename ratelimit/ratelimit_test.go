@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/JohannesKaufmann/dynamodb-cache/memadapter"
+)
+
+func newCache(t *testing.T) *cache.Cache {
+	c, err := cache.New(memadapter.New(time.Hour, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func allow(t *testing.T, l Limiter, expected bool, remaining int) Result {
+	res, err := l.Allow("client")
+	if err != nil {
+		t.Error(err)
+	}
+	if res.Allowed != expected {
+		t.Errorf("expected allowed to be %v", expected)
+	}
+	if res.Remaining != remaining {
+		t.Errorf("expected %d remaining but got %d", remaining, res.Remaining)
+	}
+	return res
+}
+
+func TestFixedWindow(t *testing.T) {
+	clk := &clock{now: time.Unix(1000, 0)}
+	l := NewFixedWindow(newCache(t), 2, time.Minute)
+	l.now = clk.Now
+
+	allow(t, l, true, 1)
+	allow(t, l, true, 0)
+	res := allow(t, l, false, 0)
+	if res.RetryAfter != time.Second*20 {
+		t.Errorf("expected to retry at the start of the next window but got %s", res.RetryAfter)
+	}
+
+	clk.now = clk.now.Add(time.Second * 20)
+	allow(t, l, true, 1)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	clk := &clock{now: time.Unix(960, 0)}
+	l := NewSlidingWindow(newCache(t), 4, time.Minute)
+	l.now = clk.Now
+
+	for i := 3; i >= 0; i-- {
+		allow(t, l, true, i)
+	}
+	allow(t, l, false, 0)
+
+	// at the start of the next window all requests of the
+	// previous window still count.
+	clk.now = clk.now.Add(time.Minute)
+	res := allow(t, l, false, 0)
+	if res.RetryAfter != time.Second*15 {
+		t.Errorf("expected to retry after one request slid out but got %s", res.RetryAfter)
+	}
+
+	// half of the previous window slid out
+	clk.now = clk.now.Add(time.Second * 30)
+	allow(t, l, true, 1)
+	allow(t, l, true, 0)
+	allow(t, l, false, 0)
+}
+
+func TestTokenBucket(t *testing.T) {
+	clk := &clock{now: time.Unix(1000, 0)}
+	l := NewTokenBucket(newCache(t), 1, 2)
+	l.now = clk.Now
+
+	allow(t, l, true, 1)
+	allow(t, l, true, 0)
+	res := allow(t, l, false, 0)
+	if res.RetryAfter != time.Second {
+		t.Errorf("expected to retry after one token but got %s", res.RetryAfter)
+	}
+
+	clk.now = clk.now.Add(time.Second * 10)
+	res = allow(t, l, true, 1)
+	if res.Reset != time.Second {
+		t.Errorf("expected bucket to be full after one second but got %s", res.Reset)
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	MaxAttempts = 1000
+	defer func() { MaxAttempts = 10 }()
+
+	l := NewFixedWindow(newCache(t), 25, time.Hour)
+
+	var wg sync.WaitGroup
+	var m sync.Mutex
+	var allowed int
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := l.Allow("client")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if res.Allowed {
+				m.Lock()
+				allowed++
+				m.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 25 {
+		t.Errorf("expected 25 allowed requests but got %d", allowed)
+	}
+}
@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// FixedWindow allows limit requests per window. The windows
+// start at multiples of the window duration.
+type FixedWindow struct {
+	c      *cache.Cache
+	limit  int
+	window time.Duration
+	now    func() time.Time
+}
+
+// NewFixedWindow returns a FixedWindow limiter. The TTL of the
+// adapters should be at least as long as the window.
+func NewFixedWindow(c *cache.Cache, limit int, window time.Duration) *FixedWindow {
+	return &FixedWindow{c: c, limit: limit, window: window, now: time.Now}
+}
+
+func (l *FixedWindow) Allow(key string) (Result, error) {
+	now := l.now()
+	index := now.UnixNano() / int64(l.window)
+	reset := time.Duration((index+1)*int64(l.window) - now.UnixNano())
+
+	res := Result{Limit: l.limit, Reset: reset}
+	err := update(l.c, key, func(s *state, found bool) bool {
+		if !found || s.Window != index {
+			*s = state{Window: index}
+		}
+
+		res.Allowed = int(s.Count) < l.limit
+		if !res.Allowed {
+			res.Remaining = 0
+			res.RetryAfter = reset
+			return false
+		}
+
+		s.Count++
+		res.Remaining = l.limit - int(s.Count)
+		return true
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	return res, nil
+}
+
+// SlidingWindow allows limit requests in any period of the window
+// duration. It approximates the number of requests by weighting
+// the count of the previous window with how much of it still
+// overlaps with the sliding window.
+type SlidingWindow struct {
+	c      *cache.Cache
+	limit  int
+	window time.Duration
+	now    func() time.Time
+}
+
+// NewSlidingWindow returns a SlidingWindow limiter. The TTL of the
+// adapters should be at least twice as long as the window.
+func NewSlidingWindow(c *cache.Cache, limit int, window time.Duration) *SlidingWindow {
+	return &SlidingWindow{c: c, limit: limit, window: window, now: time.Now}
+}
+
+func (l *SlidingWindow) Allow(key string) (Result, error) {
+	now := l.now()
+	index := now.UnixNano() / int64(l.window)
+	elapsed := time.Duration(now.UnixNano() - index*int64(l.window))
+	weight := 1 - float64(elapsed)/float64(l.window)
+
+	res := Result{Limit: l.limit}
+	err := update(l.c, key, func(s *state, found bool) bool {
+		if !found {
+			*s = state{Window: index}
+		}
+		switch {
+		case s.Window == index-1:
+			*s = state{Window: index, Prev: s.Count}
+		case s.Window != index:
+			*s = state{Window: index}
+		}
+
+		count := s.Prev*weight + s.Count
+		res.Allowed = count+1 <= float64(l.limit)
+		if res.Allowed {
+			s.Count++
+			count++
+		}
+
+		res.Remaining = l.limit - int(math.Ceil(count))
+		if res.Remaining < 0 {
+			res.Remaining = 0
+		}
+		// the requests of the previous window slide out until
+		// the end of this window, the requests of this window
+		// until the end of the next one.
+		res.Reset = l.window - elapsed
+		if s.Count > 0 {
+			res.Reset += l.window
+		}
+		if !res.Allowed {
+			res.RetryAfter = l.retryAfter(s, elapsed)
+		}
+		return res.Allowed
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	return res, nil
+}
+
+// retryAfter calculates when enough requests of the previous
+// window slid out, so that one request is allowed again.
+func (l *SlidingWindow) retryAfter(s *state, elapsed time.Duration) time.Duration {
+	free := float64(l.limit) - 1 - s.Count
+	if free < 0 || s.Prev == 0 {
+		// only the next window helps
+		return l.window - elapsed
+	}
+
+	// Prev * (1 - t/window) <= free  =>  t >= window * (1 - free/Prev)
+	t := time.Duration(float64(l.window) * (1 - free/s.Prev))
+	if t <= elapsed {
+		return 0
+	}
+	return t - elapsed
+}
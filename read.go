@@ -0,0 +1,237 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// ReadStrategy decides how Get queries the adapters.
+type ReadStrategy int
+
+const (
+	// Sequential queries one adapter after another until
+	// one has the item.
+	Sequential ReadStrategy = iota
+	// Parallel queries every adapter at the same time. The first
+	// adapter (in the order of New) that has the item wins, so
+	// Get waits for the adapters in front of it.
+	Parallel
+	// Hedged queries the first adapter and also the next one if
+	// there is no answer after the hedge delay (or if the item was not
+	// found). The first adapter that has the item wins.
+	Hedged
+)
+
+// ContextGetter is implemented by adapters that can cancel a
+// Get, for example because another adapter answered faster.
+type ContextGetter interface {
+	GetWithContext(ctx context.Context, key string) ([]byte, error)
+}
+
+// result is the answer of the adapter with that index.
+type result struct {
+	tier int
+	data []byte
+	meta Metadata
+	err  error
+}
+
+func isMiss(err error) bool {
+	return err == ErrNotFound || err == ErrExpired
+}
+
+// get returns the data from the first adapter that has the item.
+// If withMetadata is true the metadata is requested from
+// the adapters that implement MetadataGetter.
+func (c *Cache) get(ctx context.Context, key string, withMetadata bool) ([]byte, ItemInfo, error) {
+	key = c.key(key)
+	start := time.Now()
+	data, info, err := c.read(ctx, key, withMetadata)
+
+	if c.observer != nil {
+		e := Event{Op: "get", Key: key, Tier: -1, Err: err, Duration: time.Since(start)}
+		if err == nil {
+			e.Hit = true
+			e.Tier = info.Tier
+			e.TierName = info.TierName
+		}
+		c.observer.Observe(e)
+	}
+	return data, info, err
+}
+
+// read queries the adapters with the ReadStrategy.
+func (c *Cache) read(ctx context.Context, key string, withMetadata bool) ([]byte, ItemInfo, error) {
+	switch c.readStrategy {
+	case Parallel:
+		return c.getParallel(ctx, key, withMetadata)
+	case Hedged:
+		return c.getHedged(ctx, key, withMetadata)
+	}
+
+	var finalErr = ErrNotFound
+	for i := range c.adapters {
+		r := c.getFrom(ctx, i, key, withMetadata)
+		if isMiss(r.err) {
+			finalErr = r.err
+			continue
+		} else if r.err != nil {
+			return nil, ItemInfo{}, r.err
+		}
+
+		return c.found(key, r)
+	}
+
+	return nil, ItemInfo{}, finalErr
+}
+
+// getParallel queries every adapter at once, but the results are
+// used in the order of the adapters, like with Sequential.
+func (c *Cache) getParallel(ctx context.Context, key string, withMetadata bool) ([]byte, ItemInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan result, len(c.adapters))
+	for i := range c.adapters {
+		go func(i int) {
+			results <- c.getFrom(ctx, i, key, withMetadata)
+		}(i)
+	}
+
+	var finalErr = ErrNotFound
+	answers := make([]*result, len(c.adapters))
+	var next int
+	for next < len(c.adapters) {
+		select {
+		case r := <-results:
+			answers[r.tier] = &r
+		case <-ctx.Done():
+			return nil, ItemInfo{}, ctx.Err()
+		}
+
+		for next < len(c.adapters) && answers[next] != nil {
+			r := answers[next]
+			if isMiss(r.err) {
+				finalErr = r.err
+				next++
+				continue
+			} else if r.err != nil {
+				return nil, ItemInfo{}, r.err
+			}
+
+			return c.found(key, *r)
+		}
+	}
+
+	return nil, ItemInfo{}, finalErr
+}
+
+// getHedged starts with the first adapter and adds the next one
+// after every hedge delay or miss. The first item that is found wins.
+// If no adapter has the item, the first error in the order of the
+// adapters is returned.
+func (c *Cache) getHedged(ctx context.Context, key string, withMetadata bool) ([]byte, ItemInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan result, len(c.adapters))
+	var started int
+	startNext := func() {
+		if started == len(c.adapters) {
+			return
+		}
+		go func(i int) {
+			results <- c.getFrom(ctx, i, key, withMetadata)
+		}(started)
+		started++
+	}
+
+	timer := time.NewTimer(c.hedgeDelay)
+	defer timer.Stop()
+	startNext()
+
+	errs := make([]error, len(c.adapters))
+	for answered := 0; answered < len(c.adapters); {
+		select {
+		case r := <-results:
+			answered++
+			if r.err == nil {
+				return c.found(key, r)
+			}
+			errs[r.tier] = r.err
+			startNext()
+		case <-timer.C:
+			startNext()
+			timer.Reset(c.hedgeDelay)
+		case <-ctx.Done():
+			return nil, ItemInfo{}, ctx.Err()
+		}
+	}
+
+	var finalErr = ErrNotFound
+	for _, err := range errs {
+		if !isMiss(err) {
+			return nil, ItemInfo{}, err
+		}
+		finalErr = err
+	}
+	return nil, ItemInfo{}, finalErr
+}
+
+// getFrom queries the adapter with that index.
+func (c *Cache) getFrom(ctx context.Context, i int, key string, withMetadata bool) result {
+	r := result{tier: i}
+
+	adapter := c.adapters[i]
+	var metaGetter MetadataGetter
+	if withMetadata && as(adapter, &metaGetter) {
+		r.data, r.meta, r.err = getWithMetadata(ctx, adapter, metaGetter, key)
+	} else if getter, ok := adapter.(ContextGetter); ok {
+		r.data, r.err = getter.GetWithContext(ctx, key)
+	} else {
+		r.data, r.err = adapter.Get(key)
+	}
+	return r
+}
+
+// getWithMetadata cancels the call if the adapter implements
+// ContextMetadataGetter. Otherwise the call can't be canceled,
+// so the context is only checked before and after it.
+func getWithMetadata(ctx context.Context, adapter Adapter, metaGetter MetadataGetter, key string) ([]byte, Metadata, error) {
+	if getter, ok := adapter.(ContextMetadataGetter); ok {
+		return getter.GetWithMetadataContext(ctx, key)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	data, meta, err := metaGetter.GetWithMetadata(key)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, Metadata{}, ctxErr
+	}
+	return data, meta, err
+}
+
+// found renews the item if WithRenewOnRead is used and
+// returns the data with information about the item.
+func (c *Cache) found(key string, r result) ([]byte, ItemInfo, error) {
+	adapter := c.adapters[r.tier]
+	if c.renewOnRead > 0 {
+		var toucher Toucher
+		if as(adapter, &toucher) {
+			// the item was already read, so a failed
+			// renewal should not fail the Get.
+			toucher.Touch(key, c.renewOnRead)
+		}
+	}
+
+	info := ItemInfo{
+		Expires:  r.meta.Expires,
+		Created:  r.meta.Created,
+		Tier:     r.tier,
+		TierName: c.names[r.tier],
+		Size:     len(r.data),
+		Codec:    c.codecName(r.data),
+	}
+	return r.data, info, nil
+}
@@ -0,0 +1,226 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// slowAdapter answers after the delay unless the context is canceled.
+type slowAdapter struct {
+	*AdapterMock
+	delay time.Duration
+	value string
+	err   error
+
+	canceled chan struct{}
+}
+
+func newSlowAdapter(delay time.Duration, value string, err error) *slowAdapter {
+	return &slowAdapter{
+		AdapterMock: &AdapterMock{},
+		delay:       delay,
+		value:       value,
+		err:         err,
+		canceled:    make(chan struct{}, 1),
+	}
+}
+
+func (a *slowAdapter) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	select {
+	case <-time.After(a.delay):
+	case <-ctx.Done():
+		a.canceled <- struct{}{}
+		return nil, ctx.Err()
+	}
+
+	if a.err != nil {
+		return nil, a.err
+	}
+	return msgpack.Marshal(a.value)
+}
+
+func newStrategyCache(t *testing.T, strategy cache.ReadStrategy, hedgeDelay time.Duration, adapters ...*slowAdapter) *cache.Cache {
+	var inits []cache.InitAdapter
+	for _, a := range adapters {
+		a := a
+		inits = append(inits, func() (cache.Adapter, error) { return a, nil })
+	}
+	c, err := cache.NewWithOptions(
+		cache.WithAdapters(inits...),
+		cache.WithReadStrategy(strategy, hedgeDelay),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func expectCanceled(t *testing.T, a *slowAdapter) {
+	t.Helper()
+
+	select {
+	case <-a.canceled:
+	case <-time.After(time.Second):
+		t.Error("expected the losing call to be canceled")
+	}
+}
+
+func TestParallel_Priority(t *testing.T) {
+	first := newSlowAdapter(time.Millisecond*50, "first", nil)
+	second := newSlowAdapter(0, "second", nil)
+	c := newStrategyCache(t, cache.Parallel, 0, first, second)
+
+	var value string
+	info, err := c.GetWithMetadata("key", &value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "first" || info.Tier != 0 {
+		t.Errorf("expected the first adapter to win but got '%s' from %d", value, info.Tier)
+	}
+}
+
+func TestParallel_Miss(t *testing.T) {
+	first := newSlowAdapter(0, "", cache.ErrNotFound)
+	second := newSlowAdapter(time.Millisecond*10, "second", nil)
+	third := newSlowAdapter(time.Hour, "third", nil)
+	c := newStrategyCache(t, cache.Parallel, 0, first, second, third)
+
+	var value string
+	err := c.Get("key", &value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "second" {
+		t.Errorf("expected 'second' but got '%s'", value)
+	}
+	expectCanceled(t, third)
+}
+
+func TestParallel_Error(t *testing.T) {
+	e := errors.New("adapter failed")
+	first := newSlowAdapter(time.Millisecond*10, "", e)
+	second := newSlowAdapter(0, "second", nil)
+	c := newStrategyCache(t, cache.Parallel, 0, first, second)
+
+	var value string
+	err := c.Get("key", &value)
+	if err != e {
+		t.Errorf("expected the error of the first adapter but got %v", err)
+	}
+}
+
+func TestHedged_Delay(t *testing.T) {
+	first := newSlowAdapter(time.Hour, "first", nil)
+	second := newSlowAdapter(0, "second", nil)
+	c := newStrategyCache(t, cache.Hedged, time.Millisecond*10, first, second)
+
+	var value string
+	err := c.Get("key", &value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "second" {
+		t.Errorf("expected the backup read to win but got '%s'", value)
+	}
+	expectCanceled(t, first)
+}
+
+func TestHedged_Miss(t *testing.T) {
+	first := newSlowAdapter(0, "", cache.ErrNotFound)
+	second := newSlowAdapter(0, "second", nil)
+	c := newStrategyCache(t, cache.Hedged, time.Hour, first, second)
+
+	var value string
+	err := c.Get("key", &value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "second" {
+		t.Errorf("expected 'second' but got '%s'", value)
+	}
+}
+
+func TestHedged_NotFound(t *testing.T) {
+	e := errors.New("adapter failed")
+	c := newStrategyCache(t, cache.Hedged, 0,
+		newSlowAdapter(0, "", cache.ErrExpired),
+		newSlowAdapter(0, "", cache.ErrNotFound),
+	)
+
+	var value string
+	err := c.Get("key", &value)
+	if err != cache.ErrNotFound {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+
+	c = newStrategyCache(t, cache.Hedged, 0,
+		newSlowAdapter(0, "", cache.ErrNotFound),
+		newSlowAdapter(0, "", e),
+	)
+	err = c.Get("key", &value)
+	if err != e {
+		t.Errorf("expected the adapter error but got %v", err)
+	}
+}
+
+func TestGetContext_Canceled(t *testing.T) {
+	for _, strategy := range []cache.ReadStrategy{cache.Sequential, cache.Parallel, cache.Hedged} {
+		slow := newSlowAdapter(time.Hour, "value", nil)
+		c := newStrategyCache(t, strategy, 0, slow)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		var value string
+		err := c.GetContext(ctx, "key", &value)
+		cancel()
+		if err != context.DeadlineExceeded {
+			t.Errorf("strategy %d: expected DeadlineExceeded but got %v", strategy, err)
+		}
+	}
+}
+
+// slowMetadataAdapter also answers GetWithMetadata
+// after the delay unless the context is canceled.
+type slowMetadataAdapter struct {
+	*slowAdapter
+}
+
+func (a slowMetadataAdapter) GetWithMetadata(key string) ([]byte, cache.Metadata, error) {
+	return a.GetWithMetadataContext(context.Background(), key)
+}
+
+func (a slowMetadataAdapter) GetWithMetadataContext(ctx context.Context, key string) ([]byte, cache.Metadata, error) {
+	data, err := a.GetWithContext(ctx, key)
+	return data, cache.Metadata{Created: time.Now()}, err
+}
+
+func TestHedged_Metadata(t *testing.T) {
+	first := slowMetadataAdapter{newSlowAdapter(time.Hour, "first", nil)}
+	second := slowMetadataAdapter{newSlowAdapter(0, "second", nil)}
+	c, err := cache.NewWithOptions(
+		cache.WithAdapters(
+			func() (cache.Adapter, error) { return first, nil },
+			func() (cache.Adapter, error) { return second, nil },
+		),
+		cache.WithReadStrategy(cache.Hedged, time.Millisecond*10),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var value string
+	info, err := c.GetWithMetadata("key", &value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "second" || info.Created.IsZero() {
+		t.Errorf("expected the backup read with metadata but got '%s', %+v", value, info)
+	}
+	expectCanceled(t, first.slowAdapter)
+}
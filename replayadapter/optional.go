@@ -0,0 +1,310 @@
+package replayadapter
+
+import (
+	"time"
+
+	"github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// record passes the call to the adapter with fn, which fills in the
+// result, and writes the entry. The mutex is held for the whole call.
+func (r *Recorder) record(e Entry, fn func(e *Entry) error) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	err := fn(&e)
+	e.Err = fromError(err)
+
+	werr := r.write(e)
+	if werr != nil {
+		return werr
+	}
+	return err
+}
+
+func (r *Recorder) GetWithMetadata(key string) ([]byte, cache.Metadata, error) {
+	getter, ok := r.adapter.(cache.MetadataGetter)
+	if !ok {
+		return nil, cache.Metadata{}, cache.ErrUnsupported
+	}
+
+	var data []byte
+	var meta cache.Metadata
+	err := r.record(Entry{Op: OpGetWithMetadata, Key: key}, func(e *Entry) error {
+		var err error
+		data, meta, err = getter.GetWithMetadata(key)
+		if err == nil {
+			e.Value = data
+			e.Meta = &meta
+		}
+		return err
+	})
+	return data, meta, err
+}
+
+func (r *Recorder) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	setter, ok := r.adapter.(cache.TTLSetter)
+	if !ok {
+		return cache.ErrUnsupported
+	}
+	return r.record(Entry{Op: OpSetWithTTL, Key: key, Value: value, TTL: ttl}, func(*Entry) error {
+		return setter.SetWithTTL(key, value, ttl)
+	})
+}
+
+func (r *Recorder) Touch(key string, ttl time.Duration) error {
+	toucher, ok := r.adapter.(cache.Toucher)
+	if !ok {
+		return cache.ErrUnsupported
+	}
+	return r.record(Entry{Op: OpTouch, Key: key, TTL: ttl}, func(*Entry) error {
+		return toucher.Touch(key, ttl)
+	})
+}
+
+func (r *Recorder) Add(key string, value []byte) error {
+	adder, ok := r.adapter.(cache.Adder)
+	if !ok {
+		return cache.ErrUnsupported
+	}
+	return r.record(Entry{Op: OpAdd, Key: key, Value: value}, func(*Entry) error {
+		return adder.Add(key, value)
+	})
+}
+
+func (r *Recorder) CompareAndSwap(key string, old, new []byte) error {
+	swapper, ok := r.adapter.(cache.Swapper)
+	if !ok {
+		return cache.ErrUnsupported
+	}
+	return r.record(Entry{Op: OpCAS, Key: key, Old: old, Value: new}, func(*Entry) error {
+		return swapper.CompareAndSwap(key, old, new)
+	})
+}
+
+func (r *Recorder) Incr(key string, delta int64) (int64, error) {
+	incrementer, ok := r.adapter.(cache.Incrementer)
+	if !ok {
+		return 0, cache.ErrUnsupported
+	}
+
+	var n int64
+	err := r.record(Entry{Op: OpIncr, Key: key, Delta: delta}, func(e *Entry) error {
+		var err error
+		n, err = incrementer.Incr(key, delta)
+		e.N = n
+		return err
+	})
+	return n, err
+}
+
+func (r *Recorder) GetMulti(keys []string) (map[string][]byte, error) {
+	getter, ok := r.adapter.(cache.BatchGetter)
+	if !ok {
+		return nil, cache.ErrUnsupported
+	}
+
+	var result map[string][]byte
+	err := r.record(Entry{Op: OpGetMulti, Keys: keys}, func(e *Entry) error {
+		var err error
+		result, err = getter.GetMulti(keys)
+		e.Values = result
+		return err
+	})
+	return result, err
+}
+
+func (r *Recorder) SetMulti(items map[string][]byte) error {
+	setter, ok := r.adapter.(cache.BatchSetter)
+	if !ok {
+		return cache.ErrUnsupported
+	}
+	return r.record(Entry{Op: OpSetMulti, Values: items}, func(*Entry) error {
+		return setter.SetMulti(items)
+	})
+}
+
+func (r *Recorder) DelMulti(keys []string) error {
+	deleter, ok := r.adapter.(cache.BatchDeleter)
+	if !ok {
+		return cache.ErrUnsupported
+	}
+	return r.record(Entry{Op: OpDelMulti, Keys: keys}, func(*Entry) error {
+		return deleter.DelMulti(keys)
+	})
+}
+
+func (r *Recorder) Scan(opts cache.ScanOptions) ([]string, string, error) {
+	scanner, ok := r.adapter.(cache.Scanner)
+	if !ok {
+		return nil, "", cache.ErrUnsupported
+	}
+
+	var keys []string
+	var next string
+	err := r.record(Entry{Op: OpScan, Scan: &opts}, func(e *Entry) error {
+		var err error
+		keys, next, err = scanner.Scan(opts)
+		e.Keys = keys
+		e.Next = next
+		return err
+	})
+	return keys, next, err
+}
+
+func (r *Recorder) DelPrefix(prefix string) (int, error) {
+	deleter, ok := r.adapter.(cache.PrefixDeleter)
+	if !ok {
+		return 0, cache.ErrUnsupported
+	}
+
+	var count int
+	err := r.record(Entry{Op: OpDelPrefix, Key: prefix}, func(e *Entry) error {
+		var err error
+		count, err = deleter.DelPrefix(prefix)
+		e.N = int64(count)
+		return err
+	})
+	return count, err
+}
+
+func (r *Recorder) Clear() error {
+	clearer, ok := r.adapter.(cache.Clearer)
+	if !ok {
+		return cache.ErrUnsupported
+	}
+	return r.record(Entry{Op: OpClear}, func(*Entry) error {
+		return clearer.Clear()
+	})
+}
+
+// Dump records the records that were passed to fn.
+func (r *Recorder) Dump(fn func(rec cache.Record) error) error {
+	dumper, ok := r.adapter.(cache.Dumper)
+	if !ok {
+		return cache.ErrUnsupported
+	}
+	return r.record(Entry{Op: OpDump}, func(e *Entry) error {
+		return dumper.Dump(func(rec cache.Record) error {
+			e.Records = append(e.Records, rec)
+			return fn(rec)
+		})
+	})
+}
+
+// call replays the entry and returns the recorded
+// entry with the recorded error.
+func (r *Replayer) call(actual Entry) (Entry, error) {
+	e, err := r.replay(actual)
+	if err != nil {
+		return Entry{}, err
+	}
+	return e, toError(e.Err)
+}
+
+func (r *Replayer) GetWithMetadata(key string) ([]byte, cache.Metadata, error) {
+	e, err := r.call(Entry{Op: OpGetWithMetadata, Key: key})
+	if err != nil {
+		return nil, cache.Metadata{}, err
+	}
+
+	var meta cache.Metadata
+	if e.Meta != nil {
+		meta = *e.Meta
+	}
+	if e.Value == nil {
+		return []byte{}, meta, nil
+	}
+	return e.Value, meta, nil
+}
+
+func (r *Replayer) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	_, err := r.call(Entry{Op: OpSetWithTTL, Key: key, Value: value, TTL: ttl})
+	return err
+}
+
+func (r *Replayer) Touch(key string, ttl time.Duration) error {
+	_, err := r.call(Entry{Op: OpTouch, Key: key, TTL: ttl})
+	return err
+}
+
+func (r *Replayer) Add(key string, value []byte) error {
+	_, err := r.call(Entry{Op: OpAdd, Key: key, Value: value})
+	return err
+}
+
+func (r *Replayer) CompareAndSwap(key string, old, new []byte) error {
+	_, err := r.call(Entry{Op: OpCAS, Key: key, Old: old, Value: new})
+	return err
+}
+
+func (r *Replayer) Incr(key string, delta int64) (int64, error) {
+	e, err := r.call(Entry{Op: OpIncr, Key: key, Delta: delta})
+	if err != nil {
+		return 0, err
+	}
+	return e.N, nil
+}
+
+func (r *Replayer) GetMulti(keys []string) (map[string][]byte, error) {
+	e, err := r.call(Entry{Op: OpGetMulti, Keys: keys})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]byte, len(e.Values))
+	for key, value := range e.Values {
+		if value == nil {
+			value = []byte{}
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+func (r *Replayer) SetMulti(items map[string][]byte) error {
+	_, err := r.call(Entry{Op: OpSetMulti, Values: items})
+	return err
+}
+
+func (r *Replayer) DelMulti(keys []string) error {
+	_, err := r.call(Entry{Op: OpDelMulti, Keys: keys})
+	return err
+}
+
+func (r *Replayer) Scan(opts cache.ScanOptions) ([]string, string, error) {
+	e, err := r.call(Entry{Op: OpScan, Scan: &opts})
+	if err != nil {
+		return nil, "", err
+	}
+	return e.Keys, e.Next, nil
+}
+
+func (r *Replayer) DelPrefix(prefix string) (int, error) {
+	e, err := r.call(Entry{Op: OpDelPrefix, Key: prefix})
+	if err != nil {
+		return 0, err
+	}
+	return int(e.N), nil
+}
+
+func (r *Replayer) Clear() error {
+	_, err := r.call(Entry{Op: OpClear})
+	return err
+}
+
+// Dump passes the recorded records to fn.
+func (r *Replayer) Dump(fn func(rec cache.Record) error) error {
+	e, err := r.replay(Entry{Op: OpDump})
+	if err != nil {
+		return err
+	}
+
+	for _, rec := range e.Records {
+		err = fn(rec)
+		if err != nil {
+			return err
+		}
+	}
+	return toError(e.Err)
+}
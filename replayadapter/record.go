@@ -0,0 +1,302 @@
+// Package replayadapter records the calls to an adapter into a file
+// and replays them later without the adapter. A test can record once
+// against a real dynadapter and then run deterministically in CI:
+//
+//	init := replayadapter.Record(dynadapter.New(db, "Cache", ttl), "testdata/cache.jsonl")
+//	// ... and later
+//	init := replayadapter.Replay("testdata/cache.jsonl")
+//
+// The file has one JSON Entry per line. Replay compares every call with
+// the next entry, so the code under test has to make the same calls in
+// the same order, and calls from several goroutines need to happen in
+// a fixed order.
+//
+// Every optional interface of the cache is recorded and replayed except
+// io.Closer, Namer and the context of GetWithContext. The first line
+// lists the optional interfaces of the recorded adapter, so that the
+// cache uses the same ones during the replay and emulates the others
+// in the same way (see cache.Supporter).
+package replayadapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// operations of an Entry
+const (
+	OpGet             = "get"
+	OpSet             = "set"
+	OpDel             = "del"
+	OpGetWithMetadata = "getmeta"
+	OpSetWithTTL      = "setttl"
+	OpTouch           = "touch"
+	OpAdd             = "add"
+	OpCAS             = "cas"
+	OpIncr            = "incr"
+	OpGetMulti        = "getmulti"
+	OpSetMulti        = "setmulti"
+	OpDelMulti        = "delmulti"
+	OpScan            = "scan"
+	OpDelPrefix       = "delprefix"
+	OpClear           = "clear"
+	OpDump            = "dump"
+
+	// OpCapabilities is the first entry of the file and
+	// not a call. It lists the optional interfaces.
+	OpCapabilities = "capabilities"
+)
+
+// Entry is one call to the adapter. Only the fields
+// of the operation are set.
+type Entry struct {
+	Op  string `json:"op"`
+	Key string `json:"key"`
+	// Value is the value of Set, SetWithTTL, Add and the new
+	// value of CompareAndSwap or the result of Get.
+	Value []byte `json:"value,omitempty"`
+	// Old is the old value of CompareAndSwap.
+	Old []byte `json:"old,omitempty"`
+	// TTL is the ttl of SetWithTTL and Touch. It is not compared,
+	// because it is often computed from the current time.
+	TTL time.Duration `json:"ttl,omitempty"`
+	// Delta is the delta of Incr.
+	Delta int64 `json:"delta,omitempty"`
+	// Keys are the keys of GetMulti and DelMulti or the
+	// result of Scan.
+	Keys []string `json:"keys,omitempty"`
+	// Values are the items of SetMulti or the result of GetMulti.
+	Values map[string][]byte `json:"values,omitempty"`
+	// Scan are the options of Scan and Next is the cursor it returned.
+	Scan *cache.ScanOptions `json:"scan,omitempty"`
+	Next string             `json:"next,omitempty"`
+	// N is the result of Incr and DelPrefix.
+	N int64 `json:"n,omitempty"`
+	// Meta is the result of GetWithMetadata.
+	Meta *cache.Metadata `json:"meta,omitempty"`
+	// Records are the records that Dump passed on.
+	Records []cache.Record `json:"records,omitempty"`
+	// Capabilities are the names of the optional interfaces.
+	Capabilities []string `json:"capabilities,omitempty"`
+	// Err is the message of the error that was returned.
+	Err string `json:"err,omitempty"`
+}
+
+func (e Entry) String() string {
+	switch e.Op {
+	case OpSet, OpSetWithTTL, OpAdd:
+		return fmt.Sprintf("%s %q %q", e.Op, e.Key, e.Value)
+	case OpCAS:
+		return fmt.Sprintf("%s %q %q %q", e.Op, e.Key, e.Old, e.Value)
+	case OpIncr:
+		return fmt.Sprintf("%s %q %d", e.Op, e.Key, e.Delta)
+	case OpGetMulti, OpDelMulti:
+		return fmt.Sprintf("%s %q", e.Op, e.Keys)
+	case OpSetMulti:
+		return fmt.Sprintf("%s %q", e.Op, sortedKeys(e.Values))
+	case OpScan:
+		return fmt.Sprintf("%s %+v", e.Op, *e.Scan)
+	}
+	return fmt.Sprintf("%s %q", e.Op, e.Key)
+}
+
+func sortedKeys(values map[string][]byte) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// capabilities are the optional interfaces that are recorded.
+var capabilities = []interface{}{
+	(*cache.Adder)(nil),
+	(*cache.Swapper)(nil),
+	(*cache.TTLSetter)(nil),
+	(*cache.Toucher)(nil),
+	(*cache.BatchGetter)(nil),
+	(*cache.BatchSetter)(nil),
+	(*cache.BatchDeleter)(nil),
+	(*cache.Incrementer)(nil),
+	(*cache.Scanner)(nil),
+	(*cache.PrefixDeleter)(nil),
+	(*cache.Clearer)(nil),
+	(*cache.MetadataGetter)(nil),
+	(*cache.Dumper)(nil),
+}
+
+// capabilityName returns the name of the optional interface.
+func capabilityName(capability interface{}) string {
+	return reflect.TypeOf(capability).Elem().Name()
+}
+
+// knownErrors are replayed as the same values,
+// so that they can be compared with ==.
+var knownErrors = []error{
+	cache.ErrNotFound,
+	cache.ErrExpired,
+	cache.ErrAlreadyExists,
+	cache.ErrConflict,
+	cache.ErrUnsupported,
+	cache.ErrNotCounter,
+	context.Canceled,
+	context.DeadlineExceeded,
+}
+
+func toError(msg string) error {
+	if msg == "" {
+		return nil
+	}
+	for _, err := range knownErrors {
+		if err.Error() == msg {
+			return err
+		}
+	}
+	return errors.New(msg)
+}
+
+func fromError(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Recorder passes every call to the adapter and
+// writes it with the result to the file.
+type Recorder struct {
+	adapter cache.Adapter
+
+	m    sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// Record returns an adapter that records the calls to the adapter
+// in the file at path. An existing file is overwritten. Close the
+// cache (or the Recorder) to close the file.
+func Record(init cache.InitAdapter, path string) cache.InitAdapter {
+	return func() (cache.Adapter, error) {
+		adapter, err := init()
+		if err != nil {
+			return nil, err
+		}
+
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		r := &Recorder{
+			adapter: adapter,
+			file:    file,
+			enc:     json.NewEncoder(file),
+		}
+
+		header := Entry{Op: OpCapabilities}
+		for _, capability := range capabilities {
+			if cache.Implements(adapter, capability) {
+				header.Capabilities = append(header.Capabilities, capabilityName(capability))
+			}
+		}
+		err = r.write(header)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return r, nil
+	}
+}
+
+// Supports only reports the optional interfaces
+// that the recorded adapter implements.
+func (r *Recorder) Supports(capability interface{}) bool {
+	return cache.Implements(r.adapter, capability)
+}
+
+// write appends the entry to the file. The mutex is held for the
+// whole call, so the order of the file is the order of the calls.
+func (r *Recorder) write(e Entry) error {
+	if r.file == nil {
+		return errors.New("recorder is closed")
+	}
+	return r.enc.Encode(e)
+}
+
+func (r *Recorder) Get(key string) ([]byte, error) {
+	return r.GetWithContext(context.Background(), key)
+}
+
+func (r *Recorder) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var data []byte
+	var err error
+	if getter, ok := r.adapter.(cache.ContextGetter); ok {
+		data, err = getter.GetWithContext(ctx, key)
+	} else {
+		data, err = r.adapter.Get(key)
+	}
+
+	werr := r.write(Entry{Op: OpGet, Key: key, Value: data, Err: fromError(err)})
+	if werr != nil {
+		return nil, werr
+	}
+	return data, err
+}
+
+func (r *Recorder) Set(key string, value []byte) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	err := r.adapter.Set(key, value)
+
+	werr := r.write(Entry{Op: OpSet, Key: key, Value: value, Err: fromError(err)})
+	if werr != nil {
+		return werr
+	}
+	return err
+}
+
+func (r *Recorder) Del(key string) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	err := r.adapter.Del(key)
+
+	werr := r.write(Entry{Op: OpDel, Key: key, Err: fromError(err)})
+	if werr != nil {
+		return werr
+	}
+	return err
+}
+
+// Close closes the file and the adapter, if it implements io.Closer.
+func (r *Recorder) Close() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+
+	if closer, ok := r.adapter.(io.Closer); ok {
+		if cerr := closer.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
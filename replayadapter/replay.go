@@ -0,0 +1,203 @@
+package replayadapter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// DivergenceError is returned by the Replayer if a call
+// is different from the recorded one.
+type DivergenceError struct {
+	// Index is the position of the call, starting at 0.
+	Index int
+	// Expected is the recorded entry, or nil if
+	// there are no more entries.
+	Expected *Entry
+	Actual   Entry
+}
+
+func (e *DivergenceError) Error() string {
+	if e.Expected == nil {
+		return fmt.Sprintf("replay diverged at call %d: got %s but the recording has ended", e.Index, e.Actual)
+	}
+	return fmt.Sprintf("replay diverged at call %d: expected %s but got %s", e.Index, e.Expected, e.Actual)
+}
+
+// Replayer answers the calls with the recorded results.
+type Replayer struct {
+	m            sync.Mutex
+	capabilities map[string]bool
+	entries      []Entry
+	next         int
+	// diverged is the first divergence. After it every
+	// call fails, so that the error can't be missed.
+	diverged *DivergenceError
+}
+
+// Replay returns an adapter that answers every call with
+// the next entry of the file that was written by Record.
+// If the operation or an argument (except the ttl) is different,
+// a *DivergenceError is returned for that and every later call.
+func Replay(path string) cache.InitAdapter {
+	return func() (cache.Adapter, error) {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		r := &Replayer{capabilities: make(map[string]bool)}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(nil, 64*1024*1024)
+		for scanner.Scan() {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+
+			var e Entry
+			err := json.Unmarshal(scanner.Bytes(), &e)
+			if err != nil {
+				return nil, fmt.Errorf("%s line %d: %v", path, len(r.entries)+1, err)
+			}
+			if e.Op == OpCapabilities {
+				for _, name := range e.Capabilities {
+					r.capabilities[name] = true
+				}
+				continue
+			}
+			r.entries = append(r.entries, e)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+}
+
+// Supports reports the optional interfaces of the recorded adapter.
+// Recordings without the first line only have Get, Set and Del.
+func (r *Replayer) Supports(capability interface{}) bool {
+	return r.capabilities[capabilityName(capability)]
+}
+
+// sameCall reports whether the entries are calls of the same
+// operation with the same arguments. The ttl is not compared.
+func sameCall(expected, actual Entry) bool {
+	if expected.Op != actual.Op || expected.Key != actual.Key ||
+		expected.Delta != actual.Delta || !bytes.Equal(expected.Old, actual.Old) {
+		return false
+	}
+
+	switch actual.Op {
+	case OpSet, OpSetWithTTL, OpAdd, OpCAS:
+		return bytes.Equal(expected.Value, actual.Value)
+	case OpGetMulti, OpDelMulti:
+		return sameKeys(expected.Keys, actual.Keys)
+	case OpSetMulti:
+		return sameValues(expected.Values, actual.Values)
+	case OpScan:
+		return expected.Scan != nil && *expected.Scan == *actual.Scan
+	}
+	return true
+}
+
+func sameKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameValues(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		other, ok := b[key]
+		if !ok || !bytes.Equal(value, other) {
+			return false
+		}
+	}
+	return true
+}
+
+// replay returns the next entry if it matches the call.
+func (r *Replayer) replay(actual Entry) (Entry, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.diverged != nil {
+		return Entry{}, r.diverged
+	}
+
+	index := r.next
+	r.next++
+	if index >= len(r.entries) {
+		r.diverged = &DivergenceError{Index: index, Actual: actual}
+		return Entry{}, r.diverged
+	}
+
+	expected := r.entries[index]
+	if !sameCall(expected, actual) {
+		r.diverged = &DivergenceError{Index: index, Expected: &expected, Actual: actual}
+		return Entry{}, r.diverged
+	}
+	return expected, nil
+}
+
+func (r *Replayer) Get(key string) ([]byte, error) {
+	e, err := r.replay(Entry{Op: OpGet, Key: key})
+	if err != nil {
+		return nil, err
+	}
+	err = toError(e.Err)
+	if err != nil {
+		return nil, err
+	}
+	if e.Value == nil {
+		return []byte{}, nil
+	}
+	return e.Value, nil
+}
+
+func (r *Replayer) Set(key string, value []byte) error {
+	e, err := r.replay(Entry{Op: OpSet, Key: key, Value: value})
+	if err != nil {
+		return err
+	}
+	return toError(e.Err)
+}
+
+func (r *Replayer) Del(key string) error {
+	e, err := r.replay(Entry{Op: OpDel, Key: key})
+	if err != nil {
+		return err
+	}
+	return toError(e.Err)
+}
+
+// Close returns the divergence, if there was one, or an error
+// if not every recorded call was replayed.
+func (r *Replayer) Close() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.diverged != nil {
+		return r.diverged
+	}
+	if r.next < len(r.entries) {
+		return fmt.Errorf("replay ended after %d of %d calls, next is %s", r.next, len(r.entries), r.entries[r.next])
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package replayadapter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/JohannesKaufmann/dynamodb-cache/dynadapter"
+	"github.com/JohannesKaufmann/dynamodb-cache/dynadapter/dynafake"
+	"github.com/JohannesKaufmann/dynamodb-cache/memadapter"
+)
+
+// scenario are the calls of the code under test.
+func scenario(t *testing.T, c *cache.Cache) {
+	var value string
+	err := c.Get("user:1", &value)
+	if err != cache.ErrNotFound {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+
+	err = c.Set("user:1", "Johannes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Get("user:1", &value)
+	if err != nil || value != "Johannes" {
+		t.Errorf("expected the value but got %q, %v", value, err)
+	}
+
+	err = c.Del("user:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache.jsonl")
+
+	db := dynafake.New()
+	db.AddTable("Cache", "Key")
+	c, err := cache.New(Record(dynadapter.New(db, "Cache", time.Hour), path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scenario(t, c)
+	err = c.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = cache.New(Replay(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scenario(t, c)
+	err = c.Close()
+	if err != nil {
+		t.Errorf("expected every call to be replayed but got %v", err)
+	}
+}
+
+func TestDivergence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache.jsonl")
+
+	recorder, err := Record(memadapter.New(time.Hour, false), path)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.Set("a", []byte("1"))
+	recorder.Get("a")
+	recorder.(*Recorder).Close()
+
+	replay := func() cache.Adapter {
+		replayer, err := Replay(path)()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return replayer
+	}
+
+	// a different value
+	r := replay()
+	err = r.Set("a", []byte("2"))
+	if e, ok := err.(*DivergenceError); !ok || e.Index != 0 || e.Expected.Op != OpSet {
+		t.Errorf("expected a DivergenceError but got %v", err)
+	}
+	// every later call fails as well
+	if _, err := r.Get("a"); err == nil {
+		t.Error("expected an error after the divergence")
+	}
+
+	// a different key
+	r = replay()
+	r.Set("a", []byte("1"))
+	_, err = r.Get("b")
+	if e, ok := err.(*DivergenceError); !ok || e.Index != 1 {
+		t.Errorf("expected a DivergenceError but got %v", err)
+	}
+
+	// too many calls
+	r = replay()
+	r.Set("a", []byte("1"))
+	r.Get("a")
+	err = r.Del("a")
+	if e, ok := err.(*DivergenceError); !ok || e.Expected != nil {
+		t.Errorf("expected a DivergenceError but got %v", err)
+	}
+
+	// too few calls
+	r = replay()
+	r.Set("a", []byte("1"))
+	if err := r.(*Replayer).Close(); err == nil {
+		t.Error("expected an error for the missing call")
+	}
+}
+
+// optionalScenario uses the optional interfaces of the adapter.
+func optionalScenario(t *testing.T, c *cache.Cache) {
+	if err := c.Add("a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Add("a", "1"); err != cache.ErrAlreadyExists {
+		t.Errorf("expected ErrAlreadyExists but got %v", err)
+	}
+	if err := c.CompareAndSwap("a", "1", "2"); err != nil {
+		t.Error(err)
+	}
+	if n, err := c.Incr("n", 5); err != nil || n != 5 {
+		t.Errorf("expected 5 but got %d, %v", n, err)
+	}
+
+	err := c.SetMulti(map[string]interface{}{"user:1": "a", "user:2": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]string)
+	err = c.GetMulti([]string{"user:1", "user:3"}, &values)
+	if err != nil || len(values) != 1 || values["user:1"] != "a" {
+		t.Errorf("expected the value of user:1 but got %v, %v", values, err)
+	}
+	if err := c.Touch("user:1", time.Minute); err != nil {
+		t.Error(err)
+	}
+	var value string
+	info, err := c.GetWithMetadata("user:1", &value)
+	if err != nil || value != "a" || info.Expires.IsZero() {
+		t.Errorf("expected the value with metadata but got %q, %+v, %v", value, info, err)
+	}
+	keys, err := c.Keys("user:")
+	if err != nil || len(keys) != 2 {
+		t.Errorf("expected 2 keys but got %v, %v", keys, err)
+	}
+
+	// the ttl of Import depends on the time and is not compared
+	var buf bytes.Buffer
+	if err := c.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Import(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if count, err := c.DelPrefix("user:"); err != nil || count != 2 {
+		t.Errorf("expected 2 deleted items but got %d, %v", count, err)
+	}
+	if err := c.Clear(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRecordReplay_Optional(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache.jsonl")
+
+	c, err := cache.New(Record(memadapter.New(time.Hour, false), path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	optionalScenario(t, c)
+	err = c.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = cache.New(Replay(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	optionalScenario(t, c)
+	err = c.Close()
+	if err != nil {
+		t.Errorf("expected every call to be replayed but got %v", err)
+	}
+}
+
+// plain hides the optional interfaces of the adapter.
+type plain struct {
+	cache.Adapter
+}
+
+func TestReplay_Capabilities(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache.jsonl")
+
+	init := func() (cache.Adapter, error) {
+		mem, err := memadapter.New(time.Hour, false)()
+		return plain{mem}, err
+	}
+	c, err := cache.New(Record(init, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Add("a", "1"); err != cache.ErrUnsupported {
+		t.Errorf("expected ErrUnsupported but got %v", err)
+	}
+	// emulated with Set
+	if err := c.SetMulti(map[string]interface{}{"a": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	c, err = cache.New(Replay(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Add("a", "1"); err != cache.ErrUnsupported {
+		t.Errorf("expected ErrUnsupported but got %v", err)
+	}
+	if err := c.SetMulti(map[string]interface{}{"a": "1"}); err != nil {
+		t.Error(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("expected every call to be replayed but got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package sessions
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net/http"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+	gsessions "github.com/gorilla/sessions"
+)
+
+// GorillaStore implements the Store interface of github.com/gorilla/sessions.
+//
+// The cookie only contains the random session ID, the values are
+// saved in the cache (encoded with encoding/gob, so custom types
+// need to be registered with gob.Register). The sessions are only kept
+// in the last adapter of the cache (see cache.Cache.Last), so that a
+// deleted session is gone on every instance. When a session is loaded
+// and less than half of Options.MaxAge is left, its expiry is moved
+// by Options.MaxAge, with Touch if the adapter supports a TTL per item.
+type GorillaStore struct {
+	c *cache.Cache
+
+	// Options are the default options for new sessions.
+	Options *gsessions.Options
+}
+
+var _ gsessions.Store = &GorillaStore{}
+
+// NewGorillaStore returns a store that saves the sessions in c.
+// The sessions expire after 30 days without access.
+func NewGorillaStore(c *cache.Cache) *GorillaStore {
+	return &GorillaStore{
+		c: c.Last(),
+		Options: &gsessions.Options{
+			Path:     "/",
+			MaxAge:   86400 * 30,
+			HttpOnly: true,
+		},
+	}
+}
+
+// Get returns the session with that name for the request. It is only
+// loaded once per request, even if Get is called multiple times.
+func (s *GorillaStore) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session with that name. If the request has no
+// valid session a new session is returned with IsNew set to true.
+func (s *GorillaStore) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+
+	rec, found, err := load(s.c, cookie.Value)
+	if err != nil || !found {
+		return session, err
+	}
+
+	// roll the expiry, so that active sessions don't expire
+	err = touch(s.c, cookie.Value, rec, s.expires(session))
+	if err == cache.ErrNotFound {
+		return session, nil
+	} else if err != nil {
+		return session, err
+	}
+
+	err = gob.NewDecoder(bytes.NewReader(rec.Data)).Decode(&session.Values)
+	if err != nil {
+		return session, err
+	}
+	session.ID = cookie.Value
+	session.IsNew = false
+	return session, nil
+}
+
+// expires returns when the session expires in the cache. A MaxAge of
+// zero (a cookie that is deleted when the browser is closed) is kept
+// for a day after the last access.
+func (s *GorillaStore) expires(session *gsessions.Session) time.Time {
+	maxAge := session.Options.MaxAge
+	if maxAge == 0 {
+		maxAge = 86400
+	}
+	return time.Now().Add(time.Duration(maxAge) * time.Second)
+}
+
+// Save writes the session to the cache and sets the cookie. If
+// Options.MaxAge is below zero the session is deleted instead.
+func (s *GorillaStore) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			err := del(s.c, session.ID)
+			if err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, gsessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		session.ID = id
+	}
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(session.Values)
+	if err != nil {
+		return err
+	}
+	err = save(s.c, session.ID, buf.Bytes(), s.expires(session))
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), session.ID, session.Options))
+	return nil
+}
+
+// Regenerate deletes the session and saves the values under a new
+// session ID. It should be called after the login to prevent
+// session fixation.
+func (s *GorillaStore) Regenerate(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	if session.ID != "" {
+		err := del(s.c, session.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	session.ID = ""
+	return s.Save(r, w, session)
+}
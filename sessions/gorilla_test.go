@@ -0,0 +1,255 @@
+package sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/JohannesKaufmann/dynamodb-cache/memadapter"
+)
+
+func newCache(t *testing.T) *cache.Cache {
+	c, err := cache.New(memadapter.New(time.Hour, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func request(cookie *http.Cookie) *http.Request {
+	r := httptest.NewRequest("GET", "/", nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	return r
+}
+
+func cookie(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected one cookie but got %d", len(cookies))
+	}
+	return cookies[0]
+}
+
+func TestGorillaStore(t *testing.T) {
+	s := NewGorillaStore(newCache(t))
+
+	session, err := s.Get(request(nil), "session")
+	if err != nil {
+		t.Error(err)
+	}
+	if !session.IsNew {
+		t.Error("expected a new session")
+	}
+	session.Values["user"] = "john"
+
+	rec := httptest.NewRecorder()
+	err = s.Save(nil, rec, session)
+	if err != nil {
+		t.Error(err)
+	}
+	c := cookie(t, rec)
+	if c.Value != session.ID || len(c.Value) != 43 {
+		t.Errorf("unexpected session ID: %s", c.Value)
+	}
+
+	session, err = s.Get(request(c), "session")
+	if err != nil {
+		t.Error(err)
+	}
+	if session.IsNew {
+		t.Error("expected an existing session")
+	}
+	if session.Values["user"] != "john" {
+		t.Error("got different value")
+	}
+}
+
+func TestGorillaStore_Expired(t *testing.T) {
+	c := newCache(t)
+	s := NewGorillaStore(c)
+
+	err := save(c, "abc", nil, time.Now().Add(-time.Second))
+	if err != nil {
+		t.Error(err)
+	}
+
+	session, err := s.New(request(&http.Cookie{Name: "session", Value: "abc"}), "session")
+	if err != nil {
+		t.Error(err)
+	}
+	if !session.IsNew || session.ID != "" {
+		t.Error("expected a new session")
+	}
+}
+
+func TestGorillaStore_Regenerate(t *testing.T) {
+	s := NewGorillaStore(newCache(t))
+
+	session, _ := s.New(request(nil), "session")
+	session.Values["user"] = "john"
+	rec := httptest.NewRecorder()
+	err := s.Save(nil, rec, session)
+	if err != nil {
+		t.Error(err)
+	}
+	old := cookie(t, rec)
+
+	rec = httptest.NewRecorder()
+	err = s.Regenerate(nil, rec, session)
+	if err != nil {
+		t.Error(err)
+	}
+	regenerated := cookie(t, rec)
+	if regenerated.Value == old.Value {
+		t.Error("expected a different session ID")
+	}
+
+	session, _ = s.New(request(old), "session")
+	if !session.IsNew {
+		t.Error("old session ID is still valid")
+	}
+	session, _ = s.New(request(regenerated), "session")
+	if session.Values["user"] != "john" {
+		t.Error("values got lost")
+	}
+}
+
+func TestGorillaStore_Delete(t *testing.T) {
+	s := NewGorillaStore(newCache(t))
+
+	session, _ := s.New(request(nil), "session")
+	rec := httptest.NewRecorder()
+	s.Save(nil, rec, session)
+	c := cookie(t, rec)
+
+	session.Options.MaxAge = -1
+	rec = httptest.NewRecorder()
+	err := s.Save(nil, rec, session)
+	if err != nil {
+		t.Error(err)
+	}
+	if cookie(t, rec).MaxAge >= 0 {
+		t.Error("expected cookie to be deleted")
+	}
+
+	session, _ = s.New(request(c), "session")
+	if !session.IsNew {
+		t.Error("session was not deleted")
+	}
+}
+
+func TestGorillaStore_MaxAge(t *testing.T) {
+	c := newCache(t)
+	s := NewGorillaStore(c)
+	s.Options.MaxAge = 60
+
+	session, _ := s.New(request(nil), "session")
+	rec := httptest.NewRecorder()
+	err := s.Save(nil, rec, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var r record
+	info, err := c.GetWithMetadata(KeyPrefix+session.ID, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Expires != 0 {
+		t.Error("expected the expiry to be left to the adapter")
+	}
+	if remaining := time.Until(info.Expires); remaining > time.Minute || remaining < 59*time.Second {
+		t.Errorf("expected the ttl of MaxAge but got %s", remaining)
+	}
+
+	session, err = s.New(request(cookie(t, rec)), "session")
+	if err != nil || session.IsNew {
+		t.Errorf("expected the existing session but got %v", err)
+	}
+}
+
+func TestGorillaStore_Last(t *testing.T) {
+	upper := memadapter.New(time.Hour, false)
+	shared, err := memadapter.New(time.Hour, false)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newStore := func() (*GorillaStore, cache.Adapter) {
+		mem, err := upper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := cache.New(
+			func() (cache.Adapter, error) { return mem, nil },
+			func() (cache.Adapter, error) { return shared, nil },
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewGorillaStore(c), mem
+	}
+	a, mem := newStore()
+	b, _ := newStore()
+
+	session, _ := a.New(request(nil), "session")
+	rec := httptest.NewRecorder()
+	if err := a.Save(nil, rec, session); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.Get(KeyPrefix + session.ID); err != cache.ErrNotFound {
+		t.Errorf("expected no local copy but got %v", err)
+	}
+
+	// a logout on the other instance
+	session, _ = b.New(request(cookie(t, rec)), "session")
+	session.Options.MaxAge = -1
+	if err := b.Save(nil, httptest.NewRecorder(), session); err != nil {
+		t.Fatal(err)
+	}
+	session, _ = a.New(request(cookie(t, rec)), "session")
+	if !session.IsNew {
+		t.Error("expected the session to be deleted on every instance")
+	}
+}
+
+func TestGorillaStore_TouchHalf(t *testing.T) {
+	c := newCache(t)
+	s := NewGorillaStore(c)
+	s.Options.MaxAge = 60
+
+	session, _ := s.New(request(nil), "session")
+	rec := httptest.NewRecorder()
+	if err := s.Save(nil, rec, session); err != nil {
+		t.Fatal(err)
+	}
+	remaining := func() time.Duration {
+		var r record
+		info, err := c.GetWithMetadata(KeyPrefix+session.ID, &r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return time.Until(info.Expires)
+	}
+
+	// less than half of the MaxAge is left
+	s.Options.MaxAge = 3600
+	if _, err := s.New(request(cookie(t, rec)), "session"); err != nil {
+		t.Fatal(err)
+	}
+	if r := remaining(); r < 59*time.Minute {
+		t.Errorf("expected the session to be touched but %s are left", r)
+	}
+
+	// more than half of the MaxAge is left
+	s.Options.MaxAge = 7000
+	if _, err := s.New(request(cookie(t, rec)), "session"); err != nil {
+		t.Fatal(err)
+	}
+	if r := remaining(); r > time.Hour {
+		t.Errorf("expected the session not to be touched but %s are left", r)
+	}
+}
@@ -0,0 +1,42 @@
+package sessions
+
+import (
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// ScsStore implements the Store interface of github.com/alexedwards/scs.
+//
+// scs generates the tokens, handles the cookies and renews the token
+// with RenewToken, so the store only saves the data. To roll the
+// expiry on access set the IdleTimeout of the session manager. Like
+// GorillaStore it only uses the last adapter of the cache.
+type ScsStore struct {
+	c *cache.Cache
+}
+
+// NewScsStore returns a store that saves the sessions in c.
+func NewScsStore(c *cache.Cache) *ScsStore {
+	return &ScsStore{c: c.Last()}
+}
+
+// Find returns the data for the session token. If the session
+// does not exist or is expired found is false.
+func (s *ScsStore) Find(token string) ([]byte, bool, error) {
+	rec, found, err := load(s.c, token)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	return rec.Data, true, nil
+}
+
+// Commit saves the data for the session token until expiry.
+func (s *ScsStore) Commit(token string, b []byte, expiry time.Time) error {
+	return save(s.c, token, b, expiry)
+}
+
+// Delete removes the session token.
+func (s *ScsStore) Delete(token string) error {
+	return del(s.c, token)
+}
@@ -0,0 +1,42 @@
+package sessions
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestScsStore(t *testing.T) {
+	s := NewScsStore(newCache(t))
+
+	_, found, err := s.Find("abc")
+	if err != nil || found {
+		t.Error("expected not to find the session")
+	}
+
+	err = s.Commit("abc", []byte("data"), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Error(err)
+	}
+	data, found, err := s.Find("abc")
+	if err != nil || !found {
+		t.Error("expected to find the session")
+	}
+	if !bytes.Equal(data, []byte("data")) {
+		t.Error("got different data")
+	}
+
+	err = s.Commit("abc", []byte("data"), time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Error(err)
+	}
+	_, found, _ = s.Find("abc")
+	if found {
+		t.Error("expected session to be expired")
+	}
+
+	err = s.Delete("abc")
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,106 @@
+// Package sessions stores HTTP sessions in a cache. It provides
+// a store for github.com/gorilla/sessions and one for
+// github.com/alexedwards/scs.
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// KeyPrefix is put in front of the session ID to get
+// the key of the session in the cache.
+const KeyPrefix = "session:"
+
+// record is what is saved in the cache. Every session can have
+// its own expiry, so it is saved with the TTL of the adapters if
+// they support SetWithTTL and in the record otherwise. Expires is
+// zero if the expiry is left to the adapters.
+type record struct {
+	Data    []byte
+	Expires int64
+
+	// expires is when the session expires, set by load. It
+	// is zero if the adapter does not know the expiry.
+	expires time.Time
+}
+
+func (r record) isExpired(now time.Time) bool {
+	return r.Expires != 0 && now.UnixNano() > r.Expires
+}
+
+// newID returns a random session ID with 256 bits of entropy.
+func newID() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// load gets the record for that session ID. If the session does
+// not exist or is expired found is false.
+func load(c *cache.Cache, id string) (rec record, found bool, err error) {
+	info, err := c.GetWithMetadata(KeyPrefix+id, &rec)
+	if err == cache.ErrNotFound || err == cache.ErrExpired {
+		return rec, false, nil
+	} else if err != nil {
+		return rec, false, err
+	}
+
+	if rec.isExpired(time.Now()) {
+		return rec, false, nil
+	}
+	rec.expires = info.Expires
+	if rec.Expires != 0 {
+		rec.expires = time.Unix(0, rec.Expires)
+	}
+	return rec, true, nil
+}
+
+// save writes the session with the expiry. A session
+// that is already expired is deleted instead.
+func save(c *cache.Cache, id string, data []byte, expires time.Time) error {
+	ttl := time.Until(expires)
+	if ttl <= 0 {
+		return del(c, id)
+	}
+
+	err := c.SetWithTTL(KeyPrefix+id, record{Data: data}, ttl)
+	if err != cache.ErrUnsupported {
+		return err
+	}
+	rec := record{
+		Data:    data,
+		Expires: expires.UnixNano(),
+	}
+	return c.Set(KeyPrefix+id, rec)
+}
+
+// touch moves the expiry of the session, but only once less than half
+// of the time until the new expiry is left, so that not every request
+// writes to the cache. Only the TTL is changed if the adapters keep
+// the expiry, otherwise the record is saved again. It returns
+// cache.ErrNotFound if the session was deleted since it was loaded.
+func touch(c *cache.Cache, id string, rec record, expires time.Time) error {
+	now := time.Now()
+	if !rec.expires.IsZero() && rec.expires.Sub(now) > expires.Sub(now)/2 {
+		return nil
+	}
+
+	if rec.Expires == 0 {
+		err := c.Touch(KeyPrefix+id, time.Until(expires))
+		if err != cache.ErrUnsupported {
+			return err
+		}
+	}
+	return save(c, id, rec.Data, expires)
+}
+
+func del(c *cache.Cache, id string) error {
+	return c.Del(KeyPrefix + id)
+}
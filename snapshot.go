@@ -0,0 +1,278 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// A snapshot is a stream of records that is used by
+// Export and Import. It has the following format:
+//
+//	header: "DYNCACHE"  magic (8 bytes)
+//	        version     1 byte (currently 2)
+//	        written at  int64 big endian, unix nanoseconds
+//	record: key length  uvarint
+//	        key         bytes
+//	        ttl         varint, nanoseconds (0 = no expiry)
+//	        data length uvarint
+//	        data        bytes
+//
+// The records follow the header until the end of the stream. The ttl
+// is what was left of the item when the snapshot was written. It is
+// computed with the monotonic clock if both the expiry of the record
+// and the time of NewSnapshotWriter have a monotonic reading, so that
+// a step of the wall clock doesn't change it. Version 1 had the expiry
+// in unix nanoseconds instead of the ttl and can still be read.
+const snapshotMagic = "DYNCACHE"
+const snapshotVersion = 2
+
+// ErrInvalidSnapshot is returned if a snapshot can not be read.
+var ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+// Record is one item in a snapshot.
+type Record struct {
+	Key string
+	// Expires is zero if the item does not expire.
+	Expires time.Time
+	Data    []byte
+}
+
+// SnapshotWriter writes records in the snapshot format.
+type SnapshotWriter struct {
+	w         *bufio.Writer
+	writtenAt time.Time
+	buf       [binary.MaxVarintLen64]byte
+}
+
+// NewSnapshotWriter writes the header to w. Call Flush
+// after the last record.
+func NewSnapshotWriter(w io.Writer, writtenAt time.Time) (*SnapshotWriter, error) {
+	sw := &SnapshotWriter{w: bufio.NewWriter(w), writtenAt: writtenAt}
+
+	header := make([]byte, len(snapshotMagic)+1+8)
+	copy(header, snapshotMagic)
+	header[len(snapshotMagic)] = snapshotVersion
+	binary.BigEndian.PutUint64(header[len(snapshotMagic)+1:], uint64(writtenAt.UnixNano()))
+
+	_, err := sw.w.Write(header)
+	if err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+func (sw *SnapshotWriter) bytes(b []byte) error {
+	n := binary.PutUvarint(sw.buf[:], uint64(len(b)))
+	_, err := sw.w.Write(sw.buf[:n])
+	if err != nil {
+		return err
+	}
+	_, err = sw.w.Write(b)
+	return err
+}
+
+// Write writes one record.
+func (sw *SnapshotWriter) Write(rec Record) error {
+	err := sw.bytes([]byte(rec.Key))
+	if err != nil {
+		return err
+	}
+
+	var ttl time.Duration
+	if !rec.Expires.IsZero() {
+		ttl = rec.Expires.Sub(sw.writtenAt)
+		if ttl <= 0 {
+			// already expired, but zero means no expiry
+			ttl = -1
+		}
+	}
+	n := binary.PutVarint(sw.buf[:], int64(ttl))
+	_, err = sw.w.Write(sw.buf[:n])
+	if err != nil {
+		return err
+	}
+
+	return sw.bytes(rec.Data)
+}
+
+// Flush writes the buffered records to the underlying writer.
+func (sw *SnapshotWriter) Flush() error {
+	return sw.w.Flush()
+}
+
+// SnapshotReader reads records in the snapshot format.
+type SnapshotReader struct {
+	r         *bufio.Reader
+	version   byte
+	writtenAt time.Time
+}
+
+// NewSnapshotReader reads the header from r.
+func NewSnapshotReader(r io.Reader) (*SnapshotReader, error) {
+	sr := &SnapshotReader{r: bufio.NewReader(r)}
+
+	header := make([]byte, len(snapshotMagic)+1+8)
+	_, err := io.ReadFull(sr.r, header)
+	if err != nil {
+		return nil, ErrInvalidSnapshot
+	}
+	sr.version = header[len(snapshotMagic)]
+	if string(header[:len(snapshotMagic)]) != snapshotMagic || sr.version < 1 || sr.version > snapshotVersion {
+		return nil, ErrInvalidSnapshot
+	}
+	nanos := int64(binary.BigEndian.Uint64(header[len(snapshotMagic)+1:]))
+	sr.writtenAt = time.Unix(0, nanos)
+
+	return sr, nil
+}
+
+// WrittenAt returns when the snapshot was written.
+func (sr *SnapshotReader) WrittenAt() time.Time {
+	return sr.writtenAt
+}
+
+// maxSnapshotField is the largest key or data that a snapshot can
+// contain. Larger lengths can only come from a corrupt snapshot.
+const maxSnapshotField = 1 << 30
+
+// smallSnapshotField is allocated at once, larger fields grow
+// with the data that is actually read, so that the length of a
+// corrupt snapshot can't allocate more memory than the input has.
+const smallSnapshotField = 64 * 1024
+
+// bytes returns io.EOF only if the stream ends before the length.
+func (sr *SnapshotReader) bytes() ([]byte, error) {
+	n, err := binary.ReadUvarint(sr.r)
+	if err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil || n > maxSnapshotField {
+		return nil, ErrInvalidSnapshot
+	}
+
+	if n <= smallSnapshotField {
+		b := make([]byte, n)
+		_, err = io.ReadFull(sr.r, b)
+		if err != nil {
+			return nil, ErrInvalidSnapshot
+		}
+		return b, nil
+	}
+
+	var buf bytes.Buffer
+	_, err = io.CopyN(&buf, sr.r, int64(n))
+	if err != nil {
+		return nil, ErrInvalidSnapshot
+	}
+	return buf.Bytes(), nil
+}
+
+// Read returns the next record. After the last record io.EOF is
+// returned. The expiry of the record is WrittenAt plus its ttl.
+func (sr *SnapshotReader) Read() (Record, error) {
+	key, err := sr.bytes()
+	if err == io.EOF {
+		return Record{}, io.EOF
+	} else if err != nil {
+		return Record{}, ErrInvalidSnapshot
+	}
+
+	expires, err := binary.ReadVarint(sr.r)
+	if err != nil {
+		return Record{}, ErrInvalidSnapshot
+	}
+	data, err := sr.bytes()
+	if err != nil {
+		return Record{}, ErrInvalidSnapshot
+	}
+
+	rec := Record{Key: string(key), Data: data}
+	if expires != 0 && sr.version == 1 {
+		rec.Expires = time.Unix(0, expires)
+	} else if expires != 0 {
+		rec.Expires = sr.writtenAt.Add(time.Duration(expires))
+	}
+	return rec, nil
+}
+
+// Dumper is implemented by adapters that can list their items
+// together with the expiry. Expired items are skipped.
+type Dumper interface {
+	Dump(fn func(rec Record) error) error
+}
+
+// Export writes every item of the last adapter that implements
+// Dumper to w (see SnapshotWriter for the format). Expired
+// items are skipped.
+func (c *Cache) Export(w io.Writer) error {
+	var dumper Dumper
+	for _, adapter := range c.adapters {
+		var d Dumper
+		if as(adapter, &d) {
+			dumper = d
+		}
+	}
+	if dumper == nil {
+		return ErrUnsupported
+	}
+
+	now := time.Now()
+	sw, err := NewSnapshotWriter(w, now)
+	if err != nil {
+		return err
+	}
+	err = dumper.Dump(func(rec Record) error {
+		if !rec.Expires.IsZero() && !rec.Expires.After(now) {
+			return nil
+		}
+		return sw.Write(rec)
+	})
+	if err != nil {
+		return err
+	}
+
+	return sw.Flush()
+}
+
+// Import reads a snapshot that was written by Export and sets
+// every item in every adapter. Adapters that implement TTLSetter
+// keep the remaining TTL of the item, the others use their
+// default TTL. Expired items are skipped.
+func (c *Cache) Import(r io.Reader) error {
+	sr, err := NewSnapshotReader(r)
+	if err != nil {
+		return err
+	}
+
+	for {
+		rec, err := sr.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var ttl time.Duration
+		if !rec.Expires.IsZero() {
+			ttl = time.Until(rec.Expires)
+			if ttl <= 0 {
+				continue
+			}
+		}
+
+		for _, adapter := range c.adapters {
+			var setter TTLSetter
+			if as(adapter, &setter) && ttl > 0 {
+				err = setter.SetWithTTL(rec.Key, rec.Data, ttl)
+			} else {
+				err = adapter.Set(rec.Key, rec.Data)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
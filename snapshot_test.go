@@ -0,0 +1,158 @@
+package cache_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+	"github.com/JohannesKaufmann/dynamodb-cache/memadapter"
+)
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	expires := time.Unix(1600000000, 123)
+	writtenAt := time.Unix(1500000000, 0)
+
+	var buf bytes.Buffer
+	sw, err := cache.NewSnapshotWriter(&buf, writtenAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw.Write(cache.Record{Key: "1", Expires: expires, Data: []byte("One")})
+	sw.Write(cache.Record{Key: "2", Data: nil})
+	err = sw.Flush()
+	if err != nil {
+		t.Error(err)
+	}
+
+	sr, err := cache.NewSnapshotReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sr.WrittenAt().Equal(writtenAt) {
+		t.Error("wrong time of writing")
+	}
+
+	rec, err := sr.Read()
+	if err != nil {
+		t.Error(err)
+	}
+	if rec.Key != "1" || !rec.Expires.Equal(expires) || string(rec.Data) != "One" {
+		t.Errorf("got different record: %+v", rec)
+	}
+	rec, err = sr.Read()
+	if err != nil {
+		t.Error(err)
+	}
+	if rec.Key != "2" || !rec.Expires.IsZero() || len(rec.Data) != 0 {
+		t.Errorf("got different record: %+v", rec)
+	}
+	_, err = sr.Read()
+	if err != io.EOF {
+		t.Error(err)
+	}
+}
+
+func TestSnapshot_Version1(t *testing.T) {
+	expires := time.Unix(1600000000, 123)
+
+	var buf bytes.Buffer
+	buf.WriteString("DYNCACHE\x01")
+	binary.Write(&buf, binary.BigEndian, time.Unix(1500000000, 0).UnixNano())
+	buf.WriteString("\x011")
+	varint := make([]byte, binary.MaxVarintLen64)
+	buf.Write(varint[:binary.PutVarint(varint, expires.UnixNano())])
+	buf.WriteByte(0)
+
+	sr, err := cache.NewSnapshotReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := sr.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Key != "1" || !rec.Expires.Equal(expires) {
+		t.Errorf("got different record: %+v", rec)
+	}
+}
+
+func TestSnapshot_Invalid(t *testing.T) {
+	_, err := cache.NewSnapshotReader(strings.NewReader("something else"))
+	if err != cache.ErrInvalidSnapshot {
+		t.Error(err)
+	}
+}
+
+func TestSnapshot_Corrupt(t *testing.T) {
+	var header bytes.Buffer
+	sw, _ := cache.NewSnapshotWriter(&header, time.Now())
+	sw.Flush()
+
+	uvarint := func(n uint64) string {
+		buf := make([]byte, binary.MaxVarintLen64)
+		return string(buf[:binary.PutUvarint(buf, n)])
+	}
+	tests := map[string]string{
+		"huge key length":   uvarint(1<<63) + "key",
+		"truncated key":     uvarint(10) + "key",
+		"truncated expires": uvarint(3) + "key" + "\xff",
+		"missing data":      uvarint(3) + "key" + "\x00",
+		"huge data length":  uvarint(3) + "key" + "\x00" + uvarint(1<<29) + "data",
+		"varint overflow":   strings.Repeat("\xff", 11),
+	}
+
+	for name, records := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := cache.New(memadapter.New(time.Hour, false))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = c.Import(strings.NewReader(header.String() + records))
+			if err != cache.ErrInvalidSnapshot {
+				t.Errorf("expected ErrInvalidSnapshot but got %v", err)
+			}
+		})
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	from, err := cache.New(memadapter.New(time.Hour, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	from.Set("1", "One")
+	from.Set("2", "Two")
+
+	var buf bytes.Buffer
+	err = from.Export(&buf)
+	if err != nil {
+		t.Error(err)
+	}
+
+	mem, _ := memadapter.New(time.Minute, false)()
+	to, err := cache.New(func() (cache.Adapter, error) { return mem, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = to.Import(&buf)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var target string
+	info, err := to.GetWithMetadata("2", &target)
+	if err != nil {
+		t.Error(err)
+	}
+	if target != "Two" {
+		t.Errorf("expected 'Two' but got '%s'", target)
+	}
+	if time.Until(info.Expires) < time.Minute*59 {
+		t.Error("expected the remaining ttl to be kept")
+	}
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// WriteStrategy decides in which order Set and Del change the adapters.
+type WriteStrategy int
+
+const (
+	// TopDown changes one adapter after another in the order
+	// of New and stops at the first error.
+	TopDown WriteStrategy = iota
+	// BottomUp changes one adapter after another, starting with
+	// the last one, and stops at the first error. Deleting bottom
+	// up means that an upper adapter can't be filled again from a
+	// lower one that still has the old value.
+	BottomUp
+	// Concurrent changes every adapter at the same time, so the
+	// latency is the one of the slowest adapter instead of the sum.
+	// If any adapter fails TierErrors is returned.
+	Concurrent
+)
+
+// TierError is the error of one adapter.
+type TierError struct {
+	Tier     int
+	TierName string
+	Err      error
+}
+
+func (e *TierError) Error() string {
+	return fmt.Sprintf("%s (tier %d): %v", e.TierName, e.Tier, e.Err)
+}
+
+func (e *TierError) Unwrap() error {
+	return e.Err
+}
+
+// TierErrors holds the errors of the adapters that failed
+// with the Concurrent strategy.
+type TierErrors []*TierError
+
+func (e TierErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e TierErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// each calls fn for every adapter with the strategy.
+func (c *Cache) each(strategy WriteStrategy, fn func(adapter Adapter) error) error {
+	switch strategy {
+	case BottomUp:
+		for i := len(c.adapters) - 1; i >= 0; i-- {
+			err := fn(c.adapters[i])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case Concurrent:
+		errs := make([]error, len(c.adapters))
+		var wg sync.WaitGroup
+		for i, adapter := range c.adapters {
+			wg.Add(1)
+			go func(i int, adapter Adapter) {
+				defer wg.Done()
+				errs[i] = fn(adapter)
+			}(i, adapter)
+		}
+		wg.Wait()
+
+		var tierErrs TierErrors
+		for i, err := range errs {
+			if err != nil {
+				tierErrs = append(tierErrs, &TierError{
+					Tier:     i,
+					TierName: c.names[i],
+					Err:      err,
+				})
+			}
+		}
+		if len(tierErrs) > 0 {
+			return tierErrs
+		}
+		return nil
+	}
+
+	for _, adapter := range c.adapters {
+		err := fn(adapter)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package cache_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/JohannesKaufmann/dynamodb-cache"
+)
+
+// newOrderCache returns a cache whose adapters append
+// their index to order when they are called.
+func newOrderCache(t *testing.T, n int, delay time.Duration, failing map[int]error, opts ...cache.Option) (*cache.Cache, *[]int) {
+	var m sync.Mutex
+	var order []int
+
+	var inits []cache.InitAdapter
+	for i := 0; i < n; i++ {
+		i := i
+		call := func() error {
+			time.Sleep(delay)
+			m.Lock()
+			order = append(order, i)
+			m.Unlock()
+			return failing[i]
+		}
+		mock := &AdapterMock{
+			SetFunc: func(key string, data []byte) error { return call() },
+			DelFunc: func(key string) error { return call() },
+		}
+		inits = append(inits, func() (cache.Adapter, error) { return mock, nil })
+	}
+
+	c, err := cache.NewWithOptions(append([]cache.Option{cache.WithAdapters(inits...)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, &order
+}
+
+func TestDel_BottomUp(t *testing.T) {
+	c, order := newOrderCache(t, 3, 0, nil, cache.WithDelStrategy(cache.BottomUp))
+
+	err := c.Del("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*order) != 3 || (*order)[0] != 2 || (*order)[2] != 0 {
+		t.Errorf("expected the last adapter first but got %v", *order)
+	}
+
+	// Set still uses the default
+	*order = nil
+	err = c.Set("key", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*order)[0] != 0 {
+		t.Errorf("expected the first adapter first but got %v", *order)
+	}
+}
+
+func TestSet_Concurrent(t *testing.T) {
+	c, order := newOrderCache(t, 3, time.Millisecond*50, nil, cache.WithWriteStrategy(cache.Concurrent))
+
+	start := time.Now()
+	err := c.Set("key", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*order) != 3 {
+		t.Errorf("expected every adapter to be called but got %v", *order)
+	}
+	if time.Since(start) > time.Millisecond*140 {
+		t.Errorf("expected the adapters to be called concurrently but took %s", time.Since(start))
+	}
+}
+
+func TestSet_ConcurrentErrors(t *testing.T) {
+	e1 := errors.New("first failed")
+	e2 := errors.New("third failed")
+	c, order := newOrderCache(t, 3, 0, map[int]error{0: e1, 2: e2}, cache.WithWriteStrategy(cache.Concurrent))
+
+	err := c.Set("key", "value")
+	tierErrs, ok := err.(cache.TierErrors)
+	if !ok {
+		t.Fatalf("expected TierErrors but got %v", err)
+	}
+	if len(*order) != 3 {
+		t.Error("expected every adapter to be called despite the errors")
+	}
+	if len(tierErrs) != 2 || tierErrs[0].Tier != 0 || tierErrs[0].Err != e1 || tierErrs[1].Tier != 2 || tierErrs[1].Err != e2 {
+		t.Errorf("got wrong errors: %v", tierErrs)
+	}
+	if !errors.Is(err, e2) {
+		t.Error("expected errors.Is to find the error of an adapter")
+	}
+}